@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const adoptDesc = `
+This command creates a release from resources that already exist in the
+cluster, such as workloads that were deployed by hand or by another tool.
+
+The chart is rendered with the given values like it would be installed. Every
+resource it produces must already exist in the cluster and match the chart
+output, otherwise the command fails and lists the differences. Helm then takes
+ownership of the resources and records them as the first revision of the
+release. No resources are created and no hooks are run.
+
+Use '--dry-run' to check that the chart matches the cluster and see which
+resources would be adopted.
+
+To adopt only some of the resources of a chart, or resources that do not match
+it, use 'helm install --take-ownership' or 'helm upgrade --take-ownership'
+instead.
+`
+
+func newAdoptCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewAdopt(cfg)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "adopt NAME CHART",
+		Short: "create a release from existing resources",
+		Long:  adoptDesc,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 1 {
+				return compListCharts(toComplete, true)
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			client.ReleaseName = args[0]
+			client.Namespace = settings.Namespace()
+
+			cp, err := client.ChartPathOptions.LocateChart(args[1], settings)
+			if err != nil {
+				return err
+			}
+			debug("CHART PATH: %s\n", cp)
+
+			vals, err := valueOpts.MergeValues(getter.All(settings))
+			if err != nil {
+				return err
+			}
+			ch, err := loader.Load(cp)
+			if err != nil {
+				return err
+			}
+			if err := checkIfInstallable(ch); err != nil {
+				return err
+			}
+			if req := ch.Metadata.Dependencies; req != nil {
+				if err := action.CheckDependencies(ch, req); err != nil {
+					return err
+				}
+			}
+
+			rel, adopted, err := client.Run(ch, vals)
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &adoptWriter{rel, adopted, client.DryRun})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.DryRun, "dry-run", false, "check that the chart matches the cluster and list the resources that would be adopted")
+	f.StringVar(&client.Description, "description", "", "add a custom description")
+	f.StringToStringVarP(&client.Labels, "labels", "l", nil, "labels that will be added to the release metadata. Should be divided by comma")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of the release if another operation holds it. By default the command fails immediately")
+	addValueOptionsFlags(f, valueOpts)
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type adoptResult struct {
+	Name      string                   `json:"name"`
+	Namespace string                   `json:"namespace"`
+	Revision  int                      `json:"revision"`
+	Status    string                   `json:"status"`
+	Adopted   []action.AdoptedResource `json:"adopted"`
+}
+
+type adoptWriter struct {
+	release *release.Release
+	adopted []action.AdoptedResource
+	dryRun  bool
+}
+
+func (w *adoptWriter) result() adoptResult {
+	adopted := w.adopted
+	if adopted == nil {
+		adopted = []action.AdoptedResource{}
+	}
+	return adoptResult{
+		Name:      w.release.Name,
+		Namespace: w.release.Namespace,
+		Revision:  w.release.Version,
+		Status:    w.release.Info.Status.String(),
+		Adopted:   adopted,
+	}
+}
+
+func (w *adoptWriter) WriteTable(out io.Writer) error {
+	if w.dryRun {
+		fmt.Fprintf(out, "The chart matches the resources in the cluster. Release %q can be adopted.\n", w.release.Name)
+		return writeAdoptedResources(out, w.adopted, "would be")
+	}
+	fmt.Fprintf(out, "Release %q has been created from existing resources.\n", w.release.Name)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", w.release.Namespace)
+	fmt.Fprintf(out, "STATUS: %s\n", w.release.Info.Status.String())
+	fmt.Fprintf(out, "REVISION: %d\n", w.release.Version)
+	return writeAdoptedResources(out, w.adopted, "were")
+}
+
+func (w *adoptWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.result())
+}
+
+func (w *adoptWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.result())
+}
+
+// writeAdoptedResources prints the resources whose ownership is taken by a release.
+func writeAdoptedResources(out io.Writer, adopted []action.AdoptedResource, verb string) error {
+	if len(adopted) == 0 {
+		fmt.Fprintf(out, "No existing resources %s adopted.\n", verb)
+		return nil
+	}
+
+	fmt.Fprintf(out, "The following resources %s adopted:\n", verb)
+	table := uitable.New()
+	table.AddRow("KIND", "NAME", "NAMESPACE", "PREVIOUS OWNER")
+	for _, r := range adopted {
+		owner := "<none>"
+		if r.PreviousRelease != "" {
+			owner = fmt.Sprintf("%s/%s", r.PreviousReleaseNamespace, r.PreviousRelease)
+		}
+		table.AddRow(r.Kind, r.Name, r.Namespace, owner)
+	}
+	return output.EncodeTable(out, table)
+}
+
+// writeAdoptionReport prints the resources whose ownership would be taken by
+// a dry run of an install or upgrade with --take-ownership.
+func writeAdoptionReport(out io.Writer, cfg *action.Configuration, rel *release.Release) error {
+	adopted, err := action.ResourcesToAdopt(cfg, rel)
+	if err != nil {
+		return err
+	}
+	return writeAdoptedResources(out, adopted, "would be")
+}
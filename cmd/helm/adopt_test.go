@@ -0,0 +1,59 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestAdoptCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "adopt existing resources",
+		cmd:    "adopt adopted testdata/testcharts/empty",
+		golden: "output/adopt.txt",
+	}, {
+		name:   "adopt existing resources with dry run",
+		cmd:    "adopt adopted testdata/testcharts/empty --dry-run",
+		golden: "output/adopt-dry-run.txt",
+	}, {
+		name:   "adopt existing resources with json output",
+		cmd:    "adopt adopted testdata/testcharts/empty -o json",
+		golden: "output/adopt.json",
+	}, {
+		name:      "adopt into an existing release",
+		cmd:       "adopt adopted testdata/testcharts/empty",
+		golden:    "output/adopt-existing-release.txt",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "adopted"})},
+		wantError: true,
+	}, {
+		name:      "adopt without chart",
+		cmd:       "adopt adopted",
+		golden:    "output/adopt-no-args.txt",
+		wantError: true,
+	}, {
+		name:   "install with take ownership and dry run",
+		cmd:    "install adopted testdata/testcharts/empty --take-ownership --dry-run",
+		golden: "output/install-take-ownership-dry-run.txt",
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestAdoptOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "adopt")
+}
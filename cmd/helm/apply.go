@@ -0,0 +1,102 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+const applyDesc = `
+This command executes a plan made by 'helm upgrade --plan-out'.
+
+The manifest, hooks and values captured in the plan are applied exactly as
+they were reviewed: the chart is not rendered again. The checksums of the plan
+are verified, and it is refused if the release has been changed since the
+plan was made. Make a new plan in that case.
+
+    $ helm upgrade --plan-out plan.tgz redis ./redis
+    $ helm apply plan.tgz
+`
+
+func newApplyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewUpgrade(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "apply PLAN",
+		Short: "execute the plan of an upgrade",
+		Long:  applyDesc,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			plan, err := action.ReadPlan(f)
+			if err != nil {
+				return err
+			}
+
+			client.Namespace = settings.Namespace()
+			ctx, stop := interruptContext()
+			defer stop()
+			rel, err := client.ApplyPlan(ctx, plan)
+			if err != nil {
+				return errors.Wrap(err, "UPGRADE FAILED")
+			}
+
+			if outfmt == output.Table {
+				fmt.Fprintf(out, "Release %q has been upgraded. Happy Helming!\n", plan.Name)
+			}
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Force, "force", false, "force resource updates through a replacement strategy")
+	f.BoolVar(&client.DisableHooks, "no-hooks", false, "disable pre/post upgrade hooks")
+	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the manifest of the plan will not be validated against the Kubernetes OpenAPI Schema")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	bindWaitProgressFlag(f, &client.WaitProgress)
+	f.BoolVar(&client.Atomic, "atomic", false, "if set, upgrade process rolls back changes made in case of failed upgrade. The --wait flag will be set automatically if --atomic is used")
+	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
+	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this upgrade when upgrade fails")
+	f.StringVar(&client.Description, "description", "", "add a custom description")
+	f.BoolVar(&client.ServerSideApply, "server-side", false, "if set, update the resources with server-side apply instead of a client-side three-way merge")
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if --server-side is set, take ownership of fields managed by other field managers")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of the release if another operation holds it. By default the command fails immediately")
+	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "if set, take ownership of resources that already exist in the cluster, even if they are not annotated as owned by this release")
+	bindOutputFlag(cmd, &outfmt)
+	bindSerialHooksFlag(cmd, cfg)
+	bindKeepHookLogsFlag(cmd, cfg)
+
+	return cmd
+}
@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/internal/test/ensure"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestApplyCmd(t *testing.T) {
+	defer resetEnv()()
+
+	store := storageFixture()
+	if err := store.Create(release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 1})); err != nil {
+		t.Fatal(err)
+	}
+	plan := filepath.Join(ensure.TempDir(t), "plan.tgz")
+	chartPath := "testdata/testcharts/empty"
+
+	_, out, err := executeActionCommandC(store, fmt.Sprintf("upgrade funny-bunny '%s' --plan-out '%s'", chartPath, plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("Plan to upgrade release \"funny-bunny\" to revision 2 written to %s\n", plan)
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if last, err := store.Last("funny-bunny"); err != nil || last.Version != 1 {
+		t.Fatalf("expected the plan not to upgrade the release, got %v, %v", last, err)
+	}
+
+	_, out, err = executeActionCommandC(store, fmt.Sprintf("apply '%s'", plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "Release \"funny-bunny\" has been upgraded. Happy Helming!\n") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	last, err := store.Last("funny-bunny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Version != 2 || last.Info.Status != release.StatusDeployed {
+		t.Errorf("expected revision 2 to be deployed, got revision %d %s", last.Version, last.Info.Status)
+	}
+
+	_, _, err = executeActionCommandC(store, fmt.Sprintf("apply '%s'", plan))
+	if err == nil || !strings.Contains(err.Error(), "make a new plan") {
+		t.Errorf("expected a plan to be refused once applied, got %v", err)
+	}
+}
+
+func TestUpgradePlanOutInstall(t *testing.T) {
+	defer resetEnv()()
+
+	plan := filepath.Join(ensure.TempDir(t), "plan.tgz")
+	_, _, err := executeActionCommand(fmt.Sprintf("upgrade zany-bunny -i testdata/testcharts/empty --plan-out '%s'", plan))
+	expected := `release "zany-bunny" does not exist: --plan-out can only be used to upgrade a release`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error %q, got %v", expected, err)
+	}
+	if _, err := os.Stat(plan); !os.IsNotExist(err) {
+		t.Errorf("expected no plan to be written, got %v", err)
+	}
+}
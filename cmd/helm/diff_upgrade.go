@@ -0,0 +1,175 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+const diffUpgradeHelp = `
+This command shows what an upgrade of a release would change.
+
+The chart is rendered the same way 'helm upgrade' renders it, and every object
+of the result is compared with the manifest of the currently deployed revision.
+Nothing is changed in the cluster.
+
+The arguments and the chart and values flags are the same as for 'helm upgrade':
+
+    $ helm diff upgrade -f myvalues.yaml redis ./redis
+
+With '--output json' or '--output yaml' the added, removed and changed objects
+are printed keyed by "<apiVersion>/<kind>/<namespace>/<name>".
+`
+
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+func newDiffUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewDiff(cfg)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+	var noColor bool
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [RELEASE] [CHART]",
+		Short: "show the changes an upgrade would make",
+		Long:  diffUpgradeHelp,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListCharts(toComplete, true)
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client.Namespace = settings.Namespace()
+
+			if client.Version == "" && client.Devel {
+				debug("setting version to >0.0.0-0")
+				client.Version = ">0.0.0-0"
+			}
+
+			chartPath, err := client.ChartPathOptions.LocateChart(args[1], settings)
+			if err != nil {
+				return err
+			}
+
+			vals, err := valueOpts.MergeValues(getter.All(settings))
+			if err != nil {
+				return err
+			}
+
+			ch, err := loader.Load(chartPath)
+			if err != nil {
+				return err
+			}
+			if req := ch.Metadata.Dependencies; req != nil {
+				if err := action.CheckDependencies(ch, req); err != nil {
+					return err
+				}
+			}
+
+			res, err := client.Run(args[0], ch, vals)
+			if err != nil {
+				return err
+			}
+
+			return outfmt.Write(out, &diffPrinter{res, !noColor})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.ResetValues, "reset-values", false, "when upgrading, reset the values to the ones built into the chart")
+	f.BoolVar(&client.ReuseValues, "reuse-values", false, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' is specified, this is ignored")
+	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the proposed manifest will not be validated against the Kubernetes OpenAPI Schema")
+	f.IntVar(&client.Context, "context", 3, "number of unchanged lines to show around each change")
+	f.BoolVar(&noColor, "no-color", false, "disable colored output")
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+	addValueOptionsFlags(f, valueOpts)
+	bindOutputFlag(cmd, &outfmt)
+	bindPostRenderFlag(cmd, &client.PostRenderer)
+
+	return cmd
+}
+
+type diffPrinter struct {
+	result *action.DiffResult
+	color  bool
+}
+
+func (d diffPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, d.result)
+}
+
+func (d diffPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, d.result)
+}
+
+func (d diffPrinter) WriteTable(out io.Writer) error {
+	if !d.result.HasChanges() {
+		fmt.Fprintln(out, "No changes.")
+		return nil
+	}
+	for _, key := range d.result.Keys() {
+		for _, line := range strings.SplitAfter(d.result.Get(key).Diff, "\n") {
+			if line == "" {
+				continue
+			}
+			fmt.Fprint(out, d.colorize(line))
+		}
+	}
+	return nil
+}
+
+func (d diffPrinter) colorize(line string) string {
+	if !d.color {
+		return line
+	}
+	var color string
+	switch {
+	case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		return line
+	case strings.HasPrefix(line, "@@"):
+		color = colorCyan
+	case strings.HasPrefix(line, "-"):
+		color = colorRed
+	case strings.HasPrefix(line, "+"):
+		color = colorGreen
+	default:
+		return line
+	}
+	return color + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+}
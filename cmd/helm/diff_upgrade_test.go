@@ -0,0 +1,54 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestDiffUpgradeCmd(t *testing.T) {
+	releaseName := "funny-bunny-diff"
+	relMock, ch, chartPath := prepareMockRelease(releaseName, t)
+
+	tests := []cmdTestCase{
+		{
+			name:   "diff an upgrade",
+			cmd:    fmt.Sprintf("diff upgrade %s --no-color --values testdata/testcharts/upgradetest/values.yaml '%s'", releaseName, chartPath),
+			golden: "output/diff-upgrade.txt",
+			rels:   []*release.Release{relMock(releaseName, 3, ch)},
+		},
+		{
+			name:   "diff an upgrade as json",
+			cmd:    fmt.Sprintf("diff upgrade %s --output json --values testdata/testcharts/upgradetest/values.yaml '%s'", releaseName, chartPath),
+			golden: "output/diff-upgrade.json",
+			rels:   []*release.Release{relMock(releaseName, 3, ch)},
+		},
+		{
+			name:      "diff an upgrade of a release that is not deployed",
+			cmd:       fmt.Sprintf("diff upgrade %s '%s'", releaseName, chartPath),
+			wantError: true,
+		},
+	}
+	runTestCmd(t, tests)
+}
+
+func TestDiffUpgradeOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "diff upgrade")
+}
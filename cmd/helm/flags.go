@@ -20,10 +20,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
@@ -32,12 +35,15 @@ import (
 	"helm.sh/helm/v3/pkg/cli/output"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/helmpath"
+	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/repo"
 )
 
 const outputFlag = "output"
 const postRenderFlag = "post-renderer"
+const waitProgressFlag = "wait-progress"
+const dryRunFlag = "dry-run"
 
 func addValueOptionsFlags(f *pflag.FlagSet, v *values.Options) {
 	f.StringSliceVarP(&v.ValueFiles, "values", "f", []string{}, "specify values in a YAML file or a URL (can specify multiple)")
@@ -111,6 +117,87 @@ func (o *outputValue) Set(s string) error {
 	return nil
 }
 
+// bindSerialHooksFlag binds the flag that runs the hooks of the release one at a time.
+func bindSerialHooksFlag(cmd *cobra.Command, cfg *action.Configuration) {
+	cmd.Flags().BoolVar(&cfg.SerialHooks, "serial-hooks", false, "run hooks one at a time. By default, hooks with the same weight and kind run concurrently")
+}
+
+// bindKeepHookLogsFlag binds the flag that keeps the output of successful hooks in the release.
+func bindKeepHookLogsFlag(cmd *cobra.Command, cfg *action.Configuration) {
+	cmd.Flags().BoolVar(&cfg.KeepHookOutput, "keep-hook-logs", false, "keep the container logs and events of successful hooks in the release. By default, they are only kept for failed hooks")
+}
+
+// bindWaitProgressFlag binds the flag that prints the resources that are not
+// ready yet to stderr while waiting for them.
+func bindWaitProgressFlag(f *pflag.FlagSet, varRef *kube.ProgressFunc) {
+	f.Var(&waitProgressValue{varRef}, waitProgressFlag, "if set with --wait, print which resources are not ready yet, and why, while waiting for them")
+	f.Lookup(waitProgressFlag).NoOptDefVal = "true"
+}
+
+type waitProgressValue struct {
+	progress *kube.ProgressFunc
+}
+
+func (v *waitProgressValue) String() string {
+	return strconv.FormatBool(*v.progress != nil)
+}
+
+func (v *waitProgressValue) Type() string {
+	return "bool"
+}
+
+func (v *waitProgressValue) Set(s string) error {
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v.progress = nil
+	if enabled {
+		*v.progress = newWaitProgressPrinter(os.Stderr)
+	}
+	return nil
+}
+
+// bindDryRunFlag binds the flag that simulates the operation, on the client or
+// on the API server.
+func bindDryRunFlag(f *pflag.FlagSet, dryRun *bool, option *string, usage string) {
+	f.Var(&dryRunValue{dryRun, option}, dryRunFlag, usage+`. If set without a value or to "client", the resources are not sent to the cluster. If set to "server", they are sent to the API server as dry-run requests, which runs admission webhooks and lets lookup query the cluster`)
+	f.Lookup(dryRunFlag).NoOptDefVal = action.DryRunClient
+}
+
+type dryRunValue struct {
+	dryRun *bool
+	option *string
+}
+
+func (v *dryRunValue) String() string {
+	if !*v.dryRun {
+		return ""
+	}
+	if *v.option == "" {
+		return action.DryRunClient
+	}
+	return *v.option
+}
+
+func (v *dryRunValue) Type() string {
+	return "string"
+}
+
+func (v *dryRunValue) Set(s string) error {
+	switch s {
+	case action.DryRunClient, "true":
+		*v.dryRun, *v.option = true, action.DryRunClient
+	case action.DryRunServer:
+		*v.dryRun, *v.option = true, action.DryRunServer
+	case "none", "false":
+		*v.dryRun, *v.option = false, ""
+	default:
+		return errors.Errorf("must be %q, %q or %q", action.DryRunClient, action.DryRunServer, "none")
+	}
+	return nil
+}
+
 func bindPostRenderFlag(cmd *cobra.Command, varRef *postrender.PostRenderer) {
 	cmd.Flags().Var(&postRenderer{varRef}, postRenderFlag, "the path to an executable to be used for post rendering. If it exists in $PATH, the binary will be used, otherwise it will try to look for the executable at the given path")
 }
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/spf13/pflag"
+
+	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -86,3 +89,39 @@ func outputFlagCompletionTest(t *testing.T, cmdName string) {
 	}}
 	runTestCmd(t, tests)
 }
+
+func TestDryRunFlag(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantDryRun bool
+		wantOption string
+	}{
+		{args: nil},
+		{args: []string{"--dry-run"}, wantDryRun: true, wantOption: action.DryRunClient},
+		{args: []string{"--dry-run=true"}, wantDryRun: true, wantOption: action.DryRunClient},
+		{args: []string{"--dry-run=client"}, wantDryRun: true, wantOption: action.DryRunClient},
+		{args: []string{"--dry-run=server"}, wantDryRun: true, wantOption: action.DryRunServer},
+		{args: []string{"--dry-run=server", "--dry-run=none"}},
+		{args: []string{"--dry-run=false"}},
+	}
+	for _, tt := range tests {
+		var dryRun bool
+		var option string
+		f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		bindDryRunFlag(f, &dryRun, &option, "simulate")
+		if err := f.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if dryRun != tt.wantDryRun || option != tt.wantOption {
+			t.Errorf("%v: expected dry run %v with option %q, got %v with %q", tt.args, tt.wantDryRun, tt.wantOption, dryRun, option)
+		}
+	}
+
+	var dryRun bool
+	var option string
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	bindDryRunFlag(f, &dryRun, &option, "simulate")
+	if err := f.Parse([]string{"--dry-run=cluster"}); err == nil {
+		t.Error("expected an invalid --dry-run value to fail")
+	}
+}
@@ -20,21 +20,30 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 const getHooksHelp = `
 This command downloads hooks for a given release.
 
 Hooks are formatted in YAML and separated by the YAML '---\n' separator.
+
+With --logs, the container logs and Kubernetes events captured when each hook
+last ran are appended to the hook as YAML comments. They are captured for failed
+hooks, and for successful ones if the release was installed or upgraded with
+--keep-hook-logs.
 `
 
 func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewGet(cfg)
+	var showLogs bool
 
 	cmd := &cobra.Command{
 		Use:   "hooks RELEASE_NAME",
@@ -54,12 +63,16 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			}
 			for _, hook := range res.Hooks {
 				fmt.Fprintf(out, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+				if showLogs {
+					writeHookRun(out, hook, "# ")
+				}
 			}
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&client.Version, "revision", 0, "get the named release with revision")
+	cmd.Flags().BoolVar(&showLogs, "logs", false, "show the container logs and events captured when each hook last ran")
 	err := cmd.RegisterFlagCompletionFunc("revision", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 1 {
 			return compListRevisions(toComplete, cfg, args[0])
@@ -73,3 +86,44 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	return cmd
 }
+
+// writeHookRun writes the result of the last run of a hook with the logs and
+// events captured when it completed. Every line is prefixed with prefix.
+func writeHookRun(out io.Writer, h *release.Hook, prefix string) {
+	run := h.LastRun
+	if run.StartedAt.IsZero() {
+		fmt.Fprintf(out, "%sLast run: never\n", prefix)
+		return
+	}
+	fmt.Fprintf(out, "%sLast run: %s, started %s", prefix, run.Phase, run.StartedAt.Format(time.ANSIC))
+	if !run.CompletedAt.IsZero() {
+		fmt.Fprintf(out, ", completed %s", run.CompletedAt.Format(time.ANSIC))
+	}
+	fmt.Fprintln(out)
+
+	for _, l := range run.Logs {
+		truncated := ""
+		if l.Truncated {
+			truncated = " (beginning truncated)"
+		}
+		if l.Error != "" && l.Log == "" {
+			fmt.Fprintf(out, "%sLog of container %s in pod %s unavailable: %s\n", prefix, l.Container, l.Pod, l.Error)
+			continue
+		}
+		fmt.Fprintf(out, "%sLog of container %s in pod %s%s:\n", prefix, l.Container, l.Pod, truncated)
+		for _, line := range strings.Split(strings.TrimRight(l.Log, "\n"), "\n") {
+			fmt.Fprintf(out, "%s  %s\n", prefix, line)
+		}
+	}
+
+	if len(run.Events) > 0 {
+		fmt.Fprintf(out, "%sEvents:\n", prefix)
+		for _, e := range run.Events {
+			count := ""
+			if e.Count > 1 {
+				count = fmt.Sprintf(" (x%d)", e.Count)
+			}
+			fmt.Fprintf(out, "%s  %s %s %s: %s%s\n", prefix, e.Type, e.Reason, e.Object, e.Message, count)
+		}
+	}
+}
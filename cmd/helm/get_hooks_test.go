@@ -28,6 +28,15 @@ func TestGetHooks(t *testing.T) {
 		cmd:    "get hooks aeneas",
 		golden: "output/get-hooks.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+	}, {
+		name:   "get hooks with logs",
+		cmd:    "get hooks aeneas --logs",
+		golden: "output/get-hooks-logs.txt",
+		rels: []*release.Release{func() *release.Release {
+			rel := release.Mock(&release.MockReleaseOptions{Name: "aeneas"})
+			rel.Hooks = append(rel.Hooks, hookWithLogs())
+			return rel
+		}()},
 	}, {
 		name:      "get hooks without args",
 		cmd:       "get hooks",
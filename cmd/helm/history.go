@@ -81,6 +81,8 @@ func newHistoryCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.IntVar(&client.Max, "max", 256, "maximum number of revision to include in history")
 	bindOutputFlag(cmd, &outfmt)
 
+	cmd.AddCommand(newHistoryPruneCmd(cfg, out))
+
 	return cmd
 }
 
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+var historyPruneHelp = `
+This command removes old revisions from the history of releases according to
+a retention policy. Without a release name, every release of the namespace is
+pruned, or of every namespace with '--all-namespaces'.
+
+Revisions are removed if they are older than '--max-age', or beyond the
+number of superseded or failed revisions, or of revisions in total, to keep.
+The last revision, the deployed revision and pending revisions are always
+kept, as are the last revisions that were deployed if '--keep-deployed' is
+set, so that the release can be rolled back to them:
+
+    $ helm history prune --max-age 720h --keep-deployed 3 --max-failed 1 -A
+
+Only the records of the revisions are removed: the resources of the releases
+are not touched.
+`
+
+func newHistoryPruneCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewHistoryPrune(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "prune [RELEASE_NAME...]",
+		Short: "remove old revisions from the history of releases",
+		Long:  historyPruneHelp,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if client.AllNamespaces {
+				if err := cfg.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), debug); err != nil {
+					return err
+				}
+				client.NamespaceStorage = func(namespace string) (*storage.Storage, error) {
+					return action.NewStorage(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debug)
+				}
+			}
+
+			pruned, err := client.Run(args...)
+			if err != nil {
+				if len(pruned) > 0 && !client.DryRun {
+					fmt.Fprintf(out, "Pruned %d revision(s) before failing\n", len(pruned))
+				}
+				return err
+			}
+			return outfmt.Write(out, &pruneWriter{pruned, client.DryRun})
+		},
+	}
+
+	f := cmd.Flags()
+	f.DurationVar(&client.Policy.MaxAge, "max-age", 0, "remove the revisions last deployed longer ago than this duration, e.g. 720h")
+	f.IntVar(&client.Policy.KeepDeployed, "keep-deployed", 0, "always keep this many of the last revisions that were deployed")
+	f.IntVar(&client.Policy.MaxSuperseded, "max-superseded", 0, "maximum number of superseded revisions to keep per release")
+	f.IntVar(&client.Policy.MaxFailed, "max-failed", 0, "maximum number of failed revisions to keep per release")
+	f.IntVar(&client.Policy.MaxHistory, "history-max", 0, "maximum number of revisions to keep per release")
+	f.BoolVarP(&client.AllNamespaces, "all-namespaces", "A", false, "prune the releases of all namespaces")
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the revisions that would be removed without removing them")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of a release if another operation holds it. By default the command fails immediately")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type prunedRevision struct {
+	Name      string        `json:"name"`
+	Namespace string        `json:"namespace"`
+	Revision  int           `json:"revision"`
+	Status    string        `json:"status"`
+	Updated   helmtime.Time `json:"updated"`
+}
+
+type pruneWriter struct {
+	pruned []*release.Release
+	dryRun bool
+}
+
+func (w *pruneWriter) WriteTable(out io.Writer) error {
+	if len(w.pruned) == 0 {
+		fmt.Fprintln(out, "No revisions to prune")
+		return nil
+	}
+	table := uitable.New()
+	table.AddRow("NAME", "NAMESPACE", "REVISION", "STATUS", "UPDATED")
+	for _, r := range w.revisions() {
+		table.AddRow(r.Name, r.Namespace, r.Revision, r.Status, r.Updated.Format(time.ANSIC))
+	}
+	if err := output.EncodeTable(out, table); err != nil {
+		return err
+	}
+	verb := "Pruned"
+	if w.dryRun {
+		verb = "Would prune"
+	}
+	fmt.Fprintf(out, "%s %d revision(s)\n", verb, len(w.pruned))
+	return nil
+}
+
+func (w *pruneWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.revisions())
+}
+
+func (w *pruneWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.revisions())
+}
+
+func (w *pruneWriter) revisions() []prunedRevision {
+	revisions := []prunedRevision{}
+	for _, r := range w.pruned {
+		revisions = append(revisions, prunedRevision{
+			Name:      r.Name,
+			Namespace: r.Namespace,
+			Revision:  r.Version,
+			Status:    r.Info.Status.String(),
+			Updated:   r.Info.LastDeployed,
+		})
+	}
+	return revisions
+}
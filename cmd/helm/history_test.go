@@ -70,6 +70,48 @@ func TestHistoryCmd(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestHistoryPruneCmd(t *testing.T) {
+	mk := func(name string, vers int, status release.Status) *release.Release {
+		return release.Mock(&release.MockReleaseOptions{
+			Name:    name,
+			Version: vers,
+			Status:  status,
+		})
+	}
+	history := func(name string) []*release.Release {
+		return []*release.Release{
+			mk(name, 1, release.StatusSuperseded),
+			mk(name, 2, release.StatusSuperseded),
+			mk(name, 3, release.StatusFailed),
+			mk(name, 4, release.StatusDeployed),
+		}
+	}
+
+	tests := []cmdTestCase{{
+		name:   "prune the history of every release",
+		cmd:    "history prune --max-superseded 1",
+		rels:   append(history("angry-bird"), history("sad-bird")...),
+		golden: "output/history-prune.txt",
+	}, {
+		name:   "prune the history of a release",
+		cmd:    "history prune angry-bird --history-max 2 --dry-run",
+		rels:   append(history("angry-bird"), history("sad-bird")...),
+		golden: "output/history-prune-dry-run.txt",
+	}, {
+		name:   "prune with json output format",
+		cmd:    "history prune angry-bird --max-failed 1 --max-age 1h --keep-deployed 2 --output json",
+		rels:   history("angry-bird"),
+		golden: "output/history-prune.json",
+	}, {
+		name:      "prune without limits",
+		cmd:       "history prune --keep-deployed 2",
+		rels:      history("angry-bird"),
+		golden:    "output/history-prune-no-limits.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestHistoryOutputCompletion(t *testing.T) {
 	outputFlagCompletionTest(t, "history")
 }
@@ -110,7 +152,28 @@ func revisionFlagCompletionTest(t *testing.T, cmdName string) {
 }
 
 func TestHistoryCompletion(t *testing.T) {
-	checkReleaseCompletion(t, "history", false)
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "athos"}),
+		release.Mock(&release.MockReleaseOptions{Name: "porthos"}),
+		release.Mock(&release.MockReleaseOptions{Name: "aramis"}),
+	}
+	// The prune subcommand is completed along with the releases
+	runTestCmd(t, []cmdTestCase{{
+		name:   "completion for history",
+		cmd:    "__complete history ''",
+		golden: "output/history-comp.txt",
+		rels:   rels,
+	}, {
+		name:   "completion for history repetition",
+		cmd:    "__complete history porthos ''",
+		golden: "output/empty_nofile_comp.txt",
+		rels:   rels,
+	}, {
+		name:   "completion for history prune",
+		cmd:    "__complete history prune ''",
+		golden: "output/history-prune-comp.txt",
+		rels:   rels,
+	}})
 }
 
 func TestHistoryFileCompletion(t *testing.T) {
@@ -0,0 +1,64 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptContext returns a context that is canceled the first time helm is
+// interrupted, so that the operation fails the release, and rolls it back if
+// --atomic is set, instead of leaving it pending. Interrupting helm again
+// terminates it right away. The returned function must be called once the
+// operation is done.
+func interruptContext() (context.Context, func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	ctx, stop := cancelOnSignal(sigs, func() { signal.Stop(sigs) })
+	return ctx, func() {
+		stop()
+		signal.Stop(sigs)
+	}
+}
+
+// cancelOnSignal returns a context that is canceled when a signal is received
+// from sigs. unnotify is called first so that the next signal is handled by
+// the Go runtime again.
+func cancelOnSignal(sigs <-chan os.Signal, unnotify func()) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case sig := <-sigs:
+			unnotify()
+			warning("received %s, canceling the operation. Interrupt again to exit immediately", sig)
+			cancel()
+		case <-done:
+		}
+	}()
+	// Once stopped, no signal is handled anymore
+	return ctx, func() {
+		close(done)
+		<-exited
+		cancel()
+	}
+}
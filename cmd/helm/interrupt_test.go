@@ -0,0 +1,58 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCancelOnSignal(t *testing.T) {
+	sigs := make(chan os.Signal, 1)
+	unnotified := make(chan struct{})
+	ctx, stop := cancelOnSignal(sigs, func() { close(unnotified) })
+	defer stop()
+
+	if ctx.Err() != nil {
+		t.Fatal("expected the context not to be canceled before a signal is received")
+	}
+
+	sigs <- os.Interrupt
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the context to be canceled when a signal is received")
+	}
+	select {
+	case <-unnotified:
+	default:
+		t.Fatal("expected the signal handler to be removed before canceling")
+	}
+}
+
+func TestCancelOnSignalStop(t *testing.T) {
+	sigs := make(chan os.Signal, 1)
+	ctx, stop := cancelOnSignal(sigs, func() { t.Error("expected no signal to be handled") })
+	stop()
+
+	if ctx.Err() == nil {
+		t.Fatal("expected the context to be canceled once stopped")
+	}
+	sigs <- os.Interrupt
+	time.Sleep(10 * time.Millisecond)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+var releaseExportHelp = `
+This command exports the history of a release to an archive.
+
+Every revision of the release is exported with its status, labels and
+timestamps. Use --all to export every release in the namespace.
+
+The archive is a gzipped tarball that can be imported into another cluster or
+storage backend with 'helm release import'. It contains the release records,
+including the values they were installed with, so it may contain secrets.
+`
+
+func newReleaseExportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseExport(cfg)
+	var archive string
+
+	cmd := &cobra.Command{
+		Use:   "export [RELEASE_NAME]",
+		Short: "export the history of releases to an archive",
+		Long:  releaseExportHelp,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 || client.All {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			switch {
+			case client.All && len(args) > 0:
+				return errors.New("a release name cannot be given with --all")
+			case !client.All && len(args) != 1:
+				return errors.New("a release name or --all is required")
+			case !client.All:
+				name = args[0]
+			}
+			if archive == "" {
+				archive = "releases.tgz"
+				if name != "" {
+					archive = name + ".tgz"
+				}
+			}
+
+			f, err := os.OpenFile(archive, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return err
+			}
+			index, err := client.Run(name, f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(archive)
+				return err
+			}
+
+			releases := map[string]bool{}
+			for _, r := range index.Revisions {
+				releases[r.Name] = true
+			}
+			fmt.Fprintf(out, "Exported %d revision(s) of %d release(s) to %s\n", len(index.Revisions), len(releases), archive)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.All, "all", false, "export every release in the namespace")
+	f.StringVarP(&archive, "output", "o", "", "file to write the archive to. Defaults to RELEASE_NAME.tgz, or releases.tgz with --all. Existing files are not overwritten")
+
+	return cmd
+}
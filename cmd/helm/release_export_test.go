@@ -0,0 +1,108 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseExportImportCmd(t *testing.T) {
+	src := storageFixture()
+	for _, rel := range []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "exported", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "exported", Version: 2, Labels: map[string]string{"team": "platform"}}),
+		release.Mock(&release.MockReleaseOptions{Name: "other"}),
+	} {
+		if err := src.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive := filepath.Join(t.TempDir(), "exported.tgz")
+	_, out, err := executeActionCommandC(src, "release export exported -o "+archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("Exported 2 revision(s) of 1 release(s) to %s\n", archive); out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	// The archive is not overwritten
+	if _, _, err := executeActionCommandC(src, "release export exported -o "+archive); err == nil {
+		t.Error("expected an error exporting to an existing file")
+	}
+
+	dst := storageFixture()
+	_, out, err = executeActionCommandC(dst, "release import "+archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("Imported 2 revision(s) of 1 release(s) from %s\n", archive); out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+	rel, err := dst.Get("exported", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Labels["team"] != "platform" {
+		t.Errorf("expected the labels to be imported, got %v", rel.Labels)
+	}
+
+	_, _, err = executeActionCommandC(dst, "release import "+archive)
+	if err == nil || !strings.Contains(err.Error(), "releases already exist in the destination: exported (2 revisions)") {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestReleaseExportCmdErrors(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "export without a release",
+		cmd:       "release export",
+		golden:    "output/release-export-no-args.txt",
+		wantError: true,
+	}, {
+		name:      "export a release with --all",
+		cmd:       "release export foo --all",
+		golden:    "output/release-export-all-with-name.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseImportCmdErrors(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "import without an archive",
+		cmd:       "release import",
+		golden:    "output/release-import-no-args.txt",
+		wantError: true,
+	}, {
+		name:      "import an archive that is not a tarball",
+		cmd:       "release import testdata/testcharts/empty/Chart.yaml",
+		golden:    "output/release-import-invalid.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseExportCompletion(t *testing.T) {
+	checkReleaseCompletion(t, "release export", false)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+var releaseImportHelp = `
+This command imports the history of releases from an archive created by
+'helm release export'.
+
+Every revision is stored as it was exported, with its status, labels and
+timestamps. The resources of the releases are not touched: import the archive
+into the cluster the resources were migrated to.
+
+The checksums of the archive are verified and nothing is imported if any of
+its releases already exists.
+`
+
+func newReleaseImportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseImport(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "import ARCHIVE",
+		Short: "import the history of releases from an archive",
+		Long:  releaseImportHelp,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			client.Namespace = settings.Namespace()
+			rels, err := client.Run(f)
+			if err != nil {
+				return err
+			}
+
+			releases := map[string]bool{}
+			for _, r := range rels {
+				releases[r.Name] = true
+			}
+			verb := "Imported"
+			if client.DryRun {
+				verb = "Would import"
+			}
+			fmt.Fprintf(out, "%s %d revision(s) of %d release(s) from %s\n", verb, len(rels), len(releases), args[0])
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.DryRun, "dry-run", false, "verify the archive and check for conflicts without importing")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of a release if another operation holds it. By default the command fails immediately")
+
+	return cmd
+}
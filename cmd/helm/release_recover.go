@@ -0,0 +1,75 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+var releaseRecoverHelp = `
+This command recovers a release that is stuck in a pending state, such as
+pending-install or pending-upgrade, because the operation was interrupted.
+
+The resources in the cluster are compared with the manifest of the pending
+revision. If they all match, the revision is marked deployed. Otherwise it is
+marked failed, so that the release can be upgraded again. Use --rollback to
+also roll back to the last deployed revision in that case.
+
+The release must not be locked by a running operation.
+`
+
+func newReleaseRecoverCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRecover(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "recover RELEASE_NAME",
+		Short: "recover a release stuck in a pending state",
+		Long:  releaseRecoverHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rel, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Release %q recovered: revision %d is %s\n", rel.Name, rel.Version, rel.Info.Status)
+			fmt.Fprintf(out, "DESCRIPTION: %s\n", rel.Info.Description)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Rollback, "rollback", false, "roll back to the last deployed revision if the pending revision was not fully applied")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks) during the rollback")
+	f.BoolVar(&client.Wait, "wait", false, "if set with --rollback, will wait until all resources are in a ready state after rolling back. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed after rolling back. It will wait for as long as --timeout")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of the release if another operation holds it. By default the command fails immediately")
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseRecoverCmd(t *testing.T) {
+	deployed := release.Mock(&release.MockReleaseOptions{Name: "stuck", Version: 1})
+	pending := release.Mock(&release.MockReleaseOptions{Name: "stuck", Version: 2, Status: release.StatusPendingUpgrade})
+
+	tests := []cmdTestCase{{
+		name:   "recover a pending release",
+		cmd:    "release recover stuck",
+		golden: "output/release-recover.txt",
+		rels:   []*release.Release{deployed, pending},
+	}, {
+		name:      "recover a release that is not pending",
+		cmd:       "release recover stuck",
+		golden:    "output/release-recover-not-pending.txt",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "stuck"})},
+		wantError: true,
+	}, {
+		name:      "recover without args",
+		cmd:       "release recover",
+		golden:    "output/release-recover-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseRecoverCompletion(t *testing.T) {
+	checkReleaseCompletion(t, "release recover", false)
+}
+
+func TestReleaseRecoverFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "release recover", false)
+	checkFileCompletion(t, "release recover myrelease", false)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+var releaseUnlockHelp = `
+This command removes the lock of a release.
+
+Install, upgrade, rollback and uninstall lock the release they operate on, so
+that other operations on the same release fail or wait with --wait-for-lock.
+The lock expires on its own if the process holding it dies, but it can be
+removed immediately with this command.
+
+With the secret and configmap storage drivers, locks are Leases of the
+coordination.k8s.io API group in the namespace of the release, so locking
+requires permission to get, create, update and delete Leases there. If that
+permission is missing, operations run without lock and log a warning.
+
+Only remove the lock of an operation that is no longer running. Otherwise
+other operations will run concurrently with it.
+`
+
+func newReleaseUnlockCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseUnlock(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "unlock RELEASE_NAME",
+		Short: "remove the lock of a release",
+		Long:  releaseUnlockHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Removed lock of release %q held by %s\n", args[0], l.Holder)
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,66 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseUnlockCmd(t *testing.T) {
+	store := storageFixture()
+	if err := store.Locker.AcquireLock("locked", "ci@runner (pid 42)", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	_, out, err := executeActionCommandC(store, "release unlock locked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, out, "output/release-unlock.txt")
+
+	if _, err := store.Locker.GetLock("locked"); err == nil {
+		t.Error("expected the lock to be removed")
+	}
+}
+
+func TestReleaseUnlockCmdErrors(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "unlock a release that is not locked",
+		cmd:       "release unlock not-locked",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "not-locked"})},
+		wantError: true,
+	}, {
+		name:      "unlock without args",
+		cmd:       "release unlock",
+		golden:    "output/release-unlock-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseUnlockCompletion(t *testing.T) {
+	checkReleaseCompletion(t, "release unlock", false)
+}
+
+func TestReleaseUnlockFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "release unlock", false)
+	checkFileCompletion(t, "release unlock myrelease", false)
+}
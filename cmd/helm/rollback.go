@@ -66,7 +66,9 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				client.Version = ver
 			}
 
-			if err := client.Run(args[0]); err != nil {
+			ctx, stop := interruptContext()
+			defer stop()
+			if err := client.RunWithContext(ctx, args[0]); err != nil {
 				return err
 			}
 
@@ -83,8 +85,14 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	bindWaitProgressFlag(f, &client.WaitProgress)
 	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this rollback when rollback fails")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
+	f.BoolVar(&client.ServerSideApply, "server-side", false, "if set, update the resources with server-side apply")
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if --server-side is set, take ownership of fields managed by other field managers")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of the release if another operation holds it. By default the command fails immediately")
+	bindSerialHooksFlag(cmd, cfg)
+	bindKeepHookLogsFlag(cmd, cfg)
 
 	return cmd
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -43,12 +44,16 @@ The status consists of:
 - description of the release (can be completion message or error message, need to enable --show-desc)
 - list of resources that this release consists of, sorted by kind
 - details on last test suite run, if applicable
+- logs and events captured when the hooks last ran, with --show-hook-logs
 - additional notes provided by the chart
+- objects changed in the cluster since they were deployed, with --drift
 `
 
 func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewStatus(cfg)
 	var outfmt output.Format
+	var showDrift bool
+	var showHookLogs bool
 
 	cmd := &cobra.Command{
 		Use:   "status RELEASE_NAME",
@@ -70,7 +75,17 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			// strip chart metadata from the output
 			rel.Chart = nil
 
-			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription})
+			if showDrift {
+				drift := action.NewDrift(cfg)
+				drift.Version = client.Version
+				resources, err := drift.Run(args[0])
+				if err != nil {
+					return err
+				}
+				return outfmt.Write(out, &driftStatusPrinter{statusPrinter{rel, false, client.ShowDescription, showHookLogs}, resources})
+			}
+
+			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription, showHookLogs})
 		},
 	}
 
@@ -91,6 +106,8 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	bindOutputFlag(cmd, &outfmt)
 	f.BoolVar(&client.ShowDescription, "show-desc", false, "if set, display the description message of the named release")
+	f.BoolVar(&showHookLogs, "show-hook-logs", false, "if set, display the container logs and events captured when the hooks of the release last ran")
+	f.BoolVar(&showDrift, "drift", false, "if set, compare the release manifest with the live objects in the cluster and display the differences")
 
 	return cmd
 }
@@ -99,6 +116,7 @@ type statusPrinter struct {
 	release         *release.Release
 	debug           bool
 	showDescription bool
+	showHookLogs    bool
 }
 
 func (s statusPrinter) WriteJSON(out io.Writer) error {
@@ -142,6 +160,10 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 		}
 	}
 
+	if s.showHookLogs {
+		s.writeHookLogs(out)
+	}
+
 	if s.debug {
 		fmt.Fprintln(out, "USER-SUPPLIED VALUES:")
 		err := output.EncodeYAML(out, s.release.Config)
@@ -179,6 +201,85 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 	return nil
 }
 
+func (s statusPrinter) writeHookLogs(out io.Writer) {
+	var ran []*release.Hook
+	for _, h := range s.release.Hooks {
+		if !h.LastRun.StartedAt.IsZero() {
+			ran = append(ran, h)
+		}
+	}
+	if len(ran) == 0 {
+		fmt.Fprintln(out, "HOOK LOGS: None")
+		return
+	}
+	fmt.Fprintln(out, "HOOK LOGS:")
+	for _, h := range ran {
+		events := make([]string, 0, len(h.Events))
+		for _, e := range h.Events {
+			events = append(events, e.String())
+		}
+		fmt.Fprintf(out, "%s/%s (%s):\n", h.Kind, h.Name, strings.Join(events, ", "))
+		writeHookRun(out, h, "  ")
+	}
+}
+
+// driftStatusPrinter prints the status of a release together with the drift
+// of its objects from the release manifest.
+type driftStatusPrinter struct {
+	statusPrinter
+	drift []action.ResourceDrift
+}
+
+type releaseWithDrift struct {
+	*release.Release
+	Drift []action.ResourceDrift `json:"drift"`
+}
+
+func (s driftStatusPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, releaseWithDrift{s.release, s.drift})
+}
+
+func (s driftStatusPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, releaseWithDrift{s.release, s.drift})
+}
+
+func (s driftStatusPrinter) WriteTable(out io.Writer) error {
+	if err := s.statusPrinter.WriteTable(out); err != nil {
+		return err
+	}
+	if len(s.drift) == 0 {
+		fmt.Fprintln(out, "DRIFT: None")
+		return nil
+	}
+	fmt.Fprintln(out, "DRIFT:")
+	for _, d := range s.drift {
+		name := fmt.Sprintf("%s/%s", d.Kind, d.Name)
+		if d.Namespace != "" {
+			name = fmt.Sprintf("%s (namespace %s)", name, d.Namespace)
+		}
+		if d.Missing {
+			fmt.Fprintf(out, "  %s: missing from the cluster\n", name)
+			continue
+		}
+		fmt.Fprintf(out, "  %s:\n", name)
+		for _, f := range d.Fields {
+			fmt.Fprintf(out, "    %s: expected %s, found %s\n", f.Path, driftValue(f.Expected), driftValue(f.Actual))
+		}
+	}
+	return nil
+}
+
+func driftValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
 func executionsByHookEvent(rel *release.Release) map[release.HookEvent][]*release.Hook {
 	result := make(map[release.HookEvent][]*release.Hook)
 	for _, h := range rel.Hooks {
@@ -52,6 +52,13 @@ func TestStatusCmd(t *testing.T) {
 			Status:      release.StatusDeployed,
 			Description: "Mock description",
 		}),
+	}, {
+		name:   "get status of a deployed release, with drift",
+		cmd:    "status --drift flummoxed-chickadee",
+		golden: "output/status-with-drift.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusDeployed,
+		}),
 	}, {
 		name:   "get status of a deployed release with notes",
 		cmd:    "status flummoxed-chickadee",
@@ -108,10 +115,70 @@ func TestStatusCmd(t *testing.T) {
 				},
 			},
 		),
+	}, {
+		name:   "get status of a deployed release with hook logs",
+		cmd:    "status --show-hook-logs flummoxed-chickadee",
+		golden: "output/status-with-hook-logs.txt",
+		rels: releasesMockWithStatus(
+			&release.Info{
+				Status: release.StatusFailed,
+			},
+			&release.Hook{
+				Name:   "never-run-hook",
+				Kind:   "Job",
+				Events: []release.HookEvent{release.HookPostUpgrade},
+			},
+			hookWithLogs(),
+		),
+	}, {
+		name:   "get status of a deployed release without hook runs",
+		cmd:    "status --show-hook-logs flummoxed-chickadee",
+		golden: "output/status-without-hook-logs.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusDeployed,
+		}),
 	}}
 	runTestCmd(t, tests)
 }
 
+// hookWithLogs returns a failed pre-upgrade hook with captured logs and events.
+func hookWithLogs() *release.Hook {
+	return &release.Hook{
+		Name:   "migrate",
+		Kind:   "Job",
+		Path:   "templates/migrate.yaml",
+		Events: []release.HookEvent{release.HookPreUpgrade},
+		Manifest: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate`,
+		LastRun: release.HookExecution{
+			StartedAt:   mustParseTime("2006-01-02T15:04:05Z"),
+			CompletedAt: mustParseTime("2006-01-02T15:06:07Z"),
+			Phase:       release.HookPhaseFailed,
+			Logs: []release.HookContainerLog{{
+				Pod:       "migrate-x7k2p",
+				Container: "migrate",
+				Log:       "applying migration 0042\nerror: relation \"users\" already exists\n",
+				Truncated: true,
+			}},
+			Events: []release.HookObjectEvent{{
+				Object:  "Pod/migrate-x7k2p",
+				Type:    "Warning",
+				Reason:  "BackOff",
+				Message: "Back-off restarting failed container",
+				Count:   3,
+			}, {
+				Object:  "Job/migrate",
+				Type:    "Warning",
+				Reason:  "BackoffLimitExceeded",
+				Message: "Job has reached the specified backoff limit",
+				Count:   1,
+			}},
+		},
+	}
+}
+
 func mustParseTime(t string) helmtime.Time {
 	res, _ := helmtime.Parse(time.RFC3339, t)
 	return res
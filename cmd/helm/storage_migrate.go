@@ -0,0 +1,140 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+var storageMigrateHelp = `
+This command copies the records of all releases in a namespace from one
+storage backend to another, for example when switching HELM_DRIVER from
+"secret" to "sql":
+
+    $ helm storage migrate --from secret --to sql
+
+The drivers are configured like HELM_DRIVER, and the "sql" driver reads its
+connection string from HELM_DRIVER_SQL_CONNECTION_STRING.
+
+Releases are copied one at a time, with every revision, status, label and
+timestamp. Each revision is read back from the destination and its checksum
+compared with the source. Nothing is copied if any release already exists in
+the destination. The source is not modified.
+`
+
+func newStorageMigrateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewStorageMigrate(cfg)
+	var from, to string
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "migrate --from DRIVER --to DRIVER",
+		Short: "copy release records from one storage backend to another",
+		Long:  storageMigrateHelp,
+		Args:  require.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || to == "" {
+				return errors.New("both --from and --to are required")
+			}
+			if from == to {
+				return errors.Errorf("the source and destination drivers are both %q", from)
+			}
+
+			var err error
+			getter := settings.RESTClientGetter()
+			if client.From, err = action.NewStorage(getter, settings.Namespace(), from, debug); err != nil {
+				return err
+			}
+			if client.To, err = action.NewStorage(getter, settings.Namespace(), to, debug); err != nil {
+				return err
+			}
+
+			migrated, err := client.Run()
+			if err != nil {
+				if len(migrated) > 0 {
+					fmt.Fprintf(out, "Migrated %d revision(s) before failing\n", len(migrated))
+				}
+				return err
+			}
+			return outfmt.Write(out, &migrateWriter{migrated, client.DryRun})
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&from, "from", "", "the storage driver to read releases from: secret, configmap or sql")
+	f.StringVar(&to, "to", "", "the storage driver to write releases to: secret, configmap or sql")
+	f.BoolVar(&client.DryRun, "dry-run", false, "check for conflicts and list the revisions that would be migrated")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of a release if another operation holds it. By default the command fails immediately")
+	bindOutputFlag(cmd, &outfmt)
+
+	drivers := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"secret", "configmap", "sql"}, cobra.ShellCompDirectiveNoFileComp
+	}
+	cmd.RegisterFlagCompletionFunc("from", drivers)
+	cmd.RegisterFlagCompletionFunc("to", drivers)
+
+	return cmd
+}
+
+type migrateWriter struct {
+	migrated []action.ReleaseArchiveEntry
+	dryRun   bool
+}
+
+func (w *migrateWriter) WriteTable(out io.Writer) error {
+	if len(w.migrated) == 0 {
+		fmt.Fprintln(out, "No releases to migrate")
+		return nil
+	}
+	table := uitable.New()
+	table.AddRow("NAME", "NAMESPACE", "REVISION", "STATUS", "DIGEST")
+	for _, r := range w.migrated {
+		table.AddRow(r.Name, r.Namespace, r.Version, r.Status, r.Digest)
+	}
+	if err := output.EncodeTable(out, table); err != nil {
+		return err
+	}
+	verb := "Migrated"
+	if w.dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Fprintf(out, "%s %d revision(s)\n", verb, len(w.migrated))
+	return nil
+}
+
+func (w *migrateWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.entries())
+}
+
+func (w *migrateWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.entries())
+}
+
+func (w *migrateWriter) entries() []action.ReleaseArchiveEntry {
+	if w.migrated == nil {
+		return []action.ReleaseArchiveEntry{}
+	}
+	return w.migrated
+}
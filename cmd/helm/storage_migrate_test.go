@@ -0,0 +1,44 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestStorageMigrateCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "migrate an empty storage",
+		cmd:    "storage migrate --from memory --to configmap --dry-run",
+		golden: "output/storage-migrate-empty.txt",
+	}, {
+		name:      "migrate without drivers",
+		cmd:       "storage migrate --from secret",
+		golden:    "output/storage-migrate-no-driver.txt",
+		wantError: true,
+	}, {
+		name:      "migrate to an unknown driver",
+		cmd:       "storage migrate --from memory --to etcd",
+		golden:    "output/storage-migrate-unknown-driver.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageMigrateOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "storage migrate")
+}
@@ -0,0 +1,139 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var storageReencryptHelp = `
+This command rewrites the records of all releases in a namespace, so that they
+are encrypted with the current encryption key.
+
+The records of releases are encrypted by the "secret", "configmap" and "sql"
+drivers if HELM_DRIVER_ENCRYPTION_KEY_FILE or HELM_DRIVER_ENCRYPTION_COMMAND
+is set. The key file lists AES keys, the first of which encrypts while all of
+them decrypt:
+
+    keys:
+    - id: 2021-10
+      secret: <base64 encoded 32 bytes key>
+    - id: 2021-01
+      secret: <base64 encoded 32 bytes key>
+
+The command, with its arguments quoted like in a shell, is run with "key-id"
+to print the ID of the key to encrypt with, and with "encrypt" or "decrypt"
+and a key ID to encrypt or decrypt its standard input, for instance with a key
+management service.
+
+Records that were stored before encryption was enabled can still be read.
+Run this command after enabling encryption, or after adding a new key at the
+top of the key file, to encrypt every record with the current key. The
+previous keys can then be removed from the key file.
+`
+
+func newStorageReencryptCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewStorageReencrypt(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "encrypt release records with the current encryption key",
+		Long:  storageReencryptHelp,
+		Args:  require.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rewritten, err := client.Run()
+			if err != nil {
+				if len(rewritten) > 0 && !client.DryRun {
+					fmt.Fprintf(out, "Re-encrypted %d revision(s) before failing\n", len(rewritten))
+				}
+				return err
+			}
+			return outfmt.Write(out, &reencryptWriter{rewritten, client.DryRun})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the revisions that would be re-encrypted without writing them")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of a release if another operation holds it. By default the command fails immediately")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type reencryptedRevision struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	Status    string `json:"status"`
+}
+
+type reencryptWriter struct {
+	rewritten []*release.Release
+	dryRun    bool
+}
+
+func (w *reencryptWriter) WriteTable(out io.Writer) error {
+	if len(w.rewritten) == 0 {
+		fmt.Fprintln(out, "No releases to re-encrypt")
+		return nil
+	}
+	table := uitable.New()
+	table.AddRow("NAME", "NAMESPACE", "REVISION", "STATUS")
+	for _, r := range w.revisions() {
+		table.AddRow(r.Name, r.Namespace, r.Revision, r.Status)
+	}
+	if err := output.EncodeTable(out, table); err != nil {
+		return err
+	}
+	verb := "Re-encrypted"
+	if w.dryRun {
+		verb = "Would re-encrypt"
+	}
+	fmt.Fprintf(out, "%s %d revision(s)\n", verb, len(w.rewritten))
+	return nil
+}
+
+func (w *reencryptWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.revisions())
+}
+
+func (w *reencryptWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.revisions())
+}
+
+func (w *reencryptWriter) revisions() []reencryptedRevision {
+	revisions := []reencryptedRevision{}
+	for _, r := range w.rewritten {
+		revisions = append(revisions, reencryptedRevision{
+			Name:      r.Name,
+			Namespace: r.Namespace,
+			Revision:  r.Version,
+			Status:    r.Info.Status.String(),
+		})
+	}
+	return revisions
+}
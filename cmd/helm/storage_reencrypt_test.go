@@ -0,0 +1,52 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestStorageReencryptCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "apple", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "apple", Version: 2}),
+		release.Mock(&release.MockReleaseOptions{Name: "banana", Version: 1}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "re-encrypt the releases",
+		cmd:    "storage reencrypt",
+		golden: "output/storage-reencrypt.txt",
+		rels:   rels,
+	}, {
+		name:   "re-encrypt the releases with a dry run",
+		cmd:    "storage reencrypt --dry-run -o json",
+		golden: "output/storage-reencrypt-dry-run.json",
+		rels:   rels,
+	}, {
+		name:   "re-encrypt an empty storage",
+		cmd:    "storage reencrypt",
+		golden: "output/storage-reencrypt-empty.txt",
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageReencryptOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "storage reencrypt")
+}
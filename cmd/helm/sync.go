@@ -0,0 +1,218 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+const syncDesc = `
+This command installs or upgrades the releases listed in a release set file.
+
+Each release names a chart, and optionally a chart version, a namespace,
+values files, values, labels, and the releases it needs:
+
+    releases:
+    - name: db
+      chart: bitnami/postgresql
+      version: ~10.3
+      labels:
+        tier: data
+    - name: app
+      chart: ./charts/app
+      values:
+      - values/app.yaml
+      set:
+        image:
+          tag: v2
+      needs:
+      - db
+
+Relative paths of charts and values files are relative to the release set
+file. A release without a namespace is in the namespace of the command, and
+releases of other namespaces are needed as 'namespace/name'.
+
+Releases that do not exist are installed, and releases whose chart, values or
+rendered manifest differ from their last revision are upgraded. Each release
+is synced once the releases it needs are synced, concurrently with the other
+releases, and skipped if any of them fails. Use '--wait' so that the releases
+a release needs are ready, and not only deployed, before it is synced.
+
+Use '--selector' to sync some of the releases. The name and namespace of the
+releases can be selected as the 'name' and 'namespace' labels:
+
+    $ helm sync -f releases.yaml -l tier=data
+    $ helm sync -f releases.yaml -l 'name in (app)' --dry-run
+`
+
+func newSyncCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewSync(cfg)
+	var filename string
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "sync -f RELEASE_SET",
+		Short: "install or upgrade the releases of a release set",
+		Long:  syncDesc,
+		Args:  require.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if filename == "" {
+				return errors.New("a release set is required: use --file")
+			}
+			set, err := action.LoadReleaseSet(filename)
+			if err != nil {
+				return err
+			}
+
+			client.Settings = settings
+			client.Namespace = settings.Namespace()
+			for _, r := range set.Releases {
+				if r.Namespace != "" && r.Namespace != client.Namespace {
+					client.ConfigFor = syncConfig
+					break
+				}
+			}
+
+			ctx, stop := interruptContext()
+			defer stop()
+			results, err := client.Run(ctx, set)
+			if results == nil {
+				return err
+			}
+			if werr := outfmt.Write(out, &syncWriter{results, client.DryRun}); werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&filename, "file", "f", "", "release set file listing the releases to sync")
+	f.StringVarP(&client.Selector, "selector", "l", "", "label selector of the releases to sync, e.g. tier=data. Releases they need are not synced")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report what would be done to each release without doing it")
+	f.IntVar(&client.Concurrency, "concurrency", 4, "maximum number of releases synced at the same time. Use 0 for no limit")
+	f.BoolVar(&client.CreateNamespace, "create-namespace", false, "create the namespace of the releases that are installed if not present")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until the resources of each release are in a ready state before syncing the releases that need it. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs of each release have been completed. It will wait for as long as --timeout")
+	f.BoolVar(&client.Atomic, "atomic", false, "if set, a release that fails to sync is rolled back, or uninstalled if it was being installed. The --wait flag will be set automatically if --atomic is used")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of a release if another operation holds it. By default the release fails immediately")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+// syncConfig returns the configuration of the releases of a namespace.
+func syncConfig(namespace string) (*action.Configuration, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debug); err != nil {
+		return nil, err
+	}
+	if kc, ok := cfg.KubeClient.(*kube.Client); ok {
+		kc.Namespace = namespace
+	}
+	return cfg, nil
+}
+
+type syncedRelease struct {
+	Name      string               `json:"name"`
+	Namespace string               `json:"namespace"`
+	Operation action.SyncOperation `json:"operation"`
+	Revision  int                  `json:"revision,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+type syncWriter struct {
+	results []action.SyncResult
+	dryRun  bool
+}
+
+func (w *syncWriter) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("NAME", "NAMESPACE", "OPERATION", "REVISION", "RESULT")
+	counts := map[action.SyncOperation]int{}
+	failed := 0
+	for _, r := range w.releases() {
+		result := "ok"
+		switch {
+		case r.Error != "":
+			result = r.Error
+		case w.dryRun && r.Operation != action.SyncUnchanged:
+			result = "dry run"
+		}
+		revision := ""
+		if r.Revision > 0 {
+			revision = fmt.Sprint(r.Revision)
+		}
+		table.AddRow(r.Name, r.Namespace, r.Operation, revision, result)
+
+		if r.Error != "" && r.Operation != action.SyncSkipped {
+			failed++
+		} else {
+			counts[r.Operation]++
+		}
+	}
+	if err := output.EncodeTable(out, table); err != nil {
+		return err
+	}
+	prefix := ""
+	if w.dryRun {
+		prefix = "Dry run: "
+	}
+	fmt.Fprintf(out, "%s%d installed, %d upgraded, %d unchanged, %d skipped, %d failed\n", prefix,
+		counts[action.SyncInstall], counts[action.SyncUpgrade], counts[action.SyncUnchanged], counts[action.SyncSkipped], failed)
+	return nil
+}
+
+func (w *syncWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.releases())
+}
+
+func (w *syncWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.releases())
+}
+
+func (w *syncWriter) releases() []syncedRelease {
+	releases := []syncedRelease{}
+	for _, r := range w.results {
+		s := syncedRelease{
+			Name:      r.Name,
+			Namespace: r.Namespace,
+			Operation: r.Operation,
+		}
+		if r.Release != nil && r.Err == nil {
+			s.Revision = r.Release.Version
+		}
+		if r.Err != nil {
+			s.Error = r.Err.Error()
+		}
+		releases = append(releases, s)
+	}
+	return releases
+}
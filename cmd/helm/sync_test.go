@@ -0,0 +1,62 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestSyncCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "back", Version: 1}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "sync a release set",
+		cmd:    "sync -f testdata/releaseset/releases.yaml",
+		golden: "output/sync.txt",
+		rels:   rels,
+	}, {
+		name:   "sync a release set with a dry run",
+		cmd:    "sync -f testdata/releaseset/releases.yaml --dry-run",
+		golden: "output/sync-dry-run.txt",
+		rels:   rels,
+	}, {
+		name:   "sync the selected releases of a release set",
+		cmd:    "sync -f testdata/releaseset/releases.yaml -l tier=data",
+		golden: "output/sync-selector.txt",
+		rels:   rels,
+	}, {
+		name:   "sync a release set with JSON output",
+		cmd:    "sync -f testdata/releaseset/releases.yaml -o json",
+		golden: "output/sync.json",
+		rels:   rels,
+	}, {
+		name:      "sync a release set with a dependency cycle",
+		cmd:       "sync -f testdata/releaseset/cycle.yaml",
+		golden:    "output/sync-cycle.txt",
+		wantError: true,
+	}, {
+		name:      "sync without a release set",
+		cmd:       "sync",
+		golden:    "output/sync-no-file.txt",
+		wantError: true,
+	}}
+	runTestActionCmd(t, tests)
+}
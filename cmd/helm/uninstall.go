@@ -51,9 +51,11 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := interruptContext()
+			defer stop()
 			for i := 0; i < len(args); i++ {
 
-				res, err := client.Run(args[i])
+				res, err := client.RunWithContext(ctx, args[i])
 				if err != nil {
 					return err
 				}
@@ -73,6 +75,11 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.KeepHistory, "keep-history", false, "remove all associated resources and mark the release as deleted, but retain the release history")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all the deleted resources are gone, including the ones held by finalizers, before uninstalling the release. It will wait for as long as --timeout")
+	f.StringVar(&client.DeletionPropagation, "cascade", "background", "how to delete the dependents of the deleted resources, like the pods of a Deployment. Must be \"background\", \"foreground\" or \"orphan\"")
+	f.DurationVar(&client.WaitForLock, "wait-for-lock", 0, "time to wait for the lock of the release if another operation holds it. By default the command fails immediately")
+	bindSerialHooksFlag(cmd, cfg)
+	bindKeepHookLogsFlag(cmd, cfg)
 
 	return cmd
 }
@@ -57,6 +57,19 @@ func TestUninstall(t *testing.T) {
 			golden: "output/uninstall-keep-history.txt",
 			rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
 		},
+		{
+			name:   "uninstall with wait",
+			cmd:    "uninstall aeneas --wait",
+			golden: "output/uninstall-wait.txt",
+			rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+		},
+		{
+			name:      "uninstall with invalid cascade",
+			cmd:       "uninstall aeneas --cascade sideways",
+			golden:    "output/uninstall-invalid-cascade.txt",
+			rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+			wantError: true,
+		},
 		{
 			name:      "uninstall without release",
 			cmd:       "uninstall",
@@ -0,0 +1,49 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// newWaitProgressPrinter returns a kube.ProgressFunc that prints the resources
+// that are not ready yet, and why, whenever they change.
+func newWaitProgressPrinter(out io.Writer) kube.ProgressFunc {
+	var last string
+	return func(p kube.WaitProgress) {
+		var b strings.Builder
+		for _, r := range p.Pending() {
+			fmt.Fprintf(&b, "  %s: %s\n", r.Object, r.Reason)
+		}
+		if b.String() == last {
+			return
+		}
+		last = b.String()
+
+		elapsed := p.Elapsed.Round(time.Second)
+		if p.Ready == len(p.Resources) {
+			fmt.Fprintf(out, "All %d resources are ready after %s\n", len(p.Resources), elapsed)
+			return
+		}
+		fmt.Fprintf(out, "Waiting for %d of %d resources (%s of %s elapsed):\n%s", len(p.Resources)-p.Ready, len(p.Resources), elapsed, p.Timeout, last)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+func TestWaitProgressPrinter(t *testing.T) {
+	var out bytes.Buffer
+	printProgress := newWaitProgressPrinter(&out)
+
+	pending := kube.WaitProgress{
+		Resources: []kube.ResourceStatus{
+			{Object: "Deployment/api", Reason: "2/3 replicas ready"},
+			{Object: "PersistentVolumeClaim/data", Reason: "Pending: no storage class is set"},
+			{Object: "Service/api", Ready: true},
+		},
+		Ready:   1,
+		Elapsed: 2100 * time.Millisecond,
+		Timeout: 5 * time.Minute,
+	}
+	printProgress(pending)
+	// Nothing changed, nothing is printed
+	pending.Elapsed = 4 * time.Second
+	printProgress(pending)
+
+	printProgress(kube.WaitProgress{
+		Resources: []kube.ResourceStatus{
+			{Object: "Deployment/api", Ready: true},
+			{Object: "PersistentVolumeClaim/data", Ready: true},
+			{Object: "Service/api", Ready: true},
+		},
+		Ready:   3,
+		Elapsed: 6 * time.Second,
+		Timeout: 5 * time.Minute,
+	})
+
+	expected := `Waiting for 2 of 3 resources (2s of 5m0s elapsed):
+  Deployment/api: 2/3 replicas ready
+  PersistentVolumeClaim/data: Pending: no storage class is set
+All 3 resources are ready after 6s
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestWaitProgressFlag(t *testing.T) {
+	var progress kube.ProgressFunc
+	f := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	bindWaitProgressFlag(f, &progress)
+	if err := f.Parse([]string{"--wait-progress"}); err != nil {
+		t.Fatal(err)
+	}
+	if progress == nil {
+		t.Error("expected --wait-progress to set the progress printer")
+	}
+	if err := f.Parse([]string{"--wait-progress=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if progress != nil {
+		t.Error("expected --wait-progress=false to unset the progress printer")
+	}
+}
@@ -18,13 +18,16 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
+	shellwords "github.com/mattn/go-shellwords"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -59,7 +62,7 @@ var (
 	// errInvalidRevision indicates that an invalid release revision number was provided.
 	errInvalidRevision = errors.New("invalid release revision")
 	// errPending indicates that another instance of Helm is already applying an operation on a release.
-	errPending = errors.New("another operation (install/upgrade/rollback) is in progress")
+	errPending = errors.New("another operation (install/upgrade/rollback) is in progress. If it was interrupted, use 'helm release recover' to recover the release")
 )
 
 // ValidName is a regular expression for resource names.
@@ -95,6 +98,26 @@ type Configuration struct {
 	Capabilities *chartutil.Capabilities
 
 	Log func(string, ...interface{})
+
+	// SerialHooks runs hooks one at a time. By default, hooks with the same
+	// weight and kind run concurrently.
+	SerialHooks bool
+
+	// KeepHookOutput keeps the container logs and events of the hooks that
+	// succeed too. By default, only the output of failed hooks is kept.
+	KeepHookOutput bool
+
+	// Observer, if set, receives the events of the actions, see Event.
+	Observer Observer
+	// observerMu delivers the events to Observer one at a time.
+	observerMu sync.Mutex
+
+	// lockMu guards the release locks held by this configuration.
+	lockMu     sync.Mutex
+	locks      map[string]*heldLock
+	lockHolder string
+	// lockDisabled is set once the storage turned out not to allow locking.
+	lockDisabled bool
 }
 
 // renderResources renders the templates in a chart
@@ -356,35 +379,87 @@ func GetVersionSet(client discovery.ServerResourcesInterface) (chartutil.Version
 
 // recordRelease with an update operation in case reuse has been set.
 func (cfg *Configuration) recordRelease(r *release.Release) {
-	if err := cfg.Releases.Update(r); err != nil {
+	if err := cfg.updateRelease(r); err != nil {
 		cfg.Log("warning: Failed to update release %s: %s", r.Name, err)
 	}
 }
 
-// Init initializes the action configuration
-func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namespace, helmDriver string, log DebugLog) error {
+// createResources creates the resources in the cluster. If the KubeClient
+// implements kube.InterfaceContext, the creation stops when ctx is done.
+func (cfg *Configuration) createResources(ctx context.Context, resources kube.ResourceList) (*kube.Result, error) {
+	if kubeClient, ok := cfg.KubeClient.(kube.InterfaceContext); ok {
+		return kubeClient.CreateWithContext(ctx, resources)
+	}
+	return cfg.KubeClient.Create(resources)
+}
+
+// updateResources updates the resources in the cluster from original to target.
+// If serverSide is true, server-side apply is used, which requires a KubeClient
+// implementing kube.InterfaceServerSideApply. Otherwise, if the KubeClient
+// implements kube.InterfaceContext, the update stops when ctx is done.
+func (cfg *Configuration) updateResources(ctx context.Context, original, target kube.ResourceList, force, serverSide, forceConflicts bool) (*kube.Result, error) {
+	if !serverSide {
+		if kubeClient, ok := cfg.KubeClient.(kube.InterfaceContext); ok {
+			return kubeClient.UpdateWithContext(ctx, original, target, force)
+		}
+		return cfg.KubeClient.Update(original, target, force)
+	}
+	if force {
+		return &kube.Result{}, errors.New("force replacement cannot be combined with server-side apply, use force-conflicts instead")
+	}
+	kubeClient, ok := cfg.KubeClient.(kube.InterfaceServerSideApply)
+	if !ok {
+		return &kube.Result{}, errors.Errorf("server-side apply is not supported by the kubernetes client %T", cfg.KubeClient)
+	}
+	return kubeClient.UpdateServerSide(original, target, forceConflicts)
+}
+
+func newLeases(lc *lazyClient, log DebugLog) *driver.Leases {
+	l := driver.NewLeases(newLeaseClient(lc))
+	l.Log = log
+	return l
+}
+
+// NewStorage creates the release storage for the named driver ("secret",
+// "configmap", "memory" or "sql"), scoped to namespace. An empty namespace
+// means all namespaces.
+func NewStorage(getter genericclioptions.RESTClientGetter, namespace, helmDriver string, log DebugLog) (*storage.Storage, error) {
 	kc := kube.New(getter)
 	kc.Log = log
+	return newStorage(kc, nil, namespace, helmDriver, log)
+}
 
+// newStorage creates the release storage for the named driver. If the driver is
+// "memory" and current is backed by a memory driver, that driver is re-used.
+func newStorage(kc *kube.Client, current *storage.Storage, namespace, helmDriver string, log DebugLog) (*storage.Storage, error) {
 	lazyClient := &lazyClient{
 		namespace: namespace,
 		clientFn:  kc.Factory.KubernetesClientSet,
 	}
 
+	enc, err := newEncrypter()
+	if err != nil {
+		return nil, err
+	}
+
 	var store *storage.Storage
 	switch helmDriver {
 	case "secret", "secrets", "":
 		d := driver.NewSecrets(newSecretClient(lazyClient))
 		d.Log = log
+		d.Encrypter = enc
 		store = storage.Init(d)
+		store.Locker = newLeases(lazyClient, log)
 	case "configmap", "configmaps":
 		d := driver.NewConfigMaps(newConfigMapClient(lazyClient))
 		d.Log = log
+		d.Encrypter = enc
 		store = storage.Init(d)
+		store.Locker = newLeases(lazyClient, log)
 	case "memory":
 		var d *driver.Memory
-		if cfg.Releases != nil {
-			if mem, ok := cfg.Releases.Driver.(*driver.Memory); ok {
+		if current != nil {
+			if mem, ok := current.Driver.(*driver.Memory); ok {
 				// This function can be called more than once (e.g., helm list --all-namespaces).
 				// If a memory driver was already initialized, re-use it but set the possibly new namespace.
 				// We re-use it in case some releases where already created in the existing memory driver.
@@ -403,12 +478,51 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 			namespace,
 		)
 		if err != nil {
-			panic(fmt.Sprintf("Unable to instantiate SQL driver: %v", err))
+			return nil, errors.Wrap(err, "unable to instantiate SQL driver")
 		}
+		d.Encrypter = enc
 		store = storage.Init(d)
 	default:
+		return nil, errors.Errorf("unknown driver %q", helmDriver)
+	}
+	return store, nil
+}
+
+// newEncrypter returns the encrypter of the stored releases configured by
+// HELM_DRIVER_ENCRYPTION_KEY_FILE or HELM_DRIVER_ENCRYPTION_COMMAND, or nil if
+// the releases are not encrypted.
+func newEncrypter() (driver.Encrypter, error) {
+	keyFile := os.Getenv("HELM_DRIVER_ENCRYPTION_KEY_FILE")
+	// The command is quoted like in a shell, so that its path and arguments
+	// can contain spaces.
+	command, err := shellwords.Parse(os.Getenv("HELM_DRIVER_ENCRYPTION_COMMAND"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse HELM_DRIVER_ENCRYPTION_COMMAND")
+	}
+	switch {
+	case keyFile != "" && len(command) > 0:
+		return nil, errors.New("HELM_DRIVER_ENCRYPTION_KEY_FILE and HELM_DRIVER_ENCRYPTION_COMMAND cannot both be set")
+	case keyFile != "":
+		enc, err := driver.LoadKeyFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	case len(command) > 0:
+		return &driver.ExecEncrypter{Command: command[0], Args: command[1:]}, nil
+	}
+	return nil, nil
+}
+
+// Init initializes the action configuration
+func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namespace, helmDriver string, log DebugLog) error {
+	kc := kube.New(getter)
+	kc.Log = log
+
+	store, err := newStorage(kc, cfg.Releases, namespace, helmDriver, log)
+	if err != nil {
 		// Not sure what to do here.
-		panic("Unknown driver in HELM_DRIVER: " + helmDriver)
+		panic(fmt.Sprintf("Unable to initialize HELM_DRIVER %q: %v", helmDriver, err))
 	}
 
 	cfg.RESTClientGetter = getter
@@ -102,6 +102,25 @@ metadata:
 data:
   name: value`
 
+var manifestWithFailureHook = `kind: ConfigMap
+metadata:
+  name: failure-cm
+  annotations:
+    "helm.sh/hook": post-install-failure,post-upgrade-failure,post-rollback-failure
+    "helm.sh/hook-delete-policy": hook-succeeded
+data:
+  reason: {{ .Release.FailureReason | quote }}
+`
+
+var manifestWithConditionalFailureHook = `{{- if .Release.FailureReason }}
+kind: ConfigMap
+metadata:
+  name: failure-notification
+  annotations:
+    "helm.sh/hook": post-install-failure
+{{- end }}
+`
+
 var manifestWithTestHook = `kind: Pod
   metadata:
 	name: finding-nemo,
@@ -220,6 +239,15 @@ func withMetadataDependency(dependency chart.Dependency) chartOption {
 	}
 }
 
+func withFailureHooks() chartOption {
+	return func(opts *chartOptions) {
+		opts.Templates = append(opts.Templates,
+			&chart.File{Name: "templates/failure-hook", Data: []byte(manifestWithFailureHook)},
+			&chart.File{Name: "templates/conditional-failure-hook", Data: []byte(manifestWithConditionalFailureHook)},
+		)
+	}
+}
+
 func withSampleTemplates() chartOption {
 	return func(opts *chartOptions) {
 		sampleTemplates := []*chart.File{
@@ -319,3 +347,28 @@ func TestGetVersionSet(t *testing.T) {
 		t.Error("Non-existent version is reported found.")
 	}
 }
+
+func TestNewEncrypterCommand(t *testing.T) {
+	defer os.Unsetenv("HELM_DRIVER_ENCRYPTION_COMMAND")
+	os.Setenv("HELM_DRIVER_ENCRYPTION_COMMAND", `"/opt/key service/helm-kms" --key-ring 'release keys'`)
+
+	enc, err := newEncrypter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	execEnc, ok := enc.(*driver.ExecEncrypter)
+	if !ok {
+		t.Fatalf("Expected an ExecEncrypter, got %T", enc)
+	}
+	if execEnc.Command != "/opt/key service/helm-kms" {
+		t.Errorf("Expected the quoted path of the command, got %q", execEnc.Command)
+	}
+	if len(execEnc.Args) != 2 || execEnc.Args[0] != "--key-ring" || execEnc.Args[1] != "release keys" {
+		t.Errorf("Expected the quoted arguments of the command, got %q", execEnc.Args)
+	}
+
+	os.Setenv("HELM_DRIVER_ENCRYPTION_COMMAND", `"/opt/key service/helm-kms`)
+	if _, err := newEncrypter(); err == nil {
+		t.Error("Expected an error for an unterminated quote")
+	}
+}
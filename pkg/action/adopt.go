@@ -0,0 +1,201 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// AdoptedResource is an existing resource whose ownership is taken by a release.
+type AdoptedResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// PreviousRelease and PreviousReleaseNamespace identify the release that
+	// owned the resource. They are empty if the resource was not owned by a release.
+	PreviousRelease          string `json:"previousRelease,omitempty"`
+	PreviousReleaseNamespace string `json:"previousReleaseNamespace,omitempty"`
+}
+
+// ResourcesToAdopt returns the resources in the manifest of rel that already
+// exist in the cluster and are not owned by rel. These are the resources whose
+// ownership is taken when installing or upgrading with TakeOwnership.
+func ResourcesToAdopt(cfg *Configuration, rel *release.Release) ([]AdoptedResource, error) {
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+
+	var adopted []AdoptedResource
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		existing, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return errors.Wrapf(err, "could not get information about %s", resourceString(info))
+		}
+		if checkOwnership(existing, rel.Name, rel.Namespace) == nil {
+			return nil
+		}
+
+		annos, err := accessor.Annotations(existing)
+		if err != nil {
+			return err
+		}
+		adopted = append(adopted, AdoptedResource{
+			Kind:                     info.Mapping.GroupVersionKind.Kind,
+			Name:                     info.Name,
+			Namespace:                info.Namespace,
+			PreviousRelease:          annos[helmReleaseNameAnnotation],
+			PreviousReleaseNamespace: annos[helmReleaseNamespaceAnnotation],
+		})
+		return nil
+	})
+	return adopted, err
+}
+
+// Adopt is the action for creating a release from resources that already
+// exist in the cluster, such as workloads that were deployed by hand.
+//
+// It provides the implementation of 'helm adopt'.
+type Adopt struct {
+	cfg *Configuration
+
+	ChartPathOptions
+
+	ReleaseName string
+	Namespace   string
+	// DryRun checks that the chart matches the cluster and reports the
+	// resources that would be adopted, without changing anything.
+	DryRun      bool
+	Description string
+	Labels      map[string]string
+	// WaitForLock is how long to wait for the release lock if another operation holds it
+	WaitForLock time.Duration
+
+	// drift returns the resources of a release that differ from its manifest
+	drift func(rel *release.Release) ([]ResourceDrift, error)
+}
+
+// NewAdopt creates a new Adopt object with the given configuration.
+func NewAdopt(cfg *Configuration) *Adopt {
+	return &Adopt{
+		cfg:   cfg,
+		drift: NewDrift(cfg).compare,
+	}
+}
+
+// Run renders the chart and checks that every resource it produces already
+// exists in the cluster and matches the chart output. It then takes ownership
+// of the resources and records them as the first revision of a new release.
+// No hooks are run and no resource is created.
+//
+// It returns the release and the resources whose ownership was taken.
+func (a *Adopt) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.Release, []AdoptedResource, error) {
+	if err := a.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, nil, err
+	}
+	if err := chartutil.ValidateReleaseName(a.ReleaseName); err != nil {
+		return nil, nil, errors.Errorf("release name is invalid: %s", a.ReleaseName)
+	}
+
+	if !a.DryRun {
+		unlock, err := a.cfg.lockRelease(a.ReleaseName, a.WaitForLock)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer unlock()
+	}
+
+	if h, err := a.cfg.Releases.History(a.ReleaseName); err == nil && len(h) > 0 {
+		return nil, nil, errors.Errorf("cannot adopt resources into release %q: the release already exists", a.ReleaseName)
+	}
+
+	// Render the release without touching the cluster
+	rel, err := a.install(true).Run(chrt, vals)
+	if err != nil {
+		return rel, nil, err
+	}
+
+	drifts, err := a.drift(rel)
+	if err != nil {
+		return rel, nil, errors.Wrap(err, "unable to compare the chart with the cluster")
+	}
+	if len(drifts) > 0 {
+		return rel, nil, mismatchError(drifts)
+	}
+
+	adopted, err := ResourcesToAdopt(a.cfg, rel)
+	if err != nil || a.DryRun {
+		return rel, adopted, err
+	}
+
+	rel, err = a.install(false).Run(chrt, vals)
+	return rel, adopted, err
+}
+
+// install returns the Install action used to render and record the release.
+func (a *Adopt) install(dryRun bool) *Install {
+	inst := NewInstall(a.cfg)
+	inst.ChartPathOptions = a.ChartPathOptions
+	inst.ReleaseName = a.ReleaseName
+	inst.Namespace = a.Namespace
+	inst.Labels = a.Labels
+	inst.DryRun = dryRun
+	inst.TakeOwnership = true
+	inst.DisableHooks = true
+	inst.SkipCRDs = true
+	inst.Description = a.Description
+	if inst.Description == "" {
+		inst.Description = "Adopted existing resources"
+	}
+	return inst
+}
+
+// mismatchError describes the resources that do not match the chart output.
+func mismatchError(drifts []ResourceDrift) error {
+	msgs := make([]string, 0, len(drifts))
+	for _, d := range drifts {
+		if d.Missing {
+			msgs = append(msgs, fmt.Sprintf("%s %q does not exist", d.Kind, d.Name))
+			continue
+		}
+		paths := make([]string, 0, len(d.Fields))
+		for _, f := range d.Fields {
+			paths = append(paths, f.Path)
+		}
+		msgs = append(msgs, fmt.Sprintf("%s %q differs in %s", d.Kind, d.Name, strings.Join(paths, ", ")))
+	}
+	return errors.Errorf("the chart does not match the resources in the cluster: %s", strings.Join(msgs, "; "))
+}
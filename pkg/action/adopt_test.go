@@ -0,0 +1,199 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// buildingKubeClient returns fixed resources from Build.
+type buildingKubeClient struct {
+	kubefake.PrintingKubeClient
+	resources kube.ResourceList
+}
+
+func (c *buildingKubeClient) Build(_ io.Reader, _ bool) (kube.ResourceList, error) {
+	return c.resources, nil
+}
+
+// newConfigMapResources returns resources for the named config maps, backed by
+// a fake client serving the given live objects. Config maps without a live
+// object are not found.
+func newConfigMapResources(t *testing.T, live map[string]*v1.ConfigMap, names ...string) kube.ResourceList {
+	t.Helper()
+	codec := scheme.Codecs.LegacyCodec(scheme.Scheme.PrioritizedVersionsAllGroups()...)
+	client := &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			name := req.URL.Path[len("/namespaces/default/configmaps/"):]
+			var obj runtime.Object = &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound}
+			code := http.StatusNotFound
+			if cm, ok := live[name]; ok {
+				obj, code = cm, http.StatusOK
+			}
+			body := ioutil.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, obj))))
+			return &http.Response{StatusCode: code, Header: header, Body: body}, nil
+		}),
+	}
+
+	var resources kube.ResourceList
+	for _, name := range names {
+		resources = append(resources, &resource.Info{
+			Client:    client,
+			Name:      name,
+			Namespace: "default",
+			Mapping: &meta.RESTMapping{
+				Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+				GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+				Scope:            meta.RESTScopeNamespace,
+			},
+			Object: &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}},
+		})
+	}
+	return resources
+}
+
+func newLiveConfigMap(name string, annotations map[string]string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+	}
+}
+
+func TestRequireAdoption(t *testing.T) {
+	resources := newConfigMapResources(t, map[string]*v1.ConfigMap{
+		"existing": newLiveConfigMap("existing", nil),
+	}, "existing", "missing")
+
+	adopt, err := requireAdoption(resources)
+	require.NoError(t, err)
+	require.Len(t, adopt, 1)
+	assert.Equal(t, "existing", adopt[0].Name)
+
+	// Without taking ownership the same resources conflict
+	_, err = existingResourceConflict(resources, "adopter", "default")
+	assert.Error(t, err)
+}
+
+func TestResourcesToAdopt(t *testing.T) {
+	owned := map[string]string{
+		helmReleaseNameAnnotation:      "adopter",
+		helmReleaseNamespaceAnnotation: "default",
+	}
+	other := map[string]string{
+		helmReleaseNameAnnotation:      "other",
+		helmReleaseNamespaceAnnotation: "default",
+	}
+	config := actionConfigFixture(t)
+	config.KubeClient = &buildingKubeClient{resources: newConfigMapResources(t, map[string]*v1.ConfigMap{
+		"unowned": newLiveConfigMap("unowned", nil),
+		"owned":   newLiveConfigMap("owned", owned),
+		"other":   newLiveConfigMap("other", other),
+	}, "unowned", "owned", "other", "missing")}
+
+	rel := releaseStub()
+	rel.Name = "adopter"
+	rel.Namespace = "default"
+
+	// "owned" lacks the managed-by label, so its ownership is taken as well
+	adopted, err := ResourcesToAdopt(config, rel)
+	require.NoError(t, err)
+	assert.Equal(t, []AdoptedResource{
+		{Kind: "ConfigMap", Name: "unowned", Namespace: "default"},
+		{Kind: "ConfigMap", Name: "owned", Namespace: "default", PreviousRelease: "adopter", PreviousReleaseNamespace: "default"},
+		{Kind: "ConfigMap", Name: "other", Namespace: "default", PreviousRelease: "other", PreviousReleaseNamespace: "default"},
+	}, adopted)
+}
+
+func adoptAction(t *testing.T, drifts ...ResourceDrift) *Adopt {
+	config := actionConfigFixture(t)
+	adopt := NewAdopt(config)
+	adopt.ReleaseName = "adopter"
+	adopt.Namespace = "spaced"
+	adopt.drift = func(*release.Release) ([]ResourceDrift, error) { return drifts, nil }
+	return adopt
+}
+
+func TestAdopt(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	adopt := adoptAction(t)
+	rel, _, err := adopt.Run(buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(1, rel.Version)
+	is.Equal(release.StatusDeployed, rel.Info.Status)
+	is.Equal("Adopted existing resources", rel.Info.Description)
+
+	stored, err := adopt.cfg.Releases.Get("adopter", 1)
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, stored.Info.Status)
+	for _, h := range stored.Hooks {
+		is.Empty(h.LastRun.Phase, "expected no hooks to run")
+	}
+
+	_, _, err = adopt.Run(buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "the release already exists")
+}
+
+func TestAdopt_DryRun(t *testing.T) {
+	req := require.New(t)
+
+	adopt := adoptAction(t)
+	adopt.DryRun = true
+	rel, _, err := adopt.Run(buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	req.Equal("Dry run complete", rel.Info.Description)
+
+	_, err = adopt.cfg.Releases.History("adopter")
+	req.Error(err)
+}
+
+func TestAdopt_Mismatch(t *testing.T) {
+	is := assert.New(t)
+
+	adopt := adoptAction(t,
+		ResourceDrift{Kind: "Deployment", Name: "web", Missing: true},
+		ResourceDrift{Kind: "Service", Name: "web", Fields: []FieldDrift{{Path: "spec.type"}, {Path: "spec.ports[0].port"}}},
+	)
+	_, _, err := adopt.Run(buildChart(), map[string]interface{}{})
+	is.EqualError(err, `the chart does not match the resources in the cluster: Deployment "web" does not exist; Service "web" differs in spec.type, spec.ports[0].port`)
+
+	_, err = adopt.cfg.Releases.History("adopter")
+	is.Error(err)
+}
@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// cancelingKubeClient cancels the operation the first time it waits for
+// resources, as if the user pressed Ctrl-C, and blocks until the operation
+// notices. Later waits, e.g. for an atomic rollback, succeed. If blockHooks
+// is set, hooks run until they are canceled. If cancelDelete is set, the
+// operation is canceled when resources are deleted instead, and built
+// manifests contain the given resources.
+type cancelingKubeClient struct {
+	kubefake.PrintingKubeClient
+	cancel       context.CancelFunc
+	blockHooks   bool
+	cancelDelete bool
+	resources    kube.ResourceList
+	waits        int
+}
+
+func (c *cancelingKubeClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	if c.resources != nil {
+		return c.resources, nil
+	}
+	return c.PrintingKubeClient.Build(reader, validate)
+}
+
+func (c *cancelingKubeClient) WaitWithContext(ctx context.Context, _ kube.ResourceList, _ time.Duration, _ bool, _ kube.ProgressFunc) error {
+	c.waits++
+	if c.cancel == nil {
+		return ctx.Err()
+	}
+	c.cancel()
+	c.cancel = nil
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *cancelingKubeClient) WatchUntilReadyWithContext(ctx context.Context, _ kube.ResourceList, _ time.Duration) error {
+	if !c.blockHooks {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *cancelingKubeClient) CreateWithContext(_ context.Context, resources kube.ResourceList) (*kube.Result, error) {
+	return c.Create(resources)
+}
+
+func (c *cancelingKubeClient) UpdateWithContext(_ context.Context, original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	return c.Update(original, target, force)
+}
+
+func (c *cancelingKubeClient) DeleteWithContext(ctx context.Context, resources kube.ResourceList, _ metav1.DeletionPropagation) (*kube.Result, []error) {
+	if c.cancelDelete && c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+		return &kube.Result{}, []error{ctx.Err()}
+	}
+	return c.Delete(resources)
+}
+
+func newCancelingKubeClient(cancel context.CancelFunc) *cancelingKubeClient {
+	return &cancelingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}, cancel: cancel}
+}
+
+func TestInstallRelease_Canceled(t *testing.T) {
+	is := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instAction := installAction(t)
+	instAction.cfg.KubeClient = newCancelingKubeClient(cancel)
+	instAction.Wait = true
+
+	res, err := instAction.RunWithContext(ctx, buildChart(), map[string]interface{}{})
+	is.Error(err)
+	is.ErrorIs(err, context.Canceled)
+	is.Contains(err.Error(), "install canceled")
+
+	rel, err := instAction.cfg.Releases.Get(res.Name, res.Version)
+	is.NoError(err)
+	is.Equal(release.StatusFailed, rel.Info.Status)
+	is.Equal(`Release "test-install-release" failed: install canceled: context canceled`, rel.Info.Description)
+}
+
+func TestInstallRelease_CanceledAtomic(t *testing.T) {
+	is := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	instAction := installAction(t)
+	instAction.cfg.KubeClient = newCancelingKubeClient(cancel)
+	instAction.Atomic = true
+
+	res, err := instAction.RunWithContext(ctx, buildChart(), map[string]interface{}{})
+	is.Error(err)
+	is.Contains(err.Error(), "install canceled")
+	is.Contains(err.Error(), "atomic")
+
+	_, err = instAction.cfg.Releases.Get(res.Name, res.Version)
+	is.Equal(driver.ErrReleaseNotFound, err)
+}
+
+func TestUpgradeRelease_CanceledAtomic(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "interrupted"
+	rel.Info.Status = release.StatusDeployed
+	upAction.cfg.Releases.Create(rel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeClient := newCancelingKubeClient(cancel)
+	upAction.cfg.KubeClient = kubeClient
+	upAction.Atomic = true
+
+	res, err := upAction.RunWithContext(ctx, rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "upgrade canceled")
+	is.Contains(err.Error(), "rolled back")
+	// The rollback waits for the resources even though the upgrade was canceled
+	is.Equal(2, kubeClient.waits)
+
+	failed, err := upAction.cfg.Releases.Get(res.Name, 2)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, failed.Info.Status)
+	is.Contains(failed.Info.Description, "upgrade canceled: context canceled")
+
+	rolledBack, err := upAction.cfg.Releases.Get(res.Name, 3)
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, rolledBack.Info.Status)
+}
+
+func TestUninstallRelease_Canceled(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	unAction := uninstallAction(t)
+	rel := releaseStub()
+	rel.Name = "interrupted"
+	rel.Info.Status = release.StatusDeployed
+	rel.Manifest = `kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: settings
+`
+	unAction.cfg.Releases.Create(rel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeClient := newCancelingKubeClient(cancel)
+	kubeClient.cancelDelete = true
+	kubeClient.resources = newConfigMapResources(t, nil, "settings")
+	unAction.cfg.KubeClient = kubeClient
+
+	_, err := unAction.RunWithContext(ctx, rel.Name)
+	req.Error(err)
+	is.ErrorIs(err, context.Canceled)
+	is.Contains(err.Error(), "uninstall canceled")
+
+	// The release is kept, so that uninstalling it again deletes the
+	// remaining resources
+	interrupted, err := unAction.cfg.Releases.Get(rel.Name, rel.Version)
+	req.NoError(err)
+	is.Equal(release.StatusUninstalling, interrupted.Info.Status)
+	is.Equal("Uninstallation failed: uninstall canceled: context canceled", interrupted.Info.Description)
+
+	unAction.cfg.KubeClient = newCancelingKubeClient(nil)
+	_, err = unAction.Run(rel.Name)
+	req.NoError(err)
+	_, err = unAction.cfg.Releases.Get(rel.Name, rel.Version)
+	is.Equal(driver.ErrReleaseNotFound, err)
+}
+
+func TestExecHook_Canceled(t *testing.T) {
+	is := assert.New(t)
+
+	config := actionConfigFixture(t)
+	kubeClient := newCancelingKubeClient(nil)
+	kubeClient.blockHooks = true
+	config.KubeClient = kubeClient
+	rel := &release.Release{
+		Name:  "interrupted",
+		Hooks: hooksWithWeights(map[string]int{"migrate": 0}, release.HookSucceeded),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := config.execHook(ctx, rel, release.HookPreInstall, time.Minute)
+	is.ErrorIs(err, context.DeadlineExceeded)
+	is.Equal(release.HookPhaseFailed, rel.Hooks[0].LastRun.Phase)
+}
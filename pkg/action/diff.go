@@ -0,0 +1,235 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// Diff is the action for comparing a proposed upgrade with the deployed release.
+//
+// It provides the implementation of 'helm diff upgrade'.
+type Diff struct {
+	cfg *Configuration
+
+	ChartPathOptions
+
+	// Devel indicates that the operation is done in devel mode.
+	Devel bool
+	// Namespace is the namespace in which this operation should be performed.
+	Namespace string
+	// ResetValues will reset the values to the chart's built-ins rather than merging with existing.
+	ResetValues bool
+	// ReuseValues will re-use the user's last supplied values.
+	ReuseValues bool
+	// SubNotes determines whether sub-notes are rendered in the chart.
+	SubNotes bool
+	// PostRender is an optional post-renderer applied to the proposed manifest.
+	PostRenderer postrender.PostRenderer
+	// DisableOpenAPIValidation controls whether OpenAPI validation is enforced.
+	DisableOpenAPIValidation bool
+	// Context is the number of unchanged lines shown around each change.
+	Context int
+}
+
+// ObjectDiff describes the change to a single object between two manifests.
+type ObjectDiff struct {
+	// Old is the object manifest in the deployed release. Empty if the object is added.
+	Old string `json:"old,omitempty"`
+	// New is the object manifest in the proposed release. Empty if the object is removed.
+	New string `json:"new,omitempty"`
+	// Diff is the unified diff from Old to New.
+	Diff string `json:"diff"`
+}
+
+// DiffResult holds the objects that differ between two manifests. Every map is
+// keyed by "<apiVersion>/<kind>/<namespace>/<name>".
+type DiffResult struct {
+	Added   map[string]*ObjectDiff `json:"added"`
+	Removed map[string]*ObjectDiff `json:"removed"`
+	Changed map[string]*ObjectDiff `json:"changed"`
+}
+
+// HasChanges reports whether any object differs.
+func (d *DiffResult) HasChanges() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) > 0
+}
+
+// Keys returns the keys of all differing objects, sorted.
+func (d *DiffResult) Keys() []string {
+	keys := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, m := range []map[string]*ObjectDiff{d.Added, d.Removed, d.Changed} {
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the diff for the given key, or nil if that object is unchanged.
+func (d *DiffResult) Get(key string) *ObjectDiff {
+	for _, m := range []map[string]*ObjectDiff{d.Added, d.Removed, d.Changed} {
+		if o, ok := m[key]; ok {
+			return o
+		}
+	}
+	return nil
+}
+
+// NewDiff creates a new Diff object with the given configuration.
+func NewDiff(cfg *Configuration) *Diff {
+	return &Diff{
+		cfg:     cfg,
+		Context: 3,
+	}
+}
+
+// Run renders the chart as an upgrade of the named release would and compares
+// the result with the manifest of the deployed release. Nothing is changed in
+// the cluster or in storage.
+func (d *Diff) Run(name string, chart *chart.Chart, vals map[string]interface{}) (*DiffResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	deployed, err := d.cfg.Releases.Deployed(name)
+	if err != nil {
+		return nil, err
+	}
+
+	u := NewUpgrade(d.cfg)
+	u.ChartPathOptions = d.ChartPathOptions
+	u.Devel = d.Devel
+	u.Namespace = d.Namespace
+	u.ResetValues = d.ResetValues
+	u.ReuseValues = d.ReuseValues
+	u.SubNotes = d.SubNotes
+	u.PostRenderer = d.PostRenderer
+	u.DisableOpenAPIValidation = d.DisableOpenAPIValidation
+	// The proposed release is never installed, so render it the way a dry run would.
+	u.DryRun = true
+
+	d.cfg.Log("rendering proposed upgrade for %s", name)
+	_, proposed, err := u.prepareUpgrade(name, chart, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffManifests(deployed.Manifest, proposed.Manifest, deployed.Namespace, d.Context)
+}
+
+// DiffManifests compares two manifests object by object. Objects without an
+// explicit namespace are assumed to live in the given namespace.
+func DiffManifests(oldManifest, newManifest, namespace string, context int) (*DiffResult, error) {
+	oldObjs, err := manifestsByKey(oldManifest, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the deployed manifest")
+	}
+	newObjs, err := manifestsByKey(newManifest, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the proposed manifest")
+	}
+
+	res := &DiffResult{
+		Added:   map[string]*ObjectDiff{},
+		Removed: map[string]*ObjectDiff{},
+		Changed: map[string]*ObjectDiff{},
+	}
+	for key, o := range oldObjs {
+		n, ok := newObjs[key]
+		if !ok {
+			res.Removed[key] = &ObjectDiff{Old: o, Diff: unifiedDiff(key, o, "", context)}
+			continue
+		}
+		if o != n {
+			res.Changed[key] = &ObjectDiff{Old: o, New: n, Diff: unifiedDiff(key, o, n, context)}
+		}
+	}
+	for key, n := range newObjs {
+		if _, ok := oldObjs[key]; !ok {
+			res.Added[key] = &ObjectDiff{New: n, Diff: unifiedDiff(key, "", n, context)}
+		}
+	}
+	return res, nil
+}
+
+// manifestHead is the part of a manifest used to identify an object.
+type manifestHead struct {
+	Version  string `json:"apiVersion"`
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+// manifestsByKey splits a manifest into its objects, keyed the same way as
+// objectKey keys a resource.Info.
+func manifestsByKey(manifest, namespace string) (map[string]string, error) {
+	objs := map[string]string{}
+	for _, m := range releaseutil.SplitManifests(manifest) {
+		var head manifestHead
+		if err := yaml.Unmarshal([]byte(m), &head); err != nil {
+			return nil, err
+		}
+		// Skip documents that only hold comments
+		if head.Kind == "" && head.Metadata.Name == "" {
+			continue
+		}
+		ns := head.Metadata.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		objs[fmt.Sprintf("%s/%s/%s/%s", head.Version, head.Kind, ns, head.Metadata.Name)] = m
+	}
+	return objs, nil
+}
+
+func unifiedDiff(key, a, b string, context int) string {
+	var from, to []string
+	if a != "" {
+		from = difflib.SplitLines(a)
+	}
+	if b != "" {
+		to = difflib.SplitLines(b)
+	}
+	// WriteUnifiedDiff only fails if the underlying writer does, which a
+	// string builder never does.
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        from,
+		B:        to,
+		FromFile: key + " (deployed)",
+		ToFile:   key + " (proposed)",
+		Context:  context,
+	})
+	return diff
+}
@@ -0,0 +1,143 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const diffConfigMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+data:
+  greeting: {{ .Values.greeting }}
+`
+
+func TestDiffManifests(t *testing.T) {
+	is := assert.New(t)
+
+	oldManifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  a: "1"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: removed
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: changed
+  namespace: other
+spec:
+  replicas: 1
+`
+	newManifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  a: "1"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: changed
+  namespace: other
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: added
+`
+
+	res, err := DiffManifests(oldManifest, newManifest, "default", 3)
+	is.NoError(err)
+	is.True(res.HasChanges())
+
+	is.Len(res.Added, 1)
+	is.Contains(res.Added, "v1/Service/default/added")
+	is.Len(res.Removed, 1)
+	is.Contains(res.Removed, "v1/Secret/default/removed")
+	is.Len(res.Changed, 1)
+	changed := res.Changed["apps/v1/Deployment/other/changed"]
+	is.NotNil(changed)
+	is.Contains(changed.Diff, "-  replicas: 1\n")
+	is.Contains(changed.Diff, "+  replicas: 2\n")
+
+	is.Equal([]string{
+		"apps/v1/Deployment/other/changed",
+		"v1/Secret/default/removed",
+		"v1/Service/default/added",
+	}, res.Keys())
+
+	res, err = DiffManifests(oldManifest, oldManifest, "default", 3)
+	is.NoError(err)
+	is.False(res.HasChanges())
+}
+
+func TestDiff_Run(t *testing.T) {
+	is := assert.New(t)
+
+	diffAction := NewDiff(actionConfigFixture(t))
+	diffAction.Namespace = "spaced"
+
+	ch := buildChart(withValues(map[string]interface{}{"greeting": "hello"}))
+	ch.Templates = append(ch.Templates, &chart.File{Name: "templates/configmap.yaml", Data: []byte(diffConfigMapTemplate)})
+
+	rel := releaseStub()
+	rel.Namespace = "spaced"
+	rel.Manifest = `---
+# Source: hello/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: angry-panda-config
+data:
+  greeting: hello
+`
+	is.NoError(diffAction.cfg.Releases.Create(rel))
+
+	res, err := diffAction.Run(rel.Name, ch, map[string]interface{}{})
+	is.NoError(err)
+	is.False(res.HasChanges())
+
+	res, err = diffAction.Run(rel.Name, ch, map[string]interface{}{"greeting": "goodbye"})
+	is.NoError(err)
+	is.Len(res.Changed, 1)
+	changed := res.Changed["v1/ConfigMap/spaced/angry-panda-config"]
+	is.NotNil(changed)
+	is.Contains(changed.Diff, "+  greeting: goodbye\n")
+
+	// Nothing should have been recorded for the proposed upgrade
+	history, err := diffAction.cfg.Releases.History(rel.Name)
+	is.NoError(err)
+	is.Len(history, 1)
+}
@@ -0,0 +1,256 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// Drift is the action for detecting changes made to the objects of a release
+// outside of Helm.
+//
+// It provides the implementation of 'helm status --drift'.
+type Drift struct {
+	cfg *Configuration
+
+	// Version is the revision whose manifest is compared. Defaults to the
+	// latest revision.
+	Version int
+	// Normalizers are applied to both the manifest and the live object of every
+	// resource before they are compared. They default to DefaultDriftNormalizers.
+	Normalizers []DriftNormalizer
+}
+
+// DriftNormalizer removes fields from an object that are expected to differ
+// between a release manifest and the live object, such as fields populated by
+// the API server.
+type DriftNormalizer func(obj map[string]interface{})
+
+// DefaultDriftNormalizers are the normalizers used unless others are set on the Drift action.
+var DefaultDriftNormalizers = []DriftNormalizer{
+	RemoveServerPopulatedFields,
+}
+
+// serverPopulatedMetadata are metadata fields that are set by the API server.
+var serverPopulatedMetadata = []string{
+	"creationTimestamp",
+	"deletionGracePeriodSeconds",
+	"deletionTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// serverPopulatedAnnotations are annotations that are maintained by controllers
+// or clients rather than by the chart.
+var serverPopulatedAnnotations = []string{
+	"deployment.kubernetes.io/revision",
+	"kubectl.kubernetes.io/last-applied-configuration",
+}
+
+// RemoveServerPopulatedFields removes the status and the metadata fields that
+// are populated by the API server.
+func RemoveServerPopulatedFields(obj map[string]interface{}) {
+	delete(obj, "status")
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, f := range serverPopulatedMetadata {
+		delete(metadata, f)
+	}
+	if annos, ok := metadata["annotations"].(map[string]interface{}); ok {
+		for _, a := range serverPopulatedAnnotations {
+			delete(annos, a)
+		}
+	}
+}
+
+// ResourceDrift describes how a live object differs from the release manifest.
+type ResourceDrift struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Missing is true if the object no longer exists in the cluster.
+	Missing bool `json:"missing,omitempty"`
+	// Fields lists the fields whose live value differs from the manifest.
+	Fields []FieldDrift `json:"fields,omitempty"`
+}
+
+// FieldDrift is a single field whose live value differs from the manifest.
+type FieldDrift struct {
+	// Path is the location of the field, e.g. "spec.template.spec.containers[0].image".
+	Path string `json:"path"`
+	// Expected is the value in the release manifest.
+	Expected interface{} `json:"expected"`
+	// Actual is the value in the cluster. It is nil if the field was removed.
+	Actual interface{} `json:"actual"`
+}
+
+// NewDrift creates a new Drift object with the given configuration.
+func NewDrift(cfg *Configuration) *Drift {
+	return &Drift{
+		cfg:         cfg,
+		Normalizers: DefaultDriftNormalizers,
+	}
+}
+
+// Run compares the objects in the manifest of the named release with the live
+// objects in the cluster. Only fields set in the manifest are compared, so
+// fields defaulted by the API server or set by controllers are not reported.
+// Objects that have not drifted are not included in the result.
+func (d *Drift) Run(name string) ([]ResourceDrift, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	rel, err := d.cfg.releaseContent(name, d.Version)
+	if err != nil {
+		return nil, err
+	}
+	return d.compare(rel)
+}
+
+func (d *Drift) compare(rel *release.Release) ([]ResourceDrift, error) {
+	resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+
+	var drifts []ResourceDrift
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rd := ResourceDrift{
+			Kind:      info.Mapping.GroupVersionKind.Kind,
+			Name:      info.Name,
+			Namespace: info.Namespace,
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		live, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				rd.Missing = true
+				drifts = append(drifts, rd)
+				return nil
+			}
+			return errors.Wrapf(err, "could not get information about %s", resourceString(info))
+		}
+
+		expected, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return err
+		}
+		actual, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+		if err != nil {
+			return err
+		}
+
+		rd.Fields = d.diffObjects(expected, actual)
+		if len(rd.Fields) > 0 {
+			drifts = append(drifts, rd)
+		}
+		return nil
+	})
+	return drifts, err
+}
+
+// diffObjects normalizes both objects and returns the fields set in expected
+// whose value differs in actual.
+func (d *Drift) diffObjects(expected, actual map[string]interface{}) []FieldDrift {
+	for _, n := range d.Normalizers {
+		n(expected)
+		n(actual)
+	}
+	var fields []FieldDrift
+	diffFields("", expected, actual, &fields)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields
+}
+
+// diffFields walks expected and records every leaf that differs in actual.
+// Maps are compared as subsets so that extra fields in actual are ignored.
+// Lists are compared element by element when they have the same length, and
+// as a whole otherwise.
+func diffFields(path string, expected, actual interface{}, fields *[]FieldDrift) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			*fields = append(*fields, FieldDrift{Path: path, Expected: expected, Actual: actual})
+			return
+		}
+		for k, v := range e {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			diffFields(p, v, a[k], fields)
+		}
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			*fields = append(*fields, FieldDrift{Path: path, Expected: expected, Actual: actual})
+			return
+		}
+		for i := range e {
+			diffFields(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], fields)
+		}
+	default:
+		if !scalarEqual(expected, actual) {
+			*fields = append(*fields, FieldDrift{Path: path, Expected: expected, Actual: actual})
+		}
+	}
+}
+
+// scalarEqual compares two scalar values, treating numbers of different
+// types as equal if they have the same value.
+func scalarEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	return aok && bok && af == bf
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
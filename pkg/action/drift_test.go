@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftDiffObjects(t *testing.T) {
+	is := assert.New(t)
+
+	expected := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name": "web",
+			"annotations": map[string]interface{}{
+				"team": "a",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.19"},
+					},
+				},
+			},
+		},
+	}
+	actual := map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":              "web",
+			"uid":               "1234",
+			"resourceVersion":   "42",
+			"generation":        int64(3),
+			"creationTimestamp": "2021-01-01T00:00:00Z",
+			"managedFields":     []interface{}{},
+			"annotations": map[string]interface{}{
+				"team":                              "a",
+				"deployment.kubernetes.io/revision": "3",
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas":             float64(5),
+			"revisionHistoryLimit": int64(10),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.21", "imagePullPolicy": "IfNotPresent"},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"replicas": int64(5)},
+	}
+
+	d := NewDrift(actionConfigFixture(t))
+	fields := d.diffObjects(expected, actual)
+	is.Equal([]FieldDrift{
+		{Path: "spec.replicas", Expected: int64(2), Actual: float64(5)},
+		{Path: "spec.template.spec.containers[0].image", Expected: "nginx:1.19", Actual: "nginx:1.21"},
+	}, fields)
+
+	// Server-populated fields never show up as drift
+	_, ok := actual["status"]
+	is.False(ok)
+	is.NotContains(actual["metadata"], "uid")
+}
+
+func TestDriftDiffObjectsRemovedField(t *testing.T) {
+	is := assert.New(t)
+
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{"a": "1", "b": "2"},
+	}
+	actual := map[string]interface{}{
+		"data": map[string]interface{}{"a": "1"},
+	}
+
+	fields := NewDrift(actionConfigFixture(t)).diffObjects(expected, actual)
+	is.Equal([]FieldDrift{{Path: "data.b", Expected: "2"}}, fields)
+
+	expected = map[string]interface{}{"args": []interface{}{"a", "b"}}
+	actual = map[string]interface{}{"args": []interface{}{"a"}}
+	fields = NewDrift(actionConfigFixture(t)).diffObjects(expected, actual)
+	is.Len(fields, 1)
+	is.Equal("args", fields[0].Path)
+}
+
+func TestDrift_Run(t *testing.T) {
+	is := assert.New(t)
+
+	d := NewDrift(actionConfigFixture(t))
+	rel := releaseStub()
+	is.NoError(d.cfg.Releases.Create(rel))
+
+	drifts, err := d.Run(rel.Name)
+	is.NoError(err)
+	is.Empty(drifts)
+
+	_, err = d.Run("no-such-release")
+	is.Error(err)
+}
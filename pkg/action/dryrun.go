@@ -0,0 +1,89 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// The ways a dry run can be done, see Install.DryRunOption.
+const (
+	// DryRunClient renders the release without sending its resources to the
+	// cluster.
+	DryRunClient = "client"
+	// DryRunServer sends the resources of the release to the API server as
+	// dry-run requests.
+	DryRunServer = "server"
+)
+
+// checkDryRunOption returns an error if option is not a way to do a dry run.
+func checkDryRunOption(option string) error {
+	switch option {
+	case "", DryRunClient, DryRunServer:
+		return nil
+	default:
+		return errors.Errorf("invalid dry run option %q: must be %q or %q", option, DryRunClient, DryRunServer)
+	}
+}
+
+// serverDryRun sends the resources of the release to the API server as dry-run
+// requests, so that they go through admission webhooks, defaulting and quota
+// checks, and replaces the manifest of the release with the objects returned
+// by the API server. The resources in original are patched, the others are
+// created.
+func (cfg *Configuration) serverDryRun(rel *release.Release, original, target kube.ResourceList) error {
+	kubeClient, ok := cfg.KubeClient.(kube.InterfaceServerDryRun)
+	if !ok {
+		return errors.New("the Kubernetes client does not support server-side dry runs")
+	}
+	mutated, err := kubeClient.DryRunUpdate(original, target)
+	if err != nil {
+		return errors.Wrap(err, "server-side dry run failed")
+	}
+
+	manifests := make([]string, 0, len(mutated))
+	for _, info := range mutated {
+		m, err := dryRunManifest(info.Object)
+		if err != nil {
+			return errors.Wrapf(err, "unable to print %s %s", info.Mapping.GroupVersionKind.Kind, info.Name)
+		}
+		manifests = append(manifests, m)
+	}
+	rel.Manifest = strings.Join(manifests, "---\n")
+	return nil
+}
+
+// dryRunManifest returns the YAML of an object returned by a dry run, without
+// its managed fields which only add noise.
+func dryRunManifest(obj runtime.Object) (string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetManagedFields(nil)
+	b, err := yaml.Marshal(u.Object)
+	return string(b), err
+}
@@ -0,0 +1,149 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// serverDryRunKubeClient answers dry runs like an API server with a mutating
+// webhook that labels config maps, or denies them if err is set.
+type serverDryRunKubeClient struct {
+	waveRecordingKubeClient
+	err error
+
+	original []string
+}
+
+func (c *serverDryRunKubeClient) DryRunUpdate(original, target kube.ResourceList) (kube.ResourceList, error) {
+	for _, r := range original {
+		c.original = append(c.original, r.Name)
+	}
+	c.record("dry-run", target)
+	if c.err != nil {
+		return nil, c.err
+	}
+	var mutated kube.ResourceList
+	for _, r := range target {
+		cm := r.Object.(*v1.ConfigMap).DeepCopy()
+		cm.Labels = map[string]string{"mutated-by": "webhook"}
+		mutated = append(mutated, &resource.Info{Name: r.Name, Namespace: r.Namespace, Mapping: r.Mapping, Object: cm})
+	}
+	return mutated, nil
+}
+
+func TestInstallRelease_ServerDryRun(t *testing.T) {
+	instAction := installAction(t)
+	client := &serverDryRunKubeClient{waveRecordingKubeClient: waveRecordingKubeClient{t: t}}
+	instAction.cfg.KubeClient = client
+	instAction.DryRun = true
+	instAction.DryRunOption = DryRunServer
+
+	res, err := instAction.Run(buildChart(withWaveTemplates(map[string]string{"app": ""})), map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dry-run app"}, client.ops)
+	assert.Empty(t, client.original)
+	assert.Equal(t, "Dry run complete", res.Info.Description)
+	assert.Contains(t, res.Manifest, "name: app")
+	assert.Contains(t, res.Manifest, "mutated-by: webhook")
+
+	_, err = instAction.cfg.Releases.Get(res.Name, res.Version)
+	assert.Error(t, err, "a dry run should not store the release")
+}
+
+func TestInstallRelease_ServerDryRunDenied(t *testing.T) {
+	instAction := installAction(t)
+	client := &serverDryRunKubeClient{
+		waveRecordingKubeClient: waveRecordingKubeClient{t: t},
+		err:                     fmt.Errorf(`admission webhook "deny.example.com" denied the request`),
+	}
+	instAction.cfg.KubeClient = client
+	instAction.DryRun = true
+	instAction.DryRunOption = DryRunServer
+
+	_, err := instAction.Run(buildChart(withWaveTemplates(map[string]string{"app": ""})), map[string]interface{}{})
+	assert.EqualError(t, err, `server-side dry run failed: admission webhook "deny.example.com" denied the request`)
+}
+
+func TestInstallRelease_DryRunOption(t *testing.T) {
+	t.Run("client dry run does not contact the API server", func(t *testing.T) {
+		instAction := installAction(t)
+		client := &serverDryRunKubeClient{waveRecordingKubeClient: waveRecordingKubeClient{t: t}}
+		instAction.cfg.KubeClient = client
+		instAction.DryRun = true
+		instAction.DryRunOption = DryRunClient
+
+		res, err := instAction.Run(buildChart(withWaveTemplates(map[string]string{"app": ""})), map[string]interface{}{})
+		require.NoError(t, err)
+		assert.Empty(t, client.ops)
+		assert.NotContains(t, res.Manifest, "mutated-by")
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		instAction := installAction(t)
+		instAction.DryRun = true
+		instAction.DryRunOption = "cluster"
+
+		_, err := instAction.Run(buildChart(), map[string]interface{}{})
+		assert.EqualError(t, err, `invalid dry run option "cluster": must be "client" or "server"`)
+	})
+
+	t.Run("client without server dry run support", func(t *testing.T) {
+		instAction := installAction(t)
+		instAction.cfg.KubeClient = &waveRecordingKubeClient{t: t}
+		instAction.DryRun = true
+		instAction.DryRunOption = DryRunServer
+
+		_, err := instAction.Run(buildChart(withWaveTemplates(map[string]string{"app": ""})), map[string]interface{}{})
+		assert.EqualError(t, err, "the Kubernetes client does not support server-side dry runs")
+	})
+}
+
+func TestUpgradeRelease_ServerDryRun(t *testing.T) {
+	upAction := upgradeAction(t)
+	client := &serverDryRunKubeClient{waveRecordingKubeClient: waveRecordingKubeClient{t: t}}
+	upAction.cfg.KubeClient = client
+	upAction.DryRun = true
+	upAction.DryRunOption = DryRunServer
+
+	rel := releaseStub()
+	rel.Name = "dry"
+	rel.Info.Status = release.StatusDeployed
+	rel.Manifest = configMapManifest("app", "") + "---\n" + configMapManifest("old", "")
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	res, err := upAction.Run(rel.Name, buildChart(withWaveTemplates(map[string]string{"app": "", "web": ""})), map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"dry-run app,web"}, client.ops)
+	assert.ElementsMatch(t, []string{"app", "old"}, client.original)
+	assert.Contains(t, res.Manifest, "mutated-by: webhook")
+
+	last, err := upAction.cfg.Releases.Last(rel.Name)
+	require.NoError(t, err)
+	assert.Equal(t, rel.Version, last.Version, "a dry run should not store the release")
+}
@@ -0,0 +1,91 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// GetMetadata is the action for checking a given release's metadata.
+//
+// It provides the implementation of 'helm get metadata'.
+type GetMetadata struct {
+	cfg *Configuration
+
+	Version int
+}
+
+// Metadata describes a release.
+type Metadata struct {
+	Name          string            `json:"name"`
+	Chart         string            `json:"chart"`
+	Version       string            `json:"version"`
+	AppVersion    string            `json:"appVersion"`
+	Labels        map[string]string `json:"labels"`
+	Namespace     string            `json:"namespace"`
+	Revision      int               `json:"revision"`
+	Status        string            `json:"status"`
+	FirstDeployed helmtime.Time     `json:"firstDeployed"`
+	LastDeployed  helmtime.Time     `json:"lastDeployed"`
+}
+
+// NewGetMetadata creates a new GetMetadata object with the given configuration.
+func NewGetMetadata(cfg *Configuration) *GetMetadata {
+	return &GetMetadata{
+		cfg: cfg,
+	}
+}
+
+// Run executes 'helm get metadata' against the given release.
+func (g *GetMetadata) Run(name string) (*Metadata, error) {
+	if err := g.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	rel, err := g.cfg.releaseContent(name, g.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{
+		Name:          rel.Name,
+		Labels:        rel.Labels,
+		Namespace:     rel.Namespace,
+		Revision:      rel.Version,
+		Status:        rel.Info.Status.String(),
+		FirstDeployed: rel.Info.FirstDeployed,
+		LastDeployed:  rel.Info.LastDeployed,
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		m.Chart = rel.Chart.Metadata.Name
+		m.Version = rel.Chart.Metadata.Version
+		m.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return m, nil
+}
+
+// FormattedLabels returns the labels formatted as "key=value", sorted.
+func (m *Metadata) FormattedLabels() []string {
+	labels := make([]string, 0, len(m.Labels))
+	for k, v := range m.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,154 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// HistoryPrune is the action for removing old revisions from the history of
+// releases according to a retention policy.
+//
+// It provides the implementation of 'helm history prune'.
+type HistoryPrune struct {
+	cfg *Configuration
+
+	// Policy decides which revisions are kept.
+	Policy storage.RetentionPolicy
+	// AllNamespaces prunes the releases of every namespace. The releases are
+	// listed from the storage of the configuration, which must not be scoped
+	// to a namespace, and pruned through the storage returned by
+	// NamespaceStorage for their namespace.
+	AllNamespaces bool
+	// NamespaceStorage returns the storage of the releases of a namespace. It
+	// is required if AllNamespaces is set.
+	NamespaceStorage func(namespace string) (*storage.Storage, error)
+	// DryRun reports the revisions that would be pruned without removing them.
+	DryRun bool
+	// WaitForLock is how long to wait for the lock of each release if another
+	// operation holds it
+	WaitForLock time.Duration
+}
+
+// NewHistoryPrune creates a new HistoryPrune object with the given configuration.
+func NewHistoryPrune(cfg *Configuration) *HistoryPrune {
+	return &HistoryPrune{
+		cfg: cfg,
+	}
+}
+
+// Run prunes the history of the named releases, or of every release if no
+// name is given, one release at a time. It returns the pruned revisions.
+func (p *HistoryPrune) Run(names ...string) ([]*release.Release, error) {
+	policy := p.Policy
+	if policy.MaxHistory <= 0 && policy.MaxAge <= 0 && policy.MaxSuperseded <= 0 && policy.MaxFailed <= 0 {
+		return nil, errors.New("the retention policy does not limit the history of releases")
+	}
+	if p.AllNamespaces && p.NamespaceStorage == nil {
+		return nil, errors.New("pruning releases across namespaces requires the storage of each namespace")
+	}
+	for _, name := range names {
+		if err := chartutil.ValidateReleaseName(name); err != nil {
+			return nil, errors.Errorf("release name is invalid: %s", name)
+		}
+	}
+
+	all, err := p.cfg.Releases.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	found := map[string]bool{}
+	releases := map[string]map[string]bool{}
+	for _, rel := range all {
+		if len(wanted) > 0 && !wanted[rel.Name] {
+			continue
+		}
+		if releases[rel.Namespace] == nil {
+			releases[rel.Namespace] = map[string]bool{}
+		}
+		releases[rel.Namespace][rel.Name] = true
+		found[rel.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, errors.Wrapf(driver.ErrReleaseNotFound, "release %q", name)
+		}
+	}
+
+	namespaces := make([]string, 0, len(releases))
+	for ns := range releases {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	var pruned []*release.Release
+	for _, ns := range namespaces {
+		cfg := p.cfg
+		if p.AllNamespaces {
+			store, err := p.NamespaceStorage(ns)
+			if err != nil {
+				return pruned, err
+			}
+			cfg = &Configuration{Releases: store, Log: p.cfg.Log}
+		}
+
+		sorted := make([]string, 0, len(releases[ns]))
+		for name := range releases[ns] {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			revisions, err := p.pruneRelease(cfg, name)
+			pruned = append(pruned, revisions...)
+			if err != nil {
+				return pruned, err
+			}
+		}
+	}
+	return pruned, nil
+}
+
+func (p *HistoryPrune) pruneRelease(cfg *Configuration, name string) ([]*release.Release, error) {
+	if p.DryRun {
+		history, err := cfg.Releases.History(name)
+		if err != nil {
+			return nil, err
+		}
+		return p.Policy.Prunable(history, time.Now()), nil
+	}
+
+	unlock, err := cfg.lockRelease(name, p.WaitForLock)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg.Log("pruning history of release %s", name)
+	return cfg.Releases.Prune(name, p.Policy)
+}
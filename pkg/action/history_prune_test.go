@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// historyPruneFixture stores releases with superseded, failed and deployed
+// revisions in two namespaces.
+func historyPruneFixture(t *testing.T) (*Configuration, *driver.Memory) {
+	t.Helper()
+	mem := driver.NewMemory()
+	cfg := actionConfigFixture(t)
+	cfg.Releases = storage.Init(mem)
+
+	statuses := []release.Status{release.StatusSuperseded, release.StatusSuperseded, release.StatusFailed, release.StatusDeployed}
+	for _, ns := range []string{"default", "other"} {
+		for _, name := range []string{"apple", "banana"} {
+			for i, status := range statuses {
+				rel := namedReleaseStub(name, status)
+				rel.Namespace = ns
+				rel.Version = i + 1
+				require.NoError(t, cfg.Releases.Create(rel))
+			}
+		}
+	}
+	mem.SetNamespace("default")
+	return cfg, mem
+}
+
+// prunedRevisions returns the pruned revisions as namespace/name.vN.
+func prunedRevisions(rels []*release.Release) []string {
+	var revisions []string
+	for _, rel := range rels {
+		revisions = append(revisions, fmt.Sprintf("%s/%s.v%d", rel.Namespace, rel.Name, rel.Version))
+	}
+	return revisions
+}
+
+func TestHistoryPrune(t *testing.T) {
+	cfg, _ := historyPruneFixture(t)
+	prune := NewHistoryPrune(cfg)
+	prune.Policy = storage.RetentionPolicy{MaxSuperseded: 1}
+
+	pruned, err := prune.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default/apple.v1", "default/banana.v1"}, prunedRevisions(pruned))
+
+	h, err := cfg.Releases.History("apple")
+	require.NoError(t, err)
+	assert.Len(t, h, 3)
+
+	t.Run("named release", func(t *testing.T) {
+		prune.Policy = storage.RetentionPolicy{MaxHistory: 2}
+		pruned, err := prune.Run("banana")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"default/banana.v2"}, prunedRevisions(pruned))
+	})
+
+	t.Run("missing release", func(t *testing.T) {
+		_, err := prune.Run("cherry")
+		assert.EqualError(t, err, `release "cherry": release: not found`)
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		prune.Policy = storage.RetentionPolicy{KeepDeployed: 2}
+		_, err := prune.Run()
+		assert.EqualError(t, err, "the retention policy does not limit the history of releases")
+	})
+}
+
+func TestHistoryPrune_DryRun(t *testing.T) {
+	cfg, _ := historyPruneFixture(t)
+	prune := NewHistoryPrune(cfg)
+	prune.Policy = storage.RetentionPolicy{MaxHistory: 2}
+	prune.DryRun = true
+
+	pruned, err := prune.Run("apple")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default/apple.v1", "default/apple.v2"}, prunedRevisions(pruned))
+
+	h, err := cfg.Releases.History("apple")
+	require.NoError(t, err)
+	assert.Len(t, h, 4, "a dry run should not prune the history")
+}
+
+func TestHistoryPrune_AllNamespaces(t *testing.T) {
+	cfg, mem := historyPruneFixture(t)
+	mem.SetNamespace("")
+	prune := NewHistoryPrune(cfg)
+	prune.Policy = storage.RetentionPolicy{MaxSuperseded: 1}
+	prune.AllNamespaces = true
+
+	_, err := prune.Run()
+	assert.EqualError(t, err, "pruning releases across namespaces requires the storage of each namespace")
+
+	var namespaces []string
+	prune.NamespaceStorage = func(namespace string) (*storage.Storage, error) {
+		namespaces = append(namespaces, namespace)
+		mem.SetNamespace(namespace)
+		return storage.Init(mem), nil
+	}
+	pruned, err := prune.Run()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "other"}, namespaces)
+	assert.Equal(t, []string{"default/apple.v1", "default/banana.v1", "other/apple.v1", "other/banana.v1"}, prunedRevisions(pruned))
+
+	for _, ns := range namespaces {
+		mem.SetNamespace(ns)
+		h, err := cfg.Releases.History("banana")
+		require.NoError(t, err)
+		assert.Len(t, h, 3, "expected the history of banana in %s to be pruned", ns)
+	}
+}
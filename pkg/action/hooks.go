@@ -17,24 +17,34 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
 	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
 // execHook executes all of the hooks for the given hook event.
-func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent, timeout time.Duration) error {
+//
+// Hooks run in the order of their weight. Hooks with the same weight and kind
+// run concurrently unless SerialHooks is set, kinds in install order, and all
+// of them complete before the hooks of the next weight start.
+// Hooks stop when ctx is done.
+func (cfg *Configuration) execHook(ctx context.Context, rl *release.Release, hook release.HookEvent, timeout time.Duration) error {
 	executingHooks := []*release.Hook{}
 
 	for _, h := range rl.Hooks {
-		for _, e := range h.Events {
-			if e == hook {
-				executingHooks = append(executingHooks, h)
-			}
+		if hookHasEvent(h, hook) {
+			executingHooks = append(executingHooks, h)
 		}
 	}
 
@@ -50,60 +60,452 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 			//                 current release.
 			h.DeletePolicies = []release.HookDeletePolicy{release.HookBeforeHookCreation}
 		}
+	}
+
+	// mu guards the executions of the hooks while they are recorded in the
+	// release, since hooks of the same weight update them concurrently.
+	var mu sync.Mutex
+	for _, batch := range cfg.hookBatches(executingHooks) {
+		if len(batch) == 1 {
+			if err := cfg.runHook(ctx, rl, batch[0], hook, timeout, &mu); err != nil {
+				return err
+			}
+			continue
+		}
+
+		errs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i, h := range batch {
+			wg.Add(1)
+			go func(i int, h *release.Hook) {
+				defer wg.Done()
+				errs[i] = cfg.runHook(ctx, rl, h, hook, timeout, &mu)
+			}(i, h)
+		}
+		wg.Wait()
+
+		var failed []error
+		for _, err := range errs {
+			if err != nil {
+				failed = append(failed, err)
+			}
+		}
+		switch len(failed) {
+		case 0:
+		case 1:
+			return failed[0]
+		default:
+			return errors.New(joinErrors(failed))
+		}
+	}
 
-		if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation); err != nil {
+	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
+	// under succeeded condition. If so, then clear the corresponding resource object in each hook
+	for _, h := range executingHooks {
+		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded); err != nil {
 			return err
 		}
+	}
 
-		resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
-		if err != nil {
-			return errors.Wrapf(err, "unable to build kubernetes object for %s hook %s", hook, h.Path)
+	return nil
+}
+
+// hookBatches splits hooks sorted by weight into the batches that run
+// concurrently, or into one batch per hook if SerialHooks is set. Hooks of
+// the same weight run in one batch per kind, in releaseutil.InstallOrder, so
+// that Namespaces, ServiceAccounts, RBAC rules and ConfigMaps exist before
+// the Jobs and Pods that may depend on them. Hooks of unknown kinds run last.
+func (cfg *Configuration) hookBatches(hooks []*release.Hook) [][]*release.Hook {
+	var batches [][]*release.Hook
+	if cfg.SerialHooks {
+		for _, h := range hooks {
+			batches = append(batches, []*release.Hook{h})
 		}
+		return batches
+	}
 
-		// Record the time at which the hook was applied to the cluster
-		h.LastRun = release.HookExecution{
-			StartedAt: helmtime.Now(),
-			Phase:     release.HookPhaseRunning,
+	for start := 0; start < len(hooks); {
+		end := start
+		for end < len(hooks) && hooks[end].Weight == hooks[start].Weight {
+			end++
 		}
-		cfg.recordRelease(rl)
+		weight := append([]*release.Hook(nil), hooks[start:end]...)
+		sort.SliceStable(weight, func(i, j int) bool {
+			return hookKindRank(weight[i]) < hookKindRank(weight[j])
+		})
+		for i := 0; i < len(weight); {
+			j := i
+			for j < len(weight) && hookKindRank(weight[j]) == hookKindRank(weight[i]) {
+				j++
+			}
+			batches = append(batches, weight[i:j])
+			i = j
+		}
+		start = end
+	}
+	return batches
+}
 
-		// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
-		// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
-		// the most appropriate value to surface.
-		h.LastRun.Phase = release.HookPhaseUnknown
+// hookKindRank returns the position of the kind of the hook in
+// releaseutil.InstallOrder. All unknown kinds rank after the known ones.
+func hookKindRank(h *release.Hook) int {
+	for i, kind := range releaseutil.InstallOrder {
+		if kind == h.Kind {
+			return i
+		}
+	}
+	return len(releaseutil.InstallOrder)
+}
+
+// runHook creates the resources of a hook and watches them until they are
+// ready. The execution of the hook is recorded in the release, and notified,
+// while holding mu.
+func (cfg *Configuration) runHook(ctx context.Context, rl *release.Release, h *release.Hook, hook release.HookEvent, timeout time.Duration, mu *sync.Mutex) error {
+	if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation); err != nil {
+		return err
+	}
+
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
+	if err != nil {
+		return errors.Wrapf(err, "unable to build kubernetes object for %s hook %s", hook, h.Path)
+	}
+
+	// Record the time at which the hook was applied to the cluster
+	mu.Lock()
+	h.LastRun = release.HookExecution{
+		StartedAt: helmtime.Now(),
+		Phase:     release.HookPhaseRunning,
+	}
+	cfg.recordRelease(rl)
+
+	// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
+	// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
+	// the most appropriate value to surface.
+	h.LastRun.Phase = release.HookPhaseUnknown
+	cfg.notify(Event{Type: EventHookStarted, Release: rl, Hook: h})
+	mu.Unlock()
+
+	// The timeout of the hook applies to each attempt
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			cfg.Log("%s hook %s failed, retrying (%d/%d): %s", hook, h.Path, attempt, h.Retries, err)
+			// Delete the resources of the failed attempt so that they are created again
+			if _, errs := cfg.KubeClient.Delete(resources); len(errs) > 0 {
+				err := errors.Wrapf(errors.New(joinErrors(errs)), "unable to delete %s hook %s to retry it", hook, h.Path)
+				mu.Lock()
+				h.LastRun.CompletedAt = helmtime.Now()
+				h.LastRun.Phase = release.HookPhaseFailed
+				cfg.notify(Event{Type: EventHookFinished, Release: rl, Hook: h, Err: err})
+				mu.Unlock()
+				return err
+			}
+		}
 
 		// Create hook resources
-		if _, err := cfg.KubeClient.Create(resources); err != nil {
+		if err := cfg.createHookResources(ctx, resources, attempt > 0, timeout); err != nil {
+			err = errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+			mu.Lock()
 			h.LastRun.CompletedAt = helmtime.Now()
 			h.LastRun.Phase = release.HookPhaseFailed
-			return errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+			cfg.notify(Event{Type: EventHookFinished, Release: rl, Hook: h, Err: err})
+			mu.Unlock()
+			return err
 		}
 
 		// Watch hook resources until they have completed
-		err = cfg.KubeClient.WatchUntilReady(resources, timeout)
-		// Note the time of success/failure
-		h.LastRun.CompletedAt = helmtime.Now()
-		// Mark hook as succeeded or failed
-		if err != nil {
-			h.LastRun.Phase = release.HookPhaseFailed
-			// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
-			// under failed condition. If so, then clear the corresponding resource object in the hook
-			if err := cfg.deleteHookByPolicy(h, release.HookFailed); err != nil {
-				return err
-			}
-			return err
+		err = cfg.watchHookResources(ctx, resources, timeout)
+		if err == nil || attempt >= h.Retries || ctx.Err() != nil {
+			break
 		}
+	}
+
+	// Capture the output before a delete policy removes the hook resources
+	var logs []release.HookContainerLog
+	var events []release.HookObjectEvent
+	if err != nil || cfg.KeepHookOutput {
+		logs, events = cfg.hookOutput(h, resources)
+	}
+
+	mu.Lock()
+	// Note the time of success/failure
+	h.LastRun.CompletedAt = helmtime.Now()
+	h.LastRun.Logs, h.LastRun.Events = fitHookOutput(logs, events, maxReleaseHookOutputBytes-releaseHookOutputSize(rl))
+	// Mark hook as succeeded or failed
+	if err != nil {
+		h.LastRun.Phase = release.HookPhaseFailed
+	} else {
 		h.LastRun.Phase = release.HookPhaseSucceeded
 	}
+	cfg.notify(Event{Type: EventHookFinished, Release: rl, Hook: h, Err: err})
+	mu.Unlock()
 
-	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
-	// under succeeded condition. If so, then clear the corresponding resource object in each hook
-	for _, h := range executingHooks {
-		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded); err != nil {
+	if err != nil {
+		// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
+		// under failed condition. If so, then clear the corresponding resource object in the hook
+		if err := cfg.deleteHookByPolicy(h, release.HookFailed); err != nil {
 			return err
 		}
+		return err
 	}
+	return nil
+}
 
+// hookRetryInterval is how often the resources of a retried hook are created
+// again while the resources of the failed attempt are still being deleted.
+var hookRetryInterval = 2 * time.Second
+
+// createHookResources creates the resources of a hook. When the hook is
+// retried, the resources of the previous attempt may still be terminating, so
+// their creation is retried until timeout.
+func (cfg *Configuration) createHookResources(ctx context.Context, resources kube.ResourceList, retry bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := cfg.createResources(ctx, resources)
+		if err == nil || !retry || !apierrors.IsAlreadyExists(errors.Cause(err)) || time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(hookRetryInterval):
+		}
+	}
+}
+
+// watchHookResources watches the resources of a hook until they are ready,
+// or until ctx is done if the KubeClient implements kube.InterfaceContext.
+func (cfg *Configuration) watchHookResources(ctx context.Context, resources kube.ResourceList, timeout time.Duration) error {
+	if kubeClient, ok := cfg.KubeClient.(kube.InterfaceContext); ok {
+		return kubeClient.WatchUntilReadyWithContext(ctx, resources, timeout)
+	}
+	return cfg.KubeClient.WatchUntilReady(resources, timeout)
+}
+
+// Hook output is stored in the release, so it is capped to keep the release
+// within the size limits of the storage drivers.
+var (
+	// maxHookLogBytes is how much of the end of the log of each container is kept.
+	maxHookLogBytes = 8 * 1024
+	// maxHookLogs is how many container logs are kept for each hook, most recent pods first.
+	maxHookLogs = 4
+	// maxHookEvents is how many of the most recent events are kept for each hook.
+	maxHookEvents = 20
+	// maxReleaseHookOutputBytes is how many bytes of logs and event messages
+	// are kept for all the hooks of a release.
+	maxReleaseHookOutputBytes = 64 * 1024
+)
+
+// hookOutput returns the container logs and Kubernetes events of the hook
+// resources, if the kube client supports capturing them. Errors are only
+// logged, they do not fail the hook.
+func (cfg *Configuration) hookOutput(h *release.Hook, resources kube.ResourceList) ([]release.HookContainerLog, []release.HookObjectEvent) {
+	kubeClient, ok := cfg.KubeClient.(kube.InterfaceLogs)
+	if !ok {
+		return nil, nil
+	}
+	logs, events, err := kubeClient.GetLogs(resources, maxHookLogBytes, maxHookLogs)
+	if err != nil {
+		cfg.Log("warning: unable to capture the output of hook %s: %s", h.Path, err)
+	}
+
+	if len(logs) > maxHookLogs {
+		logs = logs[:maxHookLogs]
+	}
+	hookLogs := make([]release.HookContainerLog, 0, len(logs))
+	for _, l := range logs {
+		hl := release.HookContainerLog{
+			Pod:       l.Pod,
+			Container: l.Container,
+			Log:       l.Log,
+			Truncated: l.Truncated,
+		}
+		if l.Err != nil {
+			hl.Error = l.Err.Error()
+		}
+		hookLogs = append(hookLogs, hl)
+	}
+
+	if len(events) > maxHookEvents {
+		events = events[len(events)-maxHookEvents:]
+	}
+	hookEvents := make([]release.HookObjectEvent, 0, len(events))
+	for _, e := range events {
+		hookEvents = append(hookEvents, release.HookObjectEvent{
+			Object:   e.Object,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastSeen: helmtime.Time{Time: e.LastSeen},
+		})
+	}
+	return hookLogs, hookEvents
+}
+
+// releaseHookOutputSize returns how many bytes of logs and event messages are
+// kept for the hooks of the release.
+func releaseHookOutputSize(rl *release.Release) int {
+	size := 0
+	for _, h := range rl.Hooks {
+		for _, l := range h.LastRun.Logs {
+			size += len(l.Log) + len(l.Error)
+		}
+		for _, e := range h.LastRun.Events {
+			size += len(e.Message)
+		}
+	}
+	return size
+}
+
+// fitHookOutput returns the output of a hook that fits in budget bytes of
+// logs and event messages. The most recent events are kept first, then the
+// logs in order. The log that does not fit is cut to its end.
+func fitHookOutput(logs []release.HookContainerLog, events []release.HookObjectEvent, budget int) ([]release.HookContainerLog, []release.HookObjectEvent) {
+	first := len(events)
+	for first > 0 && len(events[first-1].Message) <= budget {
+		first--
+		budget -= len(events[first].Message)
+	}
+	events = events[first:]
+
+	var kept []release.HookContainerLog
+	for _, l := range logs {
+		if len(l.Error) > budget {
+			break
+		}
+		budget -= len(l.Error)
+		if len(l.Log) > budget {
+			if budget == 0 {
+				break
+			}
+			l.Log = l.Log[len(l.Log)-budget:]
+			l.Truncated = true
+			kept = append(kept, l)
+			break
+		}
+		budget -= len(l.Log)
+		kept = append(kept, l)
+	}
+
+	if len(events) == 0 {
+		events = nil
+	}
+	return kept, events
+}
+
+// execFailureHook runs the hooks of a failure event after an operation on the
+// release failed with reason. The hooks are rendered again so that templates
+// can use .Release.FailureReason. A failing failure hook is only logged, as
+// the operation has failed already. They run even if ctx was canceled, so they
+// keep the values of ctx but are only bounded by the timeout of the hooks.
+func (cfg *Configuration) execFailureHook(ctx context.Context, rl *release.Release, hook release.HookEvent, reason error, timeout time.Duration, pr postrender.PostRenderer) {
+	if err := cfg.renderFailureHooks(rl, hook, reason.Error(), pr); err != nil {
+		cfg.Log("warning: unable to render %s hooks with the failure reason, running them as rendered for the release: %s", hook, err)
+	}
+
+	ctx = detachedContext{ctx}
+	if bound := failureHookTimeout(rl, hook, timeout); bound > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bound)
+		defer cancel()
+	}
+	if err := cfg.execHook(ctx, rl, hook, timeout); err != nil {
+		cfg.Log("warning: %s hooks failed: %s", hook, err)
+	}
+}
+
+// failureHookTimeout returns the longest of timeout and the timeouts of the
+// hooks of the failure event.
+func failureHookTimeout(rl *release.Release, hook release.HookEvent, timeout time.Duration) time.Duration {
+	for _, h := range rl.Hooks {
+		if hookHasEvent(h, hook) && time.Duration(h.Timeout) > timeout {
+			timeout = time.Duration(h.Timeout)
+		}
+	}
+	return timeout
+}
+
+// detachedContext is a context with the values of its parent that is never
+// canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// renderFailureHooks renders the chart of the release again with
+// .Release.FailureReason set and updates the hooks of the given failure event
+// in rl.Hooks. Hooks that are only rendered when the failure reason is set are
+// added to the release.
+func (cfg *Configuration) renderFailureHooks(rl *release.Release, hook release.HookEvent, reason string, pr postrender.PostRenderer) error {
+	if rl.Chart == nil {
+		return errors.New("the release has no chart")
+	}
+
+	caps, err := cfg.getCapabilities()
+	if err != nil {
+		return err
+	}
+	if err := chartutil.ProcessDependencies(rl.Chart, rl.Config); err != nil {
+		return err
+	}
+	options := chartutil.ReleaseOptions{
+		Name:          rl.Name,
+		Namespace:     rl.Namespace,
+		Revision:      rl.Version,
+		IsInstall:     hook == release.HookPostInstallFailure,
+		IsUpgrade:     hook != release.HookPostInstallFailure,
+		FailureReason: reason,
+	}
+	values, err := chartutil.ToRenderValues(rl.Chart, rl.Config, options, caps)
+	if err != nil {
+		return err
+	}
+	hooks, _, _, err := cfg.renderResources(rl.Chart, values, "", "", false, false, false, pr, false)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hooks {
+		if !hookHasEvent(h, hook) {
+			continue
+		}
+		if current := findHook(rl.Hooks, h); current != nil {
+			current.Manifest = h.Manifest
+		} else {
+			rl.Hooks = append(rl.Hooks, h)
+		}
+	}
+	return nil
+}
+
+// hookHasEvent reports whether the hook fires on the given event.
+func hookHasEvent(h *release.Hook, event release.HookEvent) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// findHook returns the hook in hooks rendered from the same template for the
+// same resource as h.
+func findHook(hooks []*release.Hook, h *release.Hook) *release.Hook {
+	for _, current := range hooks {
+		if current.Path == h.Path && current.Kind == h.Kind && current.Name == h.Name {
+			return current
+		}
+	}
 	return nil
 }
 
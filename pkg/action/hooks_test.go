@@ -0,0 +1,455 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// loggingKubeClient returns canned container logs and events for hooks.
+type loggingKubeClient struct {
+	kubefake.FailingKubeClient
+	logs   []kube.ContainerLog
+	events []kube.ObjectEvent
+}
+
+func (c *loggingKubeClient) GetLogs(_ kube.ResourceList, limitBytes, maxLogs int) ([]kube.ContainerLog, []kube.ObjectEvent, error) {
+	return c.logs, c.events, nil
+}
+
+func TestExecHookCapturesOutput(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+
+	kubeClient := &loggingKubeClient{
+		FailingKubeClient: kubefake.FailingKubeClient{WatchUntilReadyError: fmt.Errorf("job failed: BackoffLimitExceeded")},
+		events: []kube.ObjectEvent{{
+			Object:   "Job/migrate",
+			Type:     "Warning",
+			Reason:   "BackoffLimitExceeded",
+			Message:  "Job has reached the specified backoff limit",
+			LastSeen: time.Now(),
+		}},
+	}
+	for i := 0; i < maxHookLogs+2; i++ {
+		kubeClient.logs = append(kubeClient.logs, kube.ContainerLog{
+			Pod:       fmt.Sprintf("migrate-%d", i),
+			Container: "migrate",
+			Log:       "error: relation already exists\n",
+		})
+	}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	hook := &release.Hook{
+		Name:           "migrate",
+		Kind:           "Job",
+		Path:           "templates/migrate.yaml",
+		Events:         []release.HookEvent{release.HookPreUpgrade},
+		DeletePolicies: []release.HookDeletePolicy{release.HookFailed},
+	}
+	rel.Hooks = []*release.Hook{hook}
+
+	err := config.execHook(context.Background(), rel, release.HookPreUpgrade, time.Minute)
+	is.Error(err)
+	is.Equal(release.HookPhaseFailed, hook.LastRun.Phase)
+	is.Len(hook.LastRun.Logs, maxHookLogs, "the number of logs kept should be capped")
+	is.Equal("migrate-0", hook.LastRun.Logs[0].Pod)
+	is.Equal("error: relation already exists\n", hook.LastRun.Logs[0].Log)
+	is.Len(hook.LastRun.Events, 1)
+	is.Equal("BackoffLimitExceeded", hook.LastRun.Events[0].Reason)
+}
+
+func TestExecHookKeepsOutputOfFailedHooks(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	config.KubeClient = &loggingKubeClient{
+		logs: []kube.ContainerLog{{Pod: "migrate-0", Container: "migrate", Log: "migrated\n"}},
+	}
+
+	rel := releaseStub()
+	hook := &release.Hook{
+		Name:   "migrate",
+		Kind:   "Job",
+		Path:   "templates/migrate.yaml",
+		Events: []release.HookEvent{release.HookPreUpgrade},
+	}
+	rel.Hooks = []*release.Hook{hook}
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreUpgrade, time.Minute))
+	is.Equal(release.HookPhaseSucceeded, hook.LastRun.Phase)
+	is.Empty(hook.LastRun.Logs, "the output of successful hooks should not be kept by default")
+
+	config.KeepHookOutput = true
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreUpgrade, time.Minute))
+	is.Len(hook.LastRun.Logs, 1, "the output of successful hooks should be kept if asked for")
+	is.Equal("migrated\n", hook.LastRun.Logs[0].Log)
+}
+
+func TestExecHookReleaseOutputBudget(t *testing.T) {
+	is := assert.New(t)
+	defer func(budget int) { maxReleaseHookOutputBytes = budget }(maxReleaseHookOutputBytes)
+	maxReleaseHookOutputBytes = 30
+
+	config := actionConfigFixture(t)
+	config.KubeClient = &loggingKubeClient{
+		FailingKubeClient: kubefake.FailingKubeClient{WatchUntilReadyError: fmt.Errorf("job failed: BackoffLimitExceeded")},
+		logs:              []kube.ContainerLog{{Pod: "migrate-0", Container: "migrate", Log: "0123456789abcdef\n"}},
+	}
+
+	rel := releaseStub()
+	hook := &release.Hook{
+		Name:   "migrate",
+		Kind:   "Job",
+		Path:   "templates/migrate.yaml",
+		Events: []release.HookEvent{release.HookPreUpgrade},
+	}
+	previous := &release.Hook{
+		Name: "seed",
+		Kind: "Job",
+		Path: "templates/seed.yaml",
+		LastRun: release.HookExecution{
+			Logs: []release.HookContainerLog{{Pod: "seed-0", Container: "seed", Log: "0123456789abcdef\n"}},
+		},
+	}
+	rel.Hooks = []*release.Hook{previous, hook}
+
+	is.Error(config.execHook(context.Background(), rel, release.HookPreUpgrade, time.Minute))
+	is.Len(hook.LastRun.Logs, 1)
+	is.Equal("456789abcdef\n", hook.LastRun.Logs[0].Log, "the output should be cut to the budget left by the other hooks")
+	is.True(hook.LastRun.Logs[0].Truncated)
+}
+
+func TestFitHookOutput(t *testing.T) {
+	is := assert.New(t)
+	logs := []release.HookContainerLog{
+		{Pod: "a", Log: "aaaa"},
+		{Pod: "b", Error: "gone"},
+		{Pod: "c", Log: "cccc"},
+	}
+	events := []release.HookObjectEvent{{Message: "old"}, {Message: "new"}}
+
+	keptLogs, keptEvents := fitHookOutput(logs, events, 100)
+	is.Equal(logs, keptLogs)
+	is.Equal(events, keptEvents)
+
+	keptLogs, keptEvents = fitHookOutput(logs, events, 14)
+	is.Equal([]release.HookObjectEvent{{Message: "old"}, {Message: "new"}}, keptEvents)
+	is.Equal([]release.HookContainerLog{{Pod: "a", Log: "aaaa"}, {Pod: "b", Error: "gone"}}, keptLogs)
+
+	keptLogs, keptEvents = fitHookOutput(logs, events, 5)
+	is.Equal([]release.HookObjectEvent{{Message: "new"}}, keptEvents, "the most recent events should be kept")
+	is.Equal([]release.HookContainerLog{{Pod: "a", Log: "aa", Truncated: true}}, keptLogs, "the log that does not fit should be cut to its end")
+
+	keptLogs, keptEvents = fitHookOutput(logs, events, -1)
+	is.Empty(keptLogs)
+	is.Empty(keptEvents)
+}
+
+func TestExecHookWithoutLogs(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+
+	rel := releaseStub()
+	hook := &release.Hook{
+		Name:   "migrate",
+		Kind:   "Job",
+		Path:   "templates/migrate.yaml",
+		Events: []release.HookEvent{release.HookPreUpgrade},
+	}
+	rel.Hooks = []*release.Hook{hook}
+
+	// The kube client of the fixture cannot capture logs
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreUpgrade, time.Minute))
+	is.Equal(release.HookPhaseSucceeded, hook.LastRun.Phase)
+	is.Empty(hook.LastRun.Logs)
+	is.Empty(hook.LastRun.Events)
+}
+
+// hookRecordingKubeClient builds one resource per hook, named after the hook
+// manifest, and records how the hooks are run.
+type hookRecordingKubeClient struct {
+	kubefake.PrintingKubeClient
+	delay time.Duration
+	fail  map[string]bool
+	// failures is how many times a hook fails before it succeeds
+	failures map[string]int
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	started    []string
+	deleted    []string
+	timeouts   map[string]time.Duration
+}
+
+func (c *hookRecordingKubeClient) Build(reader io.Reader, _ bool) (kube.ResourceList, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return kube.ResourceList{&resource.Info{Name: strings.TrimSpace(string(data))}}, nil
+}
+
+func (c *hookRecordingKubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *hookRecordingKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range resources {
+		c.deleted = append(c.deleted, r.Name)
+	}
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *hookRecordingKubeClient) WatchUntilReady(resources kube.ResourceList, timeout time.Duration) error {
+	name := resources[0].Name
+	c.mu.Lock()
+	if c.timeouts == nil {
+		c.timeouts = map[string]time.Duration{}
+	}
+	c.timeouts[name] = timeout
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.started = append(c.started, name)
+	c.mu.Unlock()
+
+	time.Sleep(c.delay)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+	if c.fail[name] {
+		return fmt.Errorf("job %s failed", name)
+	}
+	if c.failures[name] > 0 {
+		c.failures[name]--
+		return fmt.Errorf("job %s failed", name)
+	}
+	return nil
+}
+
+func hooksWithWeights(weights map[string]int, policy release.HookDeletePolicy) []*release.Hook {
+	var hooks []*release.Hook
+	for name, weight := range weights {
+		hooks = append(hooks, &release.Hook{
+			Name:           name,
+			Kind:           "Job",
+			Path:           "templates/" + name,
+			Manifest:       name,
+			Weight:         weight,
+			Events:         []release.HookEvent{release.HookPreInstall},
+			DeletePolicies: []release.HookDeletePolicy{policy},
+		})
+	}
+	return hooks
+}
+
+func TestExecHookRunsSameWeightConcurrently(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{delay: 100 * time.Millisecond}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"a": 0, "b": 0, "c": 0, "d": 1}, release.HookSucceeded)
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal(3, kubeClient.maxRunning, "hooks with the same weight should run concurrently")
+	is.Equal("d", kubeClient.started[3], "hooks of the next weight should start after the previous weight completed")
+	is.ElementsMatch([]string{"a", "b", "c", "d"}, kubeClient.deleted)
+	for _, h := range rel.Hooks {
+		is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase, h.Name)
+	}
+}
+
+func TestExecHookCreatesResourcesBeforeWorkloads(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{delay: 10 * time.Millisecond}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"migrate": 0, "smoke-test": 0}, release.HookSucceeded)
+	for kind, name := range map[string]string{"ServiceAccount": "migrator", "ConfigMap": "migrations"} {
+		rel.Hooks = append(rel.Hooks, &release.Hook{
+			Name:     name,
+			Kind:     kind,
+			Path:     "templates/" + name,
+			Manifest: name,
+			Events:   []release.HookEvent{release.HookPreInstall},
+		})
+	}
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal(2, kubeClient.maxRunning, "hooks of the same kind should run concurrently")
+	is.Equal([]string{"migrator", "migrations"}, kubeClient.started[:2], "resources should be created before the workloads of the same weight")
+	is.ElementsMatch([]string{"migrate", "smoke-test"}, kubeClient.started[2:])
+}
+
+func TestExecHookInstallOrderWithinWeight(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{delay: 10 * time.Millisecond}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	for kind, name := range map[string]string{"ConfigMap": "a-settings", "Namespace": "z-tenant"} {
+		rel.Hooks = append(rel.Hooks, &release.Hook{
+			Name:     name,
+			Kind:     kind,
+			Path:     "templates/" + name,
+			Manifest: name,
+			Events:   []release.HookEvent{release.HookPreInstall},
+		})
+	}
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal(1, kubeClient.maxRunning, "hooks of different kinds should not run concurrently")
+	is.Equal([]string{"z-tenant", "a-settings"}, kubeClient.started, "the Namespace should be created before the ConfigMap of the same weight")
+}
+
+func TestExecHookSerialHooks(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	config.SerialHooks = true
+	kubeClient := &hookRecordingKubeClient{delay: 10 * time.Millisecond}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"a": 0, "b": 0, "c": -1}, release.HookSucceeded)
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal(1, kubeClient.maxRunning)
+	is.Equal([]string{"c", "a", "b"}, kubeClient.started)
+}
+
+func TestExecHookConcurrentFailure(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{
+		delay: 10 * time.Millisecond,
+		fail:  map[string]bool{"a": true},
+	}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"a": 0, "b": 0, "c": 1}, release.HookFailed)
+
+	err := config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute)
+	is.EqualError(err, "job a failed")
+
+	phases := map[string]release.HookPhase{}
+	for _, h := range rel.Hooks {
+		phases[h.Name] = h.LastRun.Phase
+	}
+	is.Equal(release.HookPhaseFailed, phases["a"])
+	is.Equal(release.HookPhaseSucceeded, phases["b"], "hooks of the same weight should complete when one fails")
+	is.Equal(release.HookPhase(""), phases["c"], "hooks of the next weight should not run")
+	is.Equal([]string{"a"}, kubeClient.deleted, "only the failed hook should be deleted by the hook-failed policy")
+}
+
+func TestExecHookTimeoutAndRetries(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{
+		failures: map[string]int{"migrate": 2, "smoke-test": 2},
+	}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"migrate": 0}, release.HookSucceeded)
+	rel.Hooks[0].Timeout = helmtime.Duration(40 * time.Minute)
+	rel.Hooks[0].Retries = 2
+
+	is.NoError(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal([]string{"migrate", "migrate", "migrate"}, kubeClient.started, "the hook should be retried until it succeeds")
+	is.Equal(40*time.Minute, kubeClient.timeouts["migrate"])
+	// The failed attempts are deleted before they are retried
+	is.Equal([]string{"migrate", "migrate", "migrate"}, kubeClient.deleted)
+	is.Equal(release.HookPhaseSucceeded, rel.Hooks[0].LastRun.Phase)
+
+	rel.Hooks = hooksWithWeights(map[string]int{"smoke-test": 0}, release.HookSucceeded)
+	rel.Hooks[0].Retries = 1
+	kubeClient.started = nil
+
+	is.Error(config.execHook(context.Background(), rel, release.HookPreInstall, time.Minute))
+	is.Equal([]string{"smoke-test", "smoke-test"}, kubeClient.started, "the hook should not run more than its retries")
+	is.Equal(time.Minute, kubeClient.timeouts["smoke-test"], "the operation timeout should be used by default")
+	is.Equal(release.HookPhaseFailed, rel.Hooks[0].LastRun.Phase)
+}
+
+func TestExecFailureHookRunsWhenCanceled(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	kubeClient := &hookRecordingKubeClient{}
+	config.KubeClient = kubeClient
+
+	rel := releaseStub()
+	rel.Chart = nil
+	rel.Hooks = hooksWithWeights(map[string]int{"notify": 0}, release.HookSucceeded)
+	rel.Hooks[0].Events = []release.HookEvent{release.HookPostInstallFailure}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	config.execFailureHook(ctx, rel, release.HookPostInstallFailure, ctx.Err(), time.Minute, nil)
+	is.Equal([]string{"notify"}, kubeClient.started, "failure hooks should run after the operation was canceled")
+	is.Equal(release.HookPhaseSucceeded, rel.Hooks[0].LastRun.Phase)
+}
+
+func TestFailureHookContext(t *testing.T) {
+	is := assert.New(t)
+	type key struct{}
+
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+	ctx := detachedContext{parent}
+	is.NoError(ctx.Err(), "the context should not be canceled with its parent")
+	is.Equal("value", ctx.Value(key{}), "the context should keep the values of its parent")
+
+	rel := releaseStub()
+	rel.Hooks = hooksWithWeights(map[string]int{"notify": 0, "migrate": 0}, release.HookSucceeded)
+	for _, h := range rel.Hooks {
+		if h.Name == "notify" {
+			h.Events = []release.HookEvent{release.HookPostInstallFailure}
+			h.Timeout = helmtime.Duration(10 * time.Minute)
+		} else {
+			h.Timeout = helmtime.Duration(time.Hour)
+		}
+	}
+	is.Equal(10*time.Minute, failureHookTimeout(rel, release.HookPostInstallFailure, time.Minute), "the longest timeout of the failure hooks should bound them")
+	is.Equal(time.Minute, failureHookTimeout(rel, release.HookPostUpgradeFailure, time.Minute))
+}
@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -104,6 +105,30 @@ type Install struct {
 	// OutputDir/<ReleaseName>
 	UseReleaseName bool
 	PostRenderer   postrender.PostRenderer
+	// Labels are added to the release metadata
+	Labels map[string]string
+	// ServerSideApply creates the resources with server-side apply, so that
+	// Helm's field manager owns the fields set by the chart
+	ServerSideApply bool
+	// ForceConflicts takes over fields owned by other field managers when ServerSideApply is set
+	ForceConflicts bool
+	// WaitForLock is how long to wait for the release lock if another
+	// operation holds it. By default the install fails immediately.
+	WaitForLock time.Duration
+	// TakeOwnership adopts resources that already exist in the cluster into
+	// the release, even if they are not annotated as owned by it
+	TakeOwnership bool
+	// Retention, if set, removes the revisions of the release history it does
+	// not keep when the release is replaced.
+	Retention *storage.RetentionPolicy
+	// WaitProgress receives which resources are not ready yet, and why, while
+	// waiting for them. It requires a KubeClient implementing
+	// kube.InterfaceWaitProgress.
+	WaitProgress kube.ProgressFunc
+	// DryRunOption is how the dry run is done if DryRun is set: DryRunClient,
+	// the default, or DryRunServer. A server-side dry run also lets the
+	// templates look up resources in the cluster.
+	DryRunOption string
 }
 
 // ChartPathOptions captures common options used for controlling chart paths
@@ -128,7 +153,7 @@ func NewInstall(cfg *Configuration) *Install {
 	}
 }
 
-func (i *Install) installCRDs(crds []chart.CRD) error {
+func (i *Install) installCRDs(ctx context.Context, crds []chart.CRD) error {
 	// We do these one file at a time in the order they were read.
 	totalItems := []*resource.Info{}
 	for _, obj := range crds {
@@ -139,7 +164,7 @@ func (i *Install) installCRDs(crds []chart.CRD) error {
 		}
 
 		// Send them to Kube
-		if _, err := i.cfg.KubeClient.Create(res); err != nil {
+		if _, err := i.cfg.createResources(ctx, res); err != nil {
 			// If the error is CRD already exists, continue.
 			if apierrors.IsAlreadyExists(err) {
 				crdName := res[0].Name
@@ -175,6 +200,13 @@ func (i *Install) installCRDs(crds []chart.CRD) error {
 //
 // If DryRun is set to true, this will prepare the release, but not install it
 func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	return i.RunWithContext(context.Background(), chrt, vals)
+}
+
+// RunWithContext executes the installation until ctx is done. If ctx is
+// canceled while the release is being installed, hooks and waits are stopped
+// and the release is marked as failed, which uninstalls it if Atomic is set.
+func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
 	// Check reachability of cluster unless in client-only mode (e.g. `helm template` without `--validate`)
 	if !i.ClientOnly {
 		if err := i.cfg.KubeClient.IsReachable(); err != nil {
@@ -182,17 +214,38 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		}
 	}
 
+	if err := checkDryRunOption(i.DryRunOption); err != nil {
+		return nil, err
+	}
+
 	if err := i.availableName(); err != nil {
 		return nil, err
 	}
 
+	if !i.DryRun {
+		unlock, err := i.cfg.lockRelease(i.ReleaseName, i.WaitForLock)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+
+		// Check the name again, it may have been taken while waiting for the lock
+		if err := i.availableName(); err != nil {
+			return nil, err
+		}
+	}
+
+	if driver.ContainsSystemLabels(i.Labels) {
+		return nil, fmt.Errorf("user supplied labels contains system reserved label name. System labels: %+v", driver.GetSystemLabels())
+	}
+
 	// Pre-install anything in the crd/ directory. We do this before Helm
 	// contacts the upstream server and builds the capabilities object.
 	if crds := chrt.CRDObjects(); !i.ClientOnly && !i.SkipCRDs && len(crds) > 0 {
 		// On dry run, bail here
 		if i.DryRun {
 			i.cfg.Log("WARNING: This chart or one of its subcharts contains CRDs. Rendering may fail or contain inaccuracies.")
-		} else if err := i.installCRDs(crds); err != nil {
+		} else if err := i.installCRDs(ctx, crds); err != nil {
 			return nil, err
 		}
 	}
@@ -244,7 +297,7 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	rel := i.createRelease(chrt, vals)
 
 	var manifestDoc *bytes.Buffer
-	rel.Hooks, manifestDoc, rel.Info.Notes, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, i.DryRun)
+	rel.Hooks, manifestDoc, rel.Info.Notes, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, i.DryRun && i.DryRunOption != DryRunServer)
 	// Even for errors, attach this if available
 	if manifestDoc != nil {
 		rel.Manifest = manifestDoc.String()
@@ -255,6 +308,7 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		// Return a release with partial data so that the client can show debugging information.
 		return rel, err
 	}
+	i.cfg.notify(Event{Type: EventRenderFinished, Release: rel})
 
 	// Mark this release as in-progress
 	rel.SetStatus(release.StatusPendingInstall, "Initial install underway")
@@ -271,6 +325,12 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		return nil, err
 	}
 
+	// Resources are applied in waves, see kube.WaveAnnotation.
+	waves, err := resources.Waves()
+	if err != nil {
+		return nil, err
+	}
+
 	// Install requires an extra validation step of checking that resources
 	// don't already exist before we actually create resources. If we continue
 	// forward and create the release object with resources that already exist,
@@ -278,7 +338,11 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	// deleting the release because the manifest will be pointing at that
 	// resource
 	if !i.ClientOnly && !isUpgrade && len(resources) > 0 {
-		toBeAdopted, err = existingResourceConflict(resources, rel.Name, rel.Namespace)
+		if i.TakeOwnership {
+			toBeAdopted, err = requireAdoption(resources)
+		} else {
+			toBeAdopted, err = existingResourceConflict(resources, rel.Name, rel.Namespace)
+		}
 		if err != nil {
 			return nil, errors.Wrap(err, "rendered manifests contain a resource that already exists. Unable to continue with install")
 		}
@@ -286,6 +350,11 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 
 	// Bail out here if it is a dry run
 	if i.DryRun {
+		if i.DryRunOption == DryRunServer && !i.ClientOnly {
+			if err := i.cfg.serverDryRun(rel, toBeAdopted, resources); err != nil {
+				return rel, err
+			}
+		}
 		rel.Info.Description = "Dry run complete"
 		return rel, nil
 	}
@@ -311,7 +380,7 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		if err != nil {
 			return nil, err
 		}
-		if _, err := i.cfg.KubeClient.Create(resourceList); err != nil && !apierrors.IsAlreadyExists(err) {
+		if _, err := i.cfg.createResources(ctx, resourceList); err != nil && !apierrors.IsAlreadyExists(err) {
 			return nil, err
 		}
 	}
@@ -325,7 +394,7 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 
 	// Store the release in history before continuing (new in Helm 3). We always know
 	// that this is a create operation.
-	if err := i.cfg.Releases.Create(rel); err != nil {
+	if err := i.cfg.createRelease(rel, i.Retention); err != nil {
 		// We could try to recover gracefully here, but since nothing has been installed
 		// yet, this is probably safer than trying to continue when we know storage is
 		// not working.
@@ -334,39 +403,34 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 
 	// pre-install hooks
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPreInstall, i.Timeout); err != nil {
-			return i.failRelease(rel, fmt.Errorf("failed pre-install: %s", err))
+		if err := i.cfg.execHook(ctx, rel, release.HookPreInstall, i.Timeout); err != nil {
+			return i.failRelease(ctx, rel, fmt.Errorf("failed pre-install: %s", err))
 		}
 	}
 
 	// At this point, we can do the install. Note that before we were detecting whether to
 	// do an update, but it's not clear whether we WANT to do an update if the re-use is set
 	// to true, since that is basically an upgrade operation.
-	if len(toBeAdopted) == 0 && len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Create(resources); err != nil {
-			return i.failRelease(rel, err)
-		}
-	} else if len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Update(toBeAdopted, resources, false); err != nil {
-			return i.failRelease(rel, err)
+	_, err = i.cfg.applyInWaves(ctx, rel, waves, i.Timeout, i.WaitProgress, func(wave kube.ResourceList) (*kube.Result, error) {
+		adopted := toBeAdopted.Intersect(wave)
+		if len(adopted) == 0 && !i.ServerSideApply {
+			return i.cfg.createResources(ctx, wave)
 		}
+		return i.cfg.updateResources(ctx, adopted, wave, false, i.ServerSideApply, i.ForceConflicts)
+	})
+	if err != nil {
+		return i.failRelease(ctx, rel, err)
 	}
 
 	if i.Wait {
-		if i.WaitForJobs {
-			if err := i.cfg.KubeClient.WaitWithJobs(resources, i.Timeout); err != nil {
-				return i.failRelease(rel, err)
-			}
-		} else {
-			if err := i.cfg.KubeClient.Wait(resources, i.Timeout); err != nil {
-				return i.failRelease(rel, err)
-			}
+		if err := i.cfg.waitForResources(ctx, rel, resources, i.Timeout, i.WaitForJobs, i.WaitProgress); err != nil {
+			return i.failRelease(ctx, rel, err)
 		}
 	}
 
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPostInstall, i.Timeout); err != nil {
-			return i.failRelease(rel, fmt.Errorf("failed post-install: %s", err))
+		if err := i.cfg.execHook(ctx, rel, release.HookPostInstall, i.Timeout); err != nil {
+			return i.failRelease(ctx, rel, fmt.Errorf("failed post-install: %s", err))
 		}
 	}
 
@@ -390,7 +454,11 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	return rel, nil
 }
 
-func (i *Install) failRelease(rel *release.Release, err error) (*release.Release, error) {
+func (i *Install) failRelease(ctx context.Context, rel *release.Release, err error) (*release.Release, error) {
+	err = canceled(ctx, "install", err)
+	if !i.DisableHooks {
+		i.cfg.execFailureHook(ctx, rel, release.HookPostInstallFailure, err, i.Timeout, i.PostRenderer)
+	}
 	rel.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", i.ReleaseName, err.Error()))
 	if i.Atomic {
 		i.cfg.Log("Install failed and atomic is set, uninstalling release")
@@ -456,6 +524,7 @@ func (i *Install) createRelease(chrt *chart.Chart, rawVals map[string]interface{
 			Status:        release.StatusUnknown,
 		},
 		Version: 1,
+		Labels:  i.Labels,
 	}
 }
 
@@ -463,7 +532,7 @@ func (i *Install) createRelease(chrt *chart.Chart, rawVals map[string]interface{
 func (i *Install) recordRelease(r *release.Release) error {
 	// This is a legacy function which has been reduced to a oneliner. Could probably
 	// refactor it out.
-	return i.cfg.Releases.Update(r)
+	return i.cfg.updateRelease(r)
 }
 
 // replaceRelease replaces an older release with this one
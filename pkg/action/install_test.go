@@ -77,6 +77,35 @@ func TestInstallRelease(t *testing.T) {
 	is.Equal(rel.Info.Description, "Install complete")
 }
 
+func TestInstallReleaseWithLabels(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	instAction.Labels = map[string]string{
+		"key1": "val1",
+		"key2": "val2",
+	}
+	res, err := instAction.Run(buildChart(), nil)
+	if err != nil {
+		t.Fatalf("Failed install: %s", err)
+	}
+
+	rel, err := instAction.cfg.Releases.Get(res.Name, res.Version)
+	is.NoError(err)
+	is.Equal(instAction.Labels, rel.Labels)
+}
+
+func TestInstallReleaseWithSystemLabels(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	instAction.Labels = map[string]string{
+		"owner": "val1",
+		"key2":  "val2",
+	}
+	_, err := instAction.Run(buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "system reserved label name")
+}
+
 func TestInstallReleaseWithValues(t *testing.T) {
 	is := assert.New(t)
 	instAction := installAction(t)
@@ -362,6 +391,45 @@ func TestInstallRelease_Wait(t *testing.T) {
 	is.Equal(res.Info.Status, release.StatusFailed)
 }
 
+func TestInstallRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	instAction.ReleaseName = "come-fail-away"
+	failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WaitError = fmt.Errorf("I timed out")
+	instAction.cfg.KubeClient = failer
+	instAction.Wait = true
+
+	res, err := instAction.Run(buildChart(withFailureHooks()), map[string]interface{}{})
+	is.Error(err)
+	is.Equal(release.StatusFailed, res.Info.Status)
+
+	hooks := map[string]*release.Hook{}
+	for _, h := range res.Hooks {
+		hooks[h.Name] = h
+	}
+	is.Equal(release.HookPhaseSucceeded, hooks["failure-cm"].LastRun.Phase)
+	is.Contains(hooks["failure-cm"].Manifest, `reason: "I timed out"`)
+	is.Contains(hooks, "failure-notification", "hooks rendered only on failure should be added to the release")
+	is.Equal(release.HookPhaseSucceeded, hooks["failure-notification"].LastRun.Phase)
+	is.True(hooks["test-cm"].LastRun.StartedAt.IsZero(), "post-install hooks should not run after a failure")
+}
+
+func TestInstallRelease_FailureHooksNotRunOnSuccess(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+
+	res, err := instAction.Run(buildChart(withFailureHooks()), map[string]interface{}{})
+	is.NoError(err)
+	is.Len(res.Hooks, 2)
+	for _, h := range res.Hooks {
+		if h.Name == "failure-cm" {
+			is.True(h.LastRun.StartedAt.IsZero(), "failure hooks should not run on success")
+			is.Contains(h.Manifest, `reason: ""`)
+		}
+	}
+}
+
 func TestInstallRelease_WaitForJobs(t *testing.T) {
 	is := assert.New(t)
 	instAction := installAction(t)
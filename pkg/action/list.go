@@ -19,6 +19,7 @@ package action
 import (
 	"path"
 	"regexp"
+	"strconv"
 
 	"k8s.io/apimachinery/pkg/labels"
 
@@ -279,7 +280,7 @@ func (l *List) filterSelector(releases []*release.Release, selector labels.Selec
 	desiredStateReleases := make([]*release.Release, 0)
 
 	for _, rls := range releases {
-		if selector.Matches(labels.Set(rls.Labels)) {
+		if selector.Matches(selectorLabels(rls)) {
 			desiredStateReleases = append(desiredStateReleases, rls)
 		}
 	}
@@ -287,6 +288,23 @@ func (l *List) filterSelector(releases []*release.Release, selector labels.Selec
 	return desiredStateReleases
 }
 
+// selectorLabels returns the labels matched by the list selector: the custom
+// labels of the release, and the name, owner, status and version labels that
+// the storage drivers set, which are not part of the release labels.
+func selectorLabels(rls *release.Release) labels.Set {
+	set := labels.Set{}
+	for k, v := range rls.Labels {
+		set[k] = v
+	}
+	set["name"] = rls.Name
+	set["owner"] = "helm"
+	set["version"] = strconv.Itoa(rls.Version)
+	if rls.Info != nil {
+		set["status"] = rls.Info.Status.String()
+	}
+	return set
+}
+
 // SetStateMask calculates the state mask based on parameters.
 func (l *List) SetStateMask() {
 	if l.All {
@@ -365,4 +365,12 @@ func TestSelectorList(t *testing.T) {
 		expectedFilteredList := []*release.Release{r2, r3}
 		assert.ElementsMatch(t, expectedFilteredList, res)
 	})
+
+	t.Run("should select releases by the labels of their storage objects", func(t *testing.T) {
+		lister.Selector = "name=r2,owner=helm"
+		res, _ := lister.Run()
+
+		expectedFilteredList := []*release.Release{r2}
+		assert.ElementsMatch(t, expectedFilteredList, res)
+	})
 }
@@ -0,0 +1,243 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+var (
+	// lockTTL is how long a release lock is valid without being renewed. The
+	// lock is renewed in the background while the operation runs, so it only
+	// expires if the process holding it dies.
+	lockTTL = 2 * time.Minute
+	// lockPollInterval is how often a locked release is checked while waiting
+	// for its lock.
+	lockPollInterval = 2 * time.Second
+)
+
+// heldLock is a release lock held by a Configuration.
+type heldLock struct {
+	count int
+	stop  chan struct{}
+	// ready is closed once the lock is acquired, or failed to be. Until then
+	// the lock is pending and other callers wait for it without holding lockMu.
+	ready   chan struct{}
+	pending bool
+	// unlocked is set if the release runs unlocked because the storage does
+	// not allow locking.
+	unlocked bool
+	// renewed is when the lock was last renewed, and lost is set once it may
+	// have been taken over by another holder.
+	renewed time.Time
+	lost    error
+}
+
+// lockHolder identifies this process as the holder of release locks.
+func lockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+// lockRelease locks the named release against operations by other processes
+// and returns a function that unlocks it. If the release is locked by another
+// holder, it retries until wait has elapsed.
+//
+// Locks are counted per release, so an operation that runs another one on the
+// same release, such as an atomic upgrade rolling back, does not block itself.
+// If the storage driver does not support locking this is a no-op.
+func (cfg *Configuration) lockRelease(name string, wait time.Duration) (func(), error) {
+	if cfg.Releases == nil || cfg.Releases.Locker == nil {
+		return func() {}, nil
+	}
+
+	for {
+		cfg.lockMu.Lock()
+		if cfg.lockHolder == "" {
+			cfg.lockHolder = lockHolder()
+		}
+		if cfg.locks == nil {
+			cfg.locks = make(map[string]*heldLock)
+		}
+		held, ok := cfg.locks[name]
+		if !ok {
+			break
+		}
+		if held.pending {
+			// Wait for the caller acquiring the lock, and try again if it failed
+			cfg.lockMu.Unlock()
+			<-held.ready
+			continue
+		}
+		held.count++
+		cfg.lockMu.Unlock()
+		return func() { cfg.unlockRelease(name) }, nil
+	}
+
+	// The lock is acquired without holding lockMu, so that waiting for a
+	// release does not block the locks of other releases.
+	held := &heldLock{pending: true, ready: make(chan struct{})}
+	cfg.locks[name] = held
+	disabled := cfg.lockDisabled
+	cfg.lockMu.Unlock()
+
+	var err error
+	if !disabled {
+		err = cfg.acquireLock(name, wait)
+	}
+	unlocked := disabled
+	if isLockUnavailable(err) {
+		cfg.Log("WARNING: release locks are unavailable, running without lock: %s", err)
+		cfg.Log("WARNING: Locking releases requires permissions on coordination.k8s.io Leases in the release namespace")
+		unlocked, err = true, nil
+	}
+
+	cfg.lockMu.Lock()
+	defer cfg.lockMu.Unlock()
+	defer close(held.ready)
+	if err != nil {
+		delete(cfg.locks, name)
+		return nil, err
+	}
+	held.pending = false
+	held.count = 1
+	held.unlocked = unlocked
+	if unlocked {
+		cfg.lockDisabled = true
+	} else {
+		held.stop = make(chan struct{})
+		held.renewed = time.Now()
+		go cfg.renewLock(name, held)
+	}
+	return func() { cfg.unlockRelease(name) }, nil
+}
+
+// isLockUnavailable returns whether err means that the storage cannot lock
+// releases at all, because the API is missing or not allowed.
+func isLockUnavailable(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsNotFound(err)
+}
+
+// acquireLock acquires the lock of the named release, polling until wait has
+// elapsed while it is held by another holder.
+func (cfg *Configuration) acquireLock(name string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		err := cfg.Releases.Locker.AcquireLock(name, cfg.lockHolder, lockTTL)
+		if err == nil {
+			cfg.Log("acquired lock of release %s", name)
+			return nil
+		}
+		if !errors.Is(err, driver.ErrReleaseLocked) {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			if wait > 0 {
+				return errors.Wrapf(err, "timed out after %s waiting for lock", wait)
+			}
+			return err
+		}
+		cfg.Log("waiting for lock: %s", err)
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// renewLock renews the lock of the named release until its stop channel is
+// closed. The lock is lost once another holder took it over, or once it
+// could not be renewed before it expired.
+func (cfg *Configuration) renewLock(name string, held *heldLock) {
+	ticker := time.NewTicker(lockTTL / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-held.stop:
+			return
+		case <-ticker.C:
+			err := cfg.Releases.Locker.AcquireLock(name, cfg.lockHolder, lockTTL)
+
+			cfg.lockMu.Lock()
+			switch {
+			case err == nil:
+				held.renewed = time.Now()
+			case errors.Is(err, driver.ErrReleaseLocked):
+				held.lost = err
+			case time.Since(held.renewed) >= lockTTL:
+				held.lost = errors.Wrap(err, "lock expired")
+			}
+			lost := held.lost
+			cfg.lockMu.Unlock()
+
+			if err != nil {
+				cfg.Log("failed to renew lock of release %s: %s", name, err)
+			}
+			if lost != nil {
+				return
+			}
+		}
+	}
+}
+
+// checkLock returns an error if this configuration lost the lock it holds on
+// the named release, in which case another operation may be running on it.
+func (cfg *Configuration) checkLock(name string) error {
+	cfg.lockMu.Lock()
+	defer cfg.lockMu.Unlock()
+	if held, ok := cfg.locks[name]; ok && held.lost != nil {
+		return errors.Wrapf(held.lost, "lost the lock of release %s", name)
+	}
+	return nil
+}
+
+func (cfg *Configuration) unlockRelease(name string) {
+	cfg.lockMu.Lock()
+	defer cfg.lockMu.Unlock()
+
+	held, ok := cfg.locks[name]
+	if !ok {
+		return
+	}
+	held.count--
+	if held.count > 0 {
+		return
+	}
+	delete(cfg.locks, name)
+	if held.unlocked {
+		return
+	}
+	close(held.stop)
+
+	if err := cfg.Releases.Locker.ReleaseLock(name, cfg.lockHolder); err != nil {
+		cfg.Log("failed to release lock of release %s: %s", name, err)
+		return
+	}
+	cfg.Log("released lock of release %s", name)
+}
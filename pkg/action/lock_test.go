@@ -0,0 +1,214 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestUpgradeRelease_Locked(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "locked"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+	req.NoError(upAction.cfg.Releases.Locker.AcquireLock(rel.Name, "ci@runner (pid 42)", time.Minute))
+
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.True(errors.Is(err, driver.ErrReleaseLocked))
+	is.Contains(err.Error(), "ci@runner (pid 42)")
+
+	// The release was not touched
+	last, err := upAction.cfg.Releases.Last(rel.Name)
+	req.NoError(err)
+	is.Equal(rel.Version, last.Version)
+}
+
+func TestUpgradeRelease_WaitForLock(t *testing.T) {
+	defer func(interval time.Duration) { lockPollInterval = interval }(lockPollInterval)
+	lockPollInterval = 10 * time.Millisecond
+
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "locked"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	locker := upAction.cfg.Releases.Locker
+	req.NoError(locker.AcquireLock(rel.Name, "other", time.Minute))
+
+	// Times out while the lock is held
+	upAction.WaitForLock = 30 * time.Millisecond
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "timed out after 30ms waiting for lock")
+
+	// Succeeds once the lock is released
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		locker.ReleaseLock(rel.Name, "other")
+	}()
+	upAction.WaitForLock = 5 * time.Second
+	res, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+
+	_, err = locker.GetLock(rel.Name)
+	is.Equal(driver.ErrLockNotFound, err, "expected the lock to be released after the upgrade")
+}
+
+func TestUpgradeRelease_AtomicKeepsLock(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WatchUntilReadyError = fmt.Errorf("arming key removed")
+	upAction.cfg.KubeClient = failer
+	upAction.Atomic = true
+
+	// The rollback runs under the lock taken by the upgrade
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "has been rolled back")
+
+	_, err = upAction.cfg.Releases.Locker.GetLock(rel.Name)
+	is.Equal(driver.ErrLockNotFound, err)
+}
+
+func TestReleaseUnlock(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	req.NoError(config.Releases.Locker.AcquireLock("locked", "ci@runner (pid 42)", time.Minute))
+
+	l, err := NewReleaseUnlock(config).Run("locked")
+	req.NoError(err)
+	is.Equal("ci@runner (pid 42)", l.Holder)
+
+	_, err = NewReleaseUnlock(config).Run("locked")
+	is.Equal(driver.ErrLockNotFound, err)
+}
+
+// unavailableLocker is a locker whose storage does not allow locking.
+type unavailableLocker struct {
+	driver.ReleaseLocker
+}
+
+func (unavailableLocker) AcquireLock(name, holder string, ttl time.Duration) error {
+	return errors.Wrapf(apierrors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, name, errors.New("no RBAC")), "lock: failed to get lease %q", name)
+}
+
+func TestUpgradeRelease_LocksUnavailable(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	upAction.cfg.Releases.Locker = unavailableLocker{upAction.cfg.Releases.Locker}
+	rel := releaseStub()
+	rel.Name = "unlocked"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	// The upgrade runs without lock rather than failing
+	res, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+	is.True(upAction.cfg.lockDisabled)
+	is.Empty(upAction.cfg.locks)
+}
+
+func TestLockRelease_WaitDoesNotBlockOtherReleases(t *testing.T) {
+	defer func(interval time.Duration) { lockPollInterval = interval }(lockPollInterval)
+	lockPollInterval = 10 * time.Millisecond
+
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	req.NoError(config.Releases.Locker.AcquireLock("busy", "other", time.Minute))
+
+	waited := make(chan error)
+	go func() {
+		_, err := config.lockRelease("busy", 500*time.Millisecond)
+		waited <- err
+	}()
+	time.Sleep(30 * time.Millisecond)
+
+	// Another release is locked while the first one is being waited for
+	start := time.Now()
+	unlock, err := config.lockRelease("idle", 0)
+	req.NoError(err)
+	unlock()
+	req.Less(int64(time.Since(start)), int64(250*time.Millisecond))
+
+	req.Error(<-waited)
+}
+
+func TestLockRelease_Lost(t *testing.T) {
+	defer func(ttl time.Duration) { lockTTL = ttl }(lockTTL)
+	lockTTL = 40 * time.Millisecond
+
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "stolen"
+	req.NoError(config.Releases.Create(rel))
+
+	unlock, err := config.lockRelease(rel.Name, 0)
+	req.NoError(err)
+	defer unlock()
+
+	// Another holder takes the lock over
+	req.NoError(config.Releases.Locker.BreakLock(rel.Name))
+	req.NoError(config.Releases.Locker.AcquireLock(rel.Name, "other", time.Minute))
+
+	deadline := time.Now().Add(time.Second)
+	for config.checkLock(rel.Name) == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	err = config.updateRelease(rel)
+	req.Error(err)
+	is.True(errors.Is(err, driver.ErrReleaseLocked))
+	is.Contains(err.Error(), "lost the lock of release stolen")
+}
@@ -0,0 +1,132 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+// Event types
+const (
+	// EventRenderFinished is sent when the manifest and the hooks of the
+	// release have been rendered.
+	EventRenderFinished EventType = "render-finished"
+	// EventHookStarted is sent when the resources of a hook are about to be created.
+	EventHookStarted EventType = "hook-started"
+	// EventHookFinished is sent when a hook succeeded or failed. Err is the
+	// error of a failed hook.
+	EventHookFinished EventType = "hook-finished"
+	// EventResourcesChanged is sent when resources of the release have been
+	// created, updated or deleted. Result holds the resources.
+	EventResourcesChanged EventType = "resources-changed"
+	// EventWaitStarted is sent before waiting for Resources to be ready.
+	EventWaitStarted EventType = "wait-started"
+	// EventWaitFinished is sent when Resources are ready, or Err if they are not.
+	EventWaitFinished EventType = "wait-finished"
+	// EventReleaseRecorded is sent when the release has been created or
+	// updated in the release storage.
+	EventReleaseRecorded EventType = "release-recorded"
+)
+
+func (x EventType) String() string { return string(x) }
+
+// Event is something that happened while running an action.
+type Event struct {
+	Type EventType
+	// Release is the release the action is working on. It is always set.
+	Release *release.Release
+	// Hook is the hook of hook events.
+	Hook *release.Hook
+	// Result holds the resources of resources-changed events.
+	Result *kube.Result
+	// Resources are the resources of wait events.
+	Resources kube.ResourceList
+	// Err is the error of hook-finished and wait-finished events, if they failed.
+	Err error
+}
+
+// Observer receives the events of the actions run with a Configuration.
+//
+// Events are delivered synchronously and one at a time, so Observe should
+// return quickly. The payloads belong to the action and must not be modified.
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc is a function implementing Observer.
+type ObserverFunc func(Event)
+
+// Observe calls f(e).
+func (f ObserverFunc) Observe(e Event) {
+	f(e)
+}
+
+// notify sends the event to the Observer, if any.
+func (cfg *Configuration) notify(e Event) {
+	if cfg.Observer == nil {
+		return
+	}
+	cfg.observerMu.Lock()
+	defer cfg.observerMu.Unlock()
+	cfg.Observer.Observe(e)
+}
+
+// notifyResourcesChanged sends a resources-changed event if result holds any
+// resources.
+func (cfg *Configuration) notifyResourcesChanged(rel *release.Release, result *kube.Result) {
+	if result == nil || len(result.Created)+len(result.Updated)+len(result.Deleted) == 0 {
+		return
+	}
+	cfg.notify(Event{Type: EventResourcesChanged, Release: rel, Result: result})
+}
+
+// createRelease creates the release in the release storage. If retention is
+// set, the revisions of the release history it does not keep are removed,
+// otherwise the limits of the storage apply. It fails if the lock of the
+// release was lost, since another operation may have recorded it.
+func (cfg *Configuration) createRelease(rel *release.Release, retention *storage.RetentionPolicy) error {
+	if err := cfg.checkLock(rel.Name); err != nil {
+		return err
+	}
+	create := cfg.Releases.Create
+	if retention != nil {
+		create = func(rel *release.Release) error { return cfg.Releases.CreateRetained(rel, *retention) }
+	}
+	if err := create(rel); err != nil {
+		return err
+	}
+	cfg.notify(Event{Type: EventReleaseRecorded, Release: rel})
+	return nil
+}
+
+// updateRelease updates the release in the release storage. It fails if the
+// lock of the release was lost, like createRelease.
+func (cfg *Configuration) updateRelease(rel *release.Release) error {
+	if err := cfg.checkLock(rel.Name); err != nil {
+		return err
+	}
+	if err := cfg.Releases.Update(rel); err != nil {
+		return err
+	}
+	cfg.notify(Event{Type: EventReleaseRecorded, Release: rel})
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/chart"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// recordObserver records the events other than release-recorded, and the
+// statuses of the recorded releases.
+type recordObserver struct {
+	events   []string
+	recorded []release.Status
+}
+
+func (o *recordObserver) Observe(e Event) {
+	switch e.Type {
+	case EventReleaseRecorded:
+		o.recorded = append(o.recorded, e.Release.Info.Status)
+	case EventHookStarted:
+		o.events = append(o.events, fmt.Sprintf("%s %s", e.Type, e.Hook.Name))
+	case EventHookFinished:
+		o.events = append(o.events, fmt.Sprintf("%s %s %v", e.Type, e.Hook.Name, e.Err))
+	case EventResourcesChanged:
+		o.events = append(o.events, fmt.Sprintf("%s created=%d updated=%d deleted=%d", e.Type, len(e.Result.Created), len(e.Result.Updated), len(e.Result.Deleted)))
+	case EventWaitStarted, EventWaitFinished:
+		o.events = append(o.events, fmt.Sprintf("%s %d", e.Type, len(e.Resources)))
+	default:
+		o.events = append(o.events, e.Type.String())
+	}
+}
+
+func TestInstallRelease_Observer(t *testing.T) {
+	instAction := installAction(t)
+	instAction.cfg.KubeClient = &waveRecordingKubeClient{t: t, PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+	instAction.Wait = true
+	observer := &recordObserver{}
+	instAction.cfg.Observer = observer
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"app": "", "db": "-1"}))
+	chrt.Templates = append(chrt.Templates, &chart.File{Name: "templates/hooks", Data: []byte(manifestWithHook)})
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"render-finished",
+		"resources-changed created=1 updated=0 deleted=0",
+		"wait-started 1",
+		"wait-finished 1",
+		"resources-changed created=1 updated=0 deleted=0",
+		"wait-started 2",
+		"wait-finished 2",
+		"hook-started test-cm",
+		"hook-finished test-cm <nil>",
+	}, observer.events)
+	require.NotEmpty(t, observer.recorded)
+	assert.Equal(t, release.StatusPendingInstall, observer.recorded[0])
+	assert.Equal(t, release.StatusDeployed, observer.recorded[len(observer.recorded)-1])
+}
+
+func TestUninstallRelease_Observer(t *testing.T) {
+	unAction := uninstallAction(t)
+	unAction.cfg.KubeClient = &waveRecordingKubeClient{t: t}
+	unAction.DisableHooks = true
+	var events []Event
+	unAction.cfg.Observer = ObserverFunc(func(e Event) {
+		events = append(events, e)
+	})
+
+	rel := releaseStub()
+	rel.Manifest = configMapManifest("app", "")
+	require.NoError(t, unAction.cfg.Releases.Create(rel))
+
+	_, err := unAction.Run(rel.Name)
+	require.NoError(t, err)
+
+	var deleted []string
+	for _, e := range events {
+		assert.Equal(t, rel.Name, e.Release.Name)
+		if e.Type == EventResourcesChanged {
+			for _, r := range e.Result.Deleted {
+				deleted = append(deleted, r.Name)
+			}
+		}
+	}
+	assert.Equal(t, []string{"app"}, deleted)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// planAPIVersion is the version of the plan format.
+const planAPIVersion = "v1"
+
+// The files of a plan. The plan index is the first file of the archive and
+// the release is what is applied; the other files are there for reviewers.
+const (
+	planIndexFile    = "plan.json"
+	planReleaseFile  = "release.json"
+	planManifestFile = "manifest.yaml"
+	planHooksFile    = "hooks.yaml"
+	planValuesFile   = "values.yaml"
+)
+
+// Plan is an upgrade rendered by 'helm upgrade --plan-out', to be reviewed
+// and then executed as it is by 'helm apply'.
+type Plan struct {
+	APIVersion string    `json:"apiVersion"`
+	Generated  time.Time `json:"generated"`
+	Name       string    `json:"name"`
+	Namespace  string    `json:"namespace"`
+	// BaseRevision is the last revision of the release when the plan was
+	// made. The plan can only be applied on top of it.
+	BaseRevision int `json:"baseRevision"`
+	// Revision is the revision the plan creates.
+	Revision int `json:"revision"`
+	// Chart is the name and version of the chart.
+	Chart string `json:"chart"`
+	// ChartDigest is the SHA-256 checksum of the chart the plan was rendered
+	// from, as it is recorded in the release.
+	ChartDigest string     `json:"chartDigest"`
+	Files       []PlanFile `json:"files"`
+
+	// Release is the upgraded release, with its rendered manifest and hooks.
+	Release *release.Release `json:"-"`
+	// Values are the values of the release coalesced with the ones of the
+	// chart.
+	Values map[string]interface{} `json:"-"`
+}
+
+// PlanFile describes a file of a plan.
+type PlanFile struct {
+	Path string `json:"path"`
+	// Digest is the SHA-256 checksum of the file.
+	Digest string `json:"digest"`
+}
+
+// newPlan returns the plan to upgrade a release from baseRevision to rel.
+func newPlan(rel *release.Release, baseRevision int, now time.Time) (*Plan, error) {
+	digest, err := chartDigest(rel.Chart)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compute the digest of the chart")
+	}
+	vals, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{
+		APIVersion:   planAPIVersion,
+		Generated:    now,
+		Name:         rel.Name,
+		Namespace:    rel.Namespace,
+		BaseRevision: baseRevision,
+		Revision:     rel.Version,
+		Chart:        fmt.Sprintf("%s-%s", rel.Chart.Name(), rel.Chart.Metadata.Version),
+		ChartDigest:  digest,
+		Release:      rel,
+		Values:       vals,
+	}, nil
+}
+
+// chartDigest returns the SHA-256 checksum of a chart as it is recorded in a
+// release. Releases do not record the dependencies of their chart, whose
+// templates are rendered in the manifest, so they are not part of it.
+func chartDigest(ch *chart.Chart) (string, error) {
+	data, err := json.Marshal(ch)
+	if err != nil {
+		return "", err
+	}
+	return sha256Digest(data), nil
+}
+
+// verifyChart checks that the chart of the release of the plan is the chart
+// the plan was made from.
+func (p *Plan) verifyChart() error {
+	if p.Release.Chart == nil {
+		return errors.New("the release of the plan has no chart")
+	}
+	digest, err := chartDigest(p.Release.Chart)
+	if err != nil {
+		return errors.Wrap(err, "unable to compute the digest of the chart")
+	}
+	if digest != p.ChartDigest {
+		return errors.Errorf("the chart of the release does not match the chart digest %s of the plan", p.ChartDigest)
+	}
+	return nil
+}
+
+// Write writes the plan to w as a gzipped tarball.
+func (p *Plan) Write(w io.Writer) error {
+	releaseData, err := json.Marshal(archivedRelease{Release: p.Release, Labels: p.Release.Labels})
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode release %s revision %d", p.Release.Name, p.Release.Version)
+	}
+	valuesData, err := yaml.Marshal(p.Values)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the values")
+	}
+	var hooks strings.Builder
+	for _, h := range p.Release.Hooks {
+		fmt.Fprintf(&hooks, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+
+	files := []tarballFile{
+		{planReleaseFile, releaseData},
+		{planManifestFile, []byte(p.Release.Manifest)},
+		{planHooksFile, []byte(hooks.String())},
+		{planValuesFile, valuesData},
+	}
+	p.Files = nil
+	for _, f := range files {
+		p.Files = append(p.Files, PlanFile{Path: f.name, Digest: sha256Digest(f.data)})
+	}
+	indexData, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeTarball(w, append([]tarballFile{{planIndexFile, indexData}}, files...), p.Generated)
+}
+
+// ReadPlan reads a plan written by Plan.Write. The checksum of every file of
+// the plan, and the digest of the chart of the release, are verified.
+func ReadPlan(r io.Reader) (*Plan, error) {
+	files, err := readTarball(r, "plan")
+	if err != nil {
+		return nil, err
+	}
+
+	indexData, ok := files[planIndexFile]
+	if !ok {
+		return nil, errors.Errorf("plan has no %s", planIndexFile)
+	}
+	p := &Plan{}
+	if err := json.Unmarshal(indexData, p); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", planIndexFile)
+	}
+	if p.APIVersion != planAPIVersion {
+		return nil, errors.Errorf("unsupported plan version %q", p.APIVersion)
+	}
+
+	listed := map[string]bool{}
+	for _, f := range p.Files {
+		data, ok := files[f.Path]
+		if !ok {
+			return nil, errors.Errorf("plan is missing %s", f.Path)
+		}
+		if sha256Digest(data) != f.Digest {
+			return nil, errors.Errorf("checksum mismatch for %s: the plan is corrupted", f.Path)
+		}
+		listed[f.Path] = true
+	}
+	for _, name := range []string{planReleaseFile, planManifestFile, planValuesFile} {
+		if !listed[name] {
+			return nil, errors.Errorf("plan does not list %s", name)
+		}
+	}
+
+	p.Release, err = decodeArchivedRelease(files[planReleaseFile])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode %s", planReleaseFile)
+	}
+	if err := yaml.Unmarshal(files[planValuesFile], &p.Values); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode %s", planValuesFile)
+	}
+
+	rel := p.Release
+	if rel.Name != p.Name || rel.Namespace != p.Namespace || rel.Version != p.Revision || p.Revision != p.BaseRevision+1 {
+		return nil, errors.Errorf("%s does not contain release %s/%s revision %d", planReleaseFile, p.Namespace, p.Name, p.Revision)
+	}
+	if rel.Manifest != string(files[planManifestFile]) {
+		return nil, errors.Errorf("%s does not match the manifest of the release", planManifestFile)
+	}
+	if rel.Info == nil {
+		return nil, errors.Errorf("%s has no release info", planReleaseFile)
+	}
+	if err := p.verifyChart(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// planUpgrade makes a plan to upgrade a deployed release to a chart with the
+// given config maps.
+func planUpgrade(t *testing.T, names ...string) (*Upgrade, *release.Release, []byte) {
+	t.Helper()
+	upAction := upgradeAction(t)
+	upAction.cfg.KubeClient = &waveRecordingKubeClient{t: t}
+
+	rel := releaseStub()
+	rel.Name = "planned"
+	rel.Namespace = "spaced"
+	rel.Manifest = configMapManifest("app", "")
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	waves := map[string]string{}
+	for _, name := range names {
+		waves[name] = ""
+	}
+	var buf bytes.Buffer
+	upAction.PlanOut = &buf
+	ch := buildChart(withWaveTemplates(waves), withValues(map[string]interface{}{"replicas": 1}))
+	res, err := upAction.Run(rel.Name, ch, map[string]interface{}{"image": "nginx"})
+	require.NoError(t, err)
+	assert.Equal(t, "Plan written", res.Info.Description)
+	return upAction, rel, buf.Bytes()
+}
+
+func TestUpgradeRelease_PlanOut(t *testing.T) {
+	upAction, rel, data := planUpgrade(t, "app", "web")
+
+	assert.Empty(t, upAction.cfg.KubeClient.(*waveRecordingKubeClient).ops, "making a plan should not touch the cluster")
+	last, err := upAction.cfg.Releases.Last(rel.Name)
+	require.NoError(t, err)
+	assert.Equal(t, rel.Version, last.Version, "making a plan should not store the release")
+
+	plan, err := ReadPlan(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "planned", plan.Name)
+	assert.Equal(t, "spaced", plan.Namespace)
+	assert.Equal(t, 1, plan.BaseRevision)
+	assert.Equal(t, 2, plan.Revision)
+	assert.Equal(t, "hello-0.1.0", plan.Chart)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", plan.ChartDigest)
+	assert.Equal(t, map[string]interface{}{"image": "nginx", "replicas": float64(1)}, plan.Values)
+	assert.Contains(t, plan.Release.Manifest, "name: app")
+	assert.Contains(t, plan.Release.Manifest, "name: web")
+
+	t.Run("dry run", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		upAction.PlanOut = &bytes.Buffer{}
+		upAction.DryRun = true
+		_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+		assert.EqualError(t, err, "a plan cannot be made during a dry run")
+	})
+}
+
+func TestUpgradeRelease_ApplyPlan(t *testing.T) {
+	upAction, rel, data := planUpgrade(t, "app", "web")
+	plan, err := ReadPlan(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	client := &waveRecordingKubeClient{t: t}
+	upAction.cfg.KubeClient = client
+	applyAction := NewUpgrade(upAction.cfg)
+	applyAction.Namespace = "spaced"
+	applyAction.Wait = true
+	res, err := applyAction.ApplyPlan(context.Background(), plan)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"update app,web", "wait app,web"}, client.ops)
+	assert.Equal(t, release.StatusDeployed, res.Info.Status)
+	assert.Equal(t, "Upgrade complete", res.Info.Description)
+
+	stored, err := upAction.cfg.Releases.Get(rel.Name, 2)
+	require.NoError(t, err)
+	assert.Equal(t, plan.Release.Manifest, stored.Manifest)
+	assert.Equal(t, release.StatusDeployed, stored.Info.Status)
+	assert.Equal(t, rel.Info.FirstDeployed.Unix(), stored.Info.FirstDeployed.Unix())
+	previous, err := upAction.cfg.Releases.Get(rel.Name, 1)
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusSuperseded, previous.Info.Status)
+
+	t.Run("release moved past the base revision", func(t *testing.T) {
+		plan, err := ReadPlan(bytes.NewReader(data))
+		require.NoError(t, err)
+		_, err = applyAction.ApplyPlan(context.Background(), plan)
+		assert.EqualError(t, err, `release "planned" is at revision 2 but the plan was made for revision 1: make a new plan`)
+	})
+
+	t.Run("other chart", func(t *testing.T) {
+		plan, err := ReadPlan(bytes.NewReader(data))
+		require.NoError(t, err)
+		ch := *plan.Release.Chart
+		metadata := *ch.Metadata
+		metadata.Version = "0.2.0"
+		ch.Metadata = &metadata
+		plan.Release.Chart = &ch
+		_, err = applyAction.ApplyPlan(context.Background(), plan)
+		assert.EqualError(t, err, "the chart of the release does not match the chart digest "+plan.ChartDigest+" of the plan")
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		applyAction := NewUpgrade(upAction.cfg)
+		applyAction.Namespace = "default"
+		_, err := applyAction.ApplyPlan(context.Background(), plan)
+		assert.EqualError(t, err, `the plan is for namespace "spaced", not "default"`)
+	})
+}
+
+func TestReadPlan_Corrupted(t *testing.T) {
+	_, _, data := planUpgrade(t, "app")
+	files, err := readTarball(bytes.NewReader(data), "plan")
+	require.NoError(t, err)
+
+	rewrite := func(change func(files map[string][]byte)) []byte {
+		copied := map[string][]byte{}
+		for name, data := range files {
+			copied[name] = data
+		}
+		change(copied)
+		tarball := []tarballFile{{planIndexFile, copied[planIndexFile]}}
+		for _, name := range []string{planReleaseFile, planManifestFile, planHooksFile, planValuesFile} {
+			if data, ok := copied[name]; ok {
+				tarball = append(tarball, tarballFile{name, data})
+			}
+		}
+		var buf bytes.Buffer
+		require.NoError(t, writeTarball(&buf, tarball, time.Now()))
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name   string
+		change func(files map[string][]byte)
+		err    string
+	}{
+		{
+			name: "tampered manifest",
+			change: func(files map[string][]byte) {
+				files[planManifestFile] = append(files[planManifestFile], "data:\n  injected: \"true\"\n"...)
+			},
+			err: "checksum mismatch for manifest.yaml: the plan is corrupted",
+		},
+		{
+			name: "tampered release",
+			change: func(files map[string][]byte) {
+				files[planReleaseFile] = bytes.Replace(files[planReleaseFile], []byte("name: app"), []byte("name: evil"), 1)
+			},
+			err: "checksum mismatch for release.json: the plan is corrupted",
+		},
+		{
+			name:   "missing file",
+			change: func(files map[string][]byte) { delete(files, planValuesFile) },
+			err:    "plan is missing values.yaml",
+		},
+		{
+			name: "chart digest mismatch",
+			change: func(files map[string][]byte) {
+				files[planIndexFile] = regexp.MustCompile(`"chartDigest": "sha256:[0-9a-f]+"`).
+					ReplaceAll(files[planIndexFile], []byte(`"chartDigest": "sha256:0000"`))
+			},
+			err: "the chart of the release does not match the chart digest sha256:0000 of the plan",
+		},
+		{
+			name: "unsupported version",
+			change: func(files map[string][]byte) {
+				files[planIndexFile] = bytes.Replace(files[planIndexFile], []byte(`"v1"`), []byte(`"v2"`), 1)
+			},
+			err: `unsupported plan version "v2"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadPlan(bytes.NewReader(rewrite(tt.change)))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	_, err = ReadPlan(bytes.NewReader([]byte("not a plan")))
+	assert.Error(t, err)
+}
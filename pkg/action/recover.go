@@ -0,0 +1,146 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// Recover is the action for recovering a release whose latest revision was
+// left in a pending state by an interrupted operation.
+//
+// It provides the implementation of 'helm release recover'.
+type Recover struct {
+	cfg *Configuration
+
+	// Rollback rolls back to the last deployed revision if the pending
+	// revision was not fully applied, instead of only marking it failed.
+	Rollback bool
+	// Timeout, Wait and WaitForJobs are used for the rollback.
+	Timeout     time.Duration
+	Wait        bool
+	WaitForJobs bool
+	// WaitForLock is how long to wait for the release lock if another operation holds it
+	WaitForLock time.Duration
+
+	// drift returns the resources of a release that differ from its manifest
+	drift func(rel *release.Release) ([]ResourceDrift, error)
+}
+
+// NewRecover creates a new Recover object with the given configuration.
+func NewRecover(cfg *Configuration) *Recover {
+	return &Recover{
+		cfg:   cfg,
+		drift: NewDrift(cfg).compare,
+	}
+}
+
+// Run recovers the named release and returns its latest revision.
+//
+// The live resources are compared with the manifest of the pending revision.
+// If they all match, the operation got as far as applying every resource and
+// the revision is marked deployed. Otherwise it is marked failed and, if
+// Rollback is set, the release is rolled back to the last deployed revision.
+func (r *Recover) Run(name string) (*release.Release, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	// An operation that is still running holds the lock, so this fails
+	// instead of recovering a release that is not stuck.
+	unlock, err := r.cfg.lockRelease(name, r.WaitForLock)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	rel, err := r.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+	status := rel.Info.Status
+	if !status.IsPending() {
+		return nil, errors.Errorf("release %q is not pending: revision %d is %s", name, rel.Version, status)
+	}
+
+	r.cfg.Log("comparing the resources of %s with revision %d", name, rel.Version)
+	drifts, err := r.drift(rel)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compare the release with the cluster")
+	}
+
+	if len(drifts) == 0 {
+		return rel, r.markDeployed(rel)
+	}
+
+	rel.SetStatus(release.StatusFailed, fmt.Sprintf("Recovered from %s: the operation was interrupted, %d resource(s) do not match the release manifest", status, len(drifts)))
+	r.cfg.Log("marking revision %d of %s as failed", rel.Version, name)
+	if err := r.cfg.updateRelease(rel); err != nil {
+		return nil, err
+	}
+	if !r.Rollback {
+		return rel, nil
+	}
+
+	deployed, err := r.cfg.Releases.Deployed(name)
+	if err != nil {
+		return rel, errors.Wrapf(err, "release %q has no deployed revision to roll back to", name)
+	}
+	rollback := NewRollback(r.cfg)
+	rollback.Version = deployed.Version
+	rollback.Timeout = r.Timeout
+	rollback.Wait = r.Wait
+	rollback.WaitForJobs = r.WaitForJobs
+	if err := rollback.Run(name); err != nil {
+		return rel, errors.Wrapf(err, "failed to roll back to revision %d", deployed.Version)
+	}
+	return r.cfg.Releases.Last(name)
+}
+
+// markDeployed marks the pending revision deployed and supersedes the revisions
+// that were deployed before it.
+func (r *Recover) markDeployed(rel *release.Release) error {
+	deployed, err := r.cfg.Releases.DeployedAll(rel.Name)
+	if err != nil && !errors.Is(err, driver.ErrNoDeployedReleases) {
+		return err
+	}
+	for _, d := range deployed {
+		if d.Version == rel.Version {
+			continue
+		}
+		r.cfg.Log("superseding revision %d of %s", d.Version, rel.Name)
+		d.Info.Status = release.StatusSuperseded
+		if err := r.cfg.updateRelease(d); err != nil {
+			return err
+		}
+	}
+
+	rel.SetStatus(release.StatusDeployed, fmt.Sprintf("Recovered from %s: all resources match the release manifest", rel.Info.Status))
+	r.cfg.Log("marking revision %d of %s as deployed", rel.Version, rel.Name)
+	return r.cfg.updateRelease(rel)
+}
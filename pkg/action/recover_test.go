@@ -0,0 +1,131 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func recoverAction(t *testing.T, drifts ...ResourceDrift) *Recover {
+	config := actionConfigFixture(t)
+	rec := NewRecover(config)
+	rec.drift = func(*release.Release) ([]ResourceDrift, error) { return drifts, nil }
+	return rec
+}
+
+func pendingUpgradeFixture(t *testing.T, cfg *Configuration) {
+	t.Helper()
+	deployed := releaseStub()
+	deployed.Name = "stuck"
+	deployed.Version = 1
+	deployed.Info.Status = release.StatusDeployed
+	pending := releaseStub()
+	pending.Name = "stuck"
+	pending.Version = 2
+	pending.Info.Status = release.StatusPendingUpgrade
+	require.NoError(t, cfg.Releases.Create(deployed))
+	require.NoError(t, cfg.Releases.Create(pending))
+}
+
+func TestRecover_Deployed(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	rec := recoverAction(t)
+	pendingUpgradeFixture(t, rec.cfg)
+
+	res, err := rec.Run("stuck")
+	req.NoError(err)
+	is.Equal(2, res.Version)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+	is.Equal("Recovered from pending-upgrade: all resources match the release manifest", res.Info.Description)
+
+	previous, err := rec.cfg.Releases.Get("stuck", 1)
+	req.NoError(err)
+	is.Equal(release.StatusSuperseded, previous.Info.Status)
+}
+
+func TestRecover_Failed(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	rec := recoverAction(t, ResourceDrift{Kind: "Pod", Name: "missing", Missing: true})
+	pendingUpgradeFixture(t, rec.cfg)
+
+	res, err := rec.Run("stuck")
+	req.NoError(err)
+	is.Equal(2, res.Version)
+	is.Equal(release.StatusFailed, res.Info.Status)
+	is.Equal("Recovered from pending-upgrade: the operation was interrupted, 1 resource(s) do not match the release manifest", res.Info.Description)
+
+	stored, err := rec.cfg.Releases.Get("stuck", 2)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, stored.Info.Status)
+
+	previous, err := rec.cfg.Releases.Get("stuck", 1)
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, previous.Info.Status)
+}
+
+func TestRecover_Rollback(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	rec := recoverAction(t, ResourceDrift{Kind: "Pod", Name: "missing", Missing: true})
+	rec.Rollback = true
+	pendingUpgradeFixture(t, rec.cfg)
+
+	res, err := rec.Run("stuck")
+	req.NoError(err)
+	is.Equal(3, res.Version)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+	is.Equal("Rollback to 1", res.Info.Description)
+
+	failed, err := rec.cfg.Releases.Get("stuck", 2)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, failed.Info.Status)
+}
+
+func TestRecover_NotPending(t *testing.T) {
+	req := require.New(t)
+
+	rec := recoverAction(t)
+	rel := releaseStub()
+	req.NoError(rec.cfg.Releases.Create(rel))
+
+	_, err := rec.Run(rel.Name)
+	req.Error(err)
+	req.Contains(err.Error(), "is not pending")
+}
+
+func TestRecover_Locked(t *testing.T) {
+	req := require.New(t)
+
+	rec := recoverAction(t)
+	pendingUpgradeFixture(t, rec.cfg)
+	req.NoError(rec.cfg.Releases.Locker.AcquireLock("stuck", "ci@runner (pid 42)", time.Minute))
+
+	_, err := rec.Run("stuck")
+	req.Error(err)
+	req.Contains(err.Error(), "is locked by ci@runner (pid 42)")
+}
@@ -0,0 +1,267 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+)
+
+// releaseArchiveAPIVersion is the version of the release archive format.
+const releaseArchiveAPIVersion = "v1"
+
+// releaseArchiveIndexFile is the name of the archive index. It is the first
+// file of the archive.
+const releaseArchiveIndexFile = "index.json"
+
+// ReleaseArchiveIndex describes the revisions stored in a release archive.
+type ReleaseArchiveIndex struct {
+	APIVersion string                `json:"apiVersion"`
+	Generated  time.Time             `json:"generated"`
+	Revisions  []ReleaseArchiveEntry `json:"revisions"`
+}
+
+// ReleaseArchiveEntry describes a single revision of a release in an archive.
+type ReleaseArchiveEntry struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Status    string `json:"status"`
+	// Path is the location of the revision in the archive.
+	Path string `json:"path"`
+	// Digest is the SHA-256 checksum of the file at Path.
+	Digest string `json:"digest"`
+}
+
+// archivedRelease is a revision as it is stored in an archive. The labels of a
+// release are not part of its JSON encoding, so they are stored alongside it.
+type archivedRelease struct {
+	Release *release.Release  `json:"release"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+// encodeArchivedRelease encodes a revision and returns it with its checksum.
+func encodeArchivedRelease(rel *release.Release) ([]byte, string, error) {
+	data, err := json.Marshal(archivedRelease{Release: rel, Labels: rel.Labels})
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "unable to encode release %s revision %d", rel.Name, rel.Version)
+	}
+	return data, sha256Digest(data), nil
+}
+
+func decodeArchivedRelease(data []byte) (*release.Release, error) {
+	var a archivedRelease
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	if a.Release == nil {
+		return nil, errors.New("no release found")
+	}
+	a.Release.Labels = a.Labels
+	return a.Release, nil
+}
+
+// sortReleases sorts releases by namespace, name and version.
+func sortReleases(rels []*release.Release) {
+	sort.SliceStable(rels, func(i, j int) bool {
+		a, b := rels[i], rels[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+}
+
+// writeReleaseArchive writes the given revisions to w as a gzipped tarball.
+func writeReleaseArchive(w io.Writer, rels []*release.Release, now time.Time) (*ReleaseArchiveIndex, error) {
+	sortReleases(rels)
+
+	index := &ReleaseArchiveIndex{APIVersion: releaseArchiveAPIVersion, Generated: now}
+	files := make([][]byte, 0, len(rels))
+	for _, rel := range rels {
+		data, digest, err := encodeArchivedRelease(rel)
+		if err != nil {
+			return nil, err
+		}
+		index.Revisions = append(index.Revisions, ReleaseArchiveEntry{
+			Name:      rel.Name,
+			Namespace: rel.Namespace,
+			Version:   rel.Version,
+			Status:    rel.Info.Status.String(),
+			Path:      path.Join("releases", rel.Namespace, rel.Name, fmt.Sprintf("v%d.json", rel.Version)),
+			Digest:    digest,
+		})
+		files = append(files, data)
+	}
+	indexData, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	tarball := []tarballFile{{releaseArchiveIndexFile, indexData}}
+	for i, entry := range index.Revisions {
+		tarball = append(tarball, tarballFile{entry.Path, files[i]})
+	}
+	return index, writeTarball(w, tarball, now)
+}
+
+// tarballFile is a file of a gzipped tarball.
+type tarballFile struct {
+	name string
+	data []byte
+}
+
+// writeTarball writes the files to w as a gzipped tarball, in order.
+func writeTarball(w io.Writer, files []tarballFile, now time.Time) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  now,
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// readTarball returns the regular files of a gzipped tarball by name. what
+// names the kind of archive in errors.
+func readTarball(r io.Reader, what string) (map[string][]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not a gzipped tarball", what)
+	}
+	defer zr.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", what)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s from %s", hdr.Name, what)
+		}
+		files[hdr.Name] = data
+	}
+}
+
+// sha256Digest returns the SHA-256 checksum of data as "sha256:<hex>".
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// readReleaseArchive reads a release archive and returns its revisions in
+// the order of the index. The checksum of every revision is verified.
+func readReleaseArchive(r io.Reader) ([]*release.Release, error) {
+	files, err := readTarball(r, "release archive")
+	if err != nil {
+		return nil, err
+	}
+
+	indexData, ok := files[releaseArchiveIndexFile]
+	if !ok {
+		return nil, errors.Errorf("release archive has no %s", releaseArchiveIndexFile)
+	}
+	var index ReleaseArchiveIndex
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", releaseArchiveIndexFile)
+	}
+	if index.APIVersion != releaseArchiveAPIVersion {
+		return nil, errors.Errorf("unsupported release archive version %q", index.APIVersion)
+	}
+
+	rels := make([]*release.Release, 0, len(index.Revisions))
+	for _, entry := range index.Revisions {
+		data, ok := files[entry.Path]
+		if !ok {
+			return nil, errors.Errorf("release archive is missing %s", entry.Path)
+		}
+		if sha256Digest(data) != entry.Digest {
+			return nil, errors.Errorf("checksum mismatch for %s: the release archive is corrupted", entry.Path)
+		}
+		rel, err := decodeArchivedRelease(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode %s", entry.Path)
+		}
+		if rel.Name != entry.Name || rel.Namespace != entry.Namespace || rel.Version != entry.Version {
+			return nil, errors.Errorf("%s does not contain release %s/%s revision %d", entry.Path, entry.Namespace, entry.Name, entry.Version)
+		}
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+// checkReleaseConflicts returns an error listing the releases in rels that
+// already have revisions in store.
+func checkReleaseConflicts(store *storage.Storage, rels []*release.Release) error {
+	var conflicts []string
+	seen := map[string]bool{}
+	for _, rel := range rels {
+		if seen[rel.Name] {
+			continue
+		}
+		seen[rel.Name] = true
+		if h, err := store.History(rel.Name); err == nil && len(h) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%d revisions)", rel.Name, len(h)))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return errors.Errorf("releases already exist in the destination: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
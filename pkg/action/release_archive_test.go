@@ -0,0 +1,214 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// releaseHistoryFixture stores three revisions of a release and returns them.
+func releaseHistoryFixture(t *testing.T, store *storage.Storage, name string) []*release.Release {
+	t.Helper()
+	deployed := helmtime.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC)
+	statuses := []release.Status{release.StatusSuperseded, release.StatusFailed, release.StatusDeployed}
+
+	var rels []*release.Release
+	for i, status := range statuses {
+		rel := namedReleaseStub(name, status)
+		rel.Namespace = "default"
+		rel.Version = i + 1
+		rel.Info.FirstDeployed = deployed
+		rel.Info.LastDeployed = deployed.Add(time.Duration(i) * time.Hour)
+		rel.Labels = map[string]string{"team": "platform"}
+		require.NoError(t, store.Create(rel))
+		rels = append(rels, rel)
+	}
+	return rels
+}
+
+func TestReleaseExportImport(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	src := actionConfigFixture(t)
+	exported := releaseHistoryFixture(t, src.Releases, "migrated")
+	releaseHistoryFixture(t, src.Releases, "other")
+
+	var archive bytes.Buffer
+	index, err := NewReleaseExport(src).Run("migrated", &archive)
+	req.NoError(err)
+	req.Len(index.Revisions, 3)
+	is.Equal("releases/default/migrated/v2.json", index.Revisions[1].Path)
+	is.Equal("failed", index.Revisions[1].Status)
+
+	dst := actionConfigFixture(t)
+	imp := NewReleaseImport(dst)
+	imp.Namespace = "default"
+	imported, err := imp.Run(bytes.NewReader(archive.Bytes()))
+	req.NoError(err)
+	req.Len(imported, 3)
+
+	for _, want := range exported {
+		got, err := dst.Releases.Get(want.Name, want.Version)
+		req.NoError(err)
+		is.Equal(want.Info.Status, got.Info.Status)
+		is.True(want.Info.LastDeployed.Equal(got.Info.LastDeployed))
+		is.True(want.Info.FirstDeployed.Equal(got.Info.FirstDeployed))
+		is.Equal(want.Labels, got.Labels)
+		is.Equal(want.Manifest, got.Manifest)
+	}
+	_, err = dst.Releases.History("other")
+	is.Error(err, "expected only the named release to be exported")
+
+	// Importing again conflicts with the imported releases
+	_, err = imp.Run(bytes.NewReader(archive.Bytes()))
+	is.EqualError(err, "releases already exist in the destination: migrated (3 revisions)")
+}
+
+func TestReleaseExportAll(t *testing.T) {
+	req := require.New(t)
+
+	src := actionConfigFixture(t)
+	releaseHistoryFixture(t, src.Releases, "one")
+	releaseHistoryFixture(t, src.Releases, "two")
+
+	exp := NewReleaseExport(src)
+	exp.All = true
+	var archive bytes.Buffer
+	index, err := exp.Run("", &archive)
+	req.NoError(err)
+	req.Len(index.Revisions, 6)
+	req.Equal("one", index.Revisions[0].Name)
+	req.Equal("two", index.Revisions[5].Name)
+}
+
+func TestReleaseImport_Corrupted(t *testing.T) {
+	req := require.New(t)
+
+	src := actionConfigFixture(t)
+	releaseHistoryFixture(t, src.Releases, "corrupted")
+	var archive bytes.Buffer
+	_, err := NewReleaseExport(src).Run("corrupted", &archive)
+	req.NoError(err)
+
+	// Rewrite the archive with a modified revision
+	zr, err := gzip.NewReader(&archive)
+	req.NoError(err)
+	var tampered bytes.Buffer
+	zw := gzip.NewWriter(&tampered)
+	tr, tw := tar.NewReader(zr), tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		req.NoError(err)
+		data, err := ioutil.ReadAll(tr)
+		req.NoError(err)
+		if hdr.Name == "releases/default/corrupted/v2.json" {
+			data = bytes.Replace(data, []byte(`"failed"`), []byte(`"deployed"`), 1)
+			hdr.Size = int64(len(data))
+		}
+		req.NoError(tw.WriteHeader(hdr))
+		_, err = tw.Write(data)
+		req.NoError(err)
+	}
+	req.NoError(tw.Close())
+	req.NoError(zw.Close())
+
+	dst := actionConfigFixture(t)
+	imp := NewReleaseImport(dst)
+	imp.Namespace = "default"
+	_, err = imp.Run(&tampered)
+	req.EqualError(err, "checksum mismatch for releases/default/corrupted/v2.json: the release archive is corrupted")
+
+	_, err = dst.Releases.History("corrupted")
+	req.Error(err, "expected nothing to be imported")
+}
+
+func TestReleaseImport_WrongNamespace(t *testing.T) {
+	req := require.New(t)
+
+	src := actionConfigFixture(t)
+	releaseHistoryFixture(t, src.Releases, "elsewhere")
+	var archive bytes.Buffer
+	_, err := NewReleaseExport(src).Run("elsewhere", &archive)
+	req.NoError(err)
+
+	imp := NewReleaseImport(actionConfigFixture(t))
+	imp.Namespace = "production"
+	_, err = imp.Run(&archive)
+	req.EqualError(err, `release "elsewhere" belongs to namespace "default", not "production"`)
+}
+
+func TestStorageMigrate(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	from := storage.Init(driver.NewMemory())
+	to := storage.Init(driver.NewMemory())
+	releaseHistoryFixture(t, from, "one")
+	releaseHistoryFixture(t, from, "two")
+
+	migrate := NewStorageMigrate(config)
+	migrate.From = from
+	migrate.To = to
+
+	migrate.DryRun = true
+	migrated, err := migrate.Run()
+	req.NoError(err)
+	is.Len(migrated, 6)
+	_, err = to.History("one")
+	is.Error(err, "expected nothing to be migrated in a dry run")
+
+	migrate.DryRun = false
+	migrated, err = migrate.Run()
+	req.NoError(err)
+	is.Len(migrated, 6)
+	is.Equal("two", migrated[5].Name)
+	is.Equal(3, migrated[5].Version)
+	is.Contains(migrated[5].Digest, "sha256:")
+
+	h, err := to.History("two")
+	req.NoError(err)
+	is.Len(h, 3)
+
+	// The source is left untouched and unlocked
+	h, err = from.History("two")
+	req.NoError(err)
+	is.Len(h, 3)
+	_, err = from.Locker.GetLock("two")
+	is.Equal(driver.ErrLockNotFound, err)
+
+	_, err = migrate.Run()
+	is.EqualError(err, "releases already exist in the destination: one (3 revisions), two (3 revisions)")
+}
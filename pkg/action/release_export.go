@@ -0,0 +1,81 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package action
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseExport is the action for exporting the history of releases to an archive.
+//
+// It provides the implementation of 'helm release export'.
+type ReleaseExport struct {
+	cfg *Configuration
+
+	// All exports every release in the storage instead of a single one.
+	All bool
+}
+
+// NewReleaseExport creates a new ReleaseExport object with the given configuration.
+func NewReleaseExport(cfg *Configuration) *ReleaseExport {
+	return &ReleaseExport{
+		cfg: cfg,
+	}
+}
+
+// Run writes every revision of the named release, or of all releases if All
+// is set, to w as a gzipped tarball. It returns the index of the archive.
+func (r *ReleaseExport) Run(name string, w io.Writer) (*ReleaseArchiveIndex, error) {
+	var rels []*release.Release
+	if r.All {
+		all, err := r.cfg.Releases.ListReleases()
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, rel := range all {
+			if seen[rel.Name] {
+				continue
+			}
+			seen[rel.Name] = true
+			h, err := r.cfg.Releases.History(rel.Name)
+			if err != nil {
+				return nil, err
+			}
+			rels = append(rels, h...)
+		}
+	} else {
+		if err := chartutil.ValidateReleaseName(name); err != nil {
+			return nil, errors.Errorf("release name is invalid: %s", name)
+		}
+		h, err := r.cfg.Releases.History(name)
+		if err != nil {
+			return nil, err
+		}
+		rels = h
+	}
+	if len(rels) == 0 {
+		return nil, errors.New("no releases to export")
+	}
+
+	r.cfg.Log("exporting %d revisions", len(rels))
+	return writeReleaseArchive(w, rels, r.cfg.Now().Time)
+}
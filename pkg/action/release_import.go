@@ -0,0 +1,96 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package action
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseImport is the action for importing the history of releases from an
+// archive created by ReleaseExport.
+//
+// It provides the implementation of 'helm release import'.
+type ReleaseImport struct {
+	cfg *Configuration
+
+	// Namespace is the namespace of the storage the releases are imported
+	// into. Every release in the archive must belong to it.
+	Namespace string
+	// DryRun verifies the archive and checks for conflicts without importing.
+	DryRun bool
+	// WaitForLock is how long to wait for the lock of each release if another operation holds it
+	WaitForLock time.Duration
+}
+
+// NewReleaseImport creates a new ReleaseImport object with the given configuration.
+func NewReleaseImport(cfg *Configuration) *ReleaseImport {
+	return &ReleaseImport{
+		cfg: cfg,
+	}
+}
+
+// Run imports every revision in the archive read from r and returns them.
+//
+// The revisions are stored as they are, with their status, labels and
+// timestamps. Nothing is imported if the archive is corrupted or if any of
+// its releases already exists in the storage.
+func (r *ReleaseImport) Run(in io.Reader) ([]*release.Release, error) {
+	rels, err := readReleaseArchive(in)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rel := range rels {
+		if rel.Namespace != r.Namespace {
+			return nil, errors.Errorf("release %q belongs to namespace %q, not %q", rel.Name, rel.Namespace, r.Namespace)
+		}
+	}
+
+	if !r.DryRun {
+		locked := map[string]bool{}
+		for _, rel := range rels {
+			if locked[rel.Name] {
+				continue
+			}
+			locked[rel.Name] = true
+			unlock, err := r.cfg.lockRelease(rel.Name, r.WaitForLock)
+			if err != nil {
+				return nil, err
+			}
+			defer unlock()
+		}
+	}
+
+	if err := checkReleaseConflicts(r.cfg.Releases, rels); err != nil {
+		return nil, err
+	}
+	if r.DryRun {
+		return rels, nil
+	}
+
+	for _, rel := range rels {
+		r.cfg.Log("importing release %s revision %d", rel.Name, rel.Version)
+		if err := r.cfg.createRelease(rel, nil); err != nil {
+			return nil, errors.Wrapf(err, "failed to import release %s revision %d", rel.Name, rel.Version)
+		}
+	}
+	return rels, nil
+}
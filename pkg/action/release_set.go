@@ -0,0 +1,185 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ReleaseSet is a list of releases to install or upgrade together, read from
+// a file by 'helm sync'.
+type ReleaseSet struct {
+	Releases []*ReleaseSpec `json:"releases"`
+}
+
+// ReleaseSpec describes a release of a release set.
+type ReleaseSpec struct {
+	// Name is the name of the release.
+	Name string `json:"name"`
+	// Namespace is the namespace of the release. It defaults to the namespace
+	// of the sync.
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is a chart reference, like 'repo/chart', or a path to a chart.
+	// Relative paths are relative to the release set file.
+	Chart string `json:"chart"`
+	// Version is the version constraint of the chart.
+	Version string `json:"version,omitempty"`
+	// Values are values files, relative to the release set file. Later files
+	// take precedence.
+	Values []string `json:"values,omitempty"`
+	// Set are values that take precedence over the values files.
+	Set map[string]interface{} `json:"set,omitempty"`
+	// Labels are used to select releases of the set.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Needs are the releases that must be synced successfully before this
+	// one, as 'name' for a release of the same namespace or 'namespace/name'.
+	Needs []string `json:"needs,omitempty"`
+}
+
+// key identifies a release of a set.
+func (r *ReleaseSpec) key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// needKey returns the key of a release needed by r.
+func (r *ReleaseSpec) needKey(need string) string {
+	if strings.Contains(need, "/") {
+		return need
+	}
+	return r.Namespace + "/" + need
+}
+
+// LoadReleaseSet reads a release set from a file. Relative paths of charts and
+// values files are resolved against the directory of the file.
+func LoadReleaseSet(filename string) (*ReleaseSet, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	set := &ReleaseSet{}
+	if err := yaml.UnmarshalStrict(data, set); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse release set %s", filename)
+	}
+
+	dir := filepath.Dir(filename)
+	for _, r := range set.Releases {
+		if strings.HasPrefix(r.Chart, "./") || strings.HasPrefix(r.Chart, "../") {
+			r.Chart = filepath.Join(dir, r.Chart)
+		}
+		for i, f := range r.Values {
+			if !filepath.IsAbs(f) {
+				r.Values[i] = filepath.Join(dir, f)
+			}
+		}
+	}
+	return set, nil
+}
+
+// validate defaults the namespaces of the releases to namespace and checks
+// that the releases are unique, that they only need releases of the set, and
+// that they do not need each other in a cycle.
+func (s *ReleaseSet) validate(namespace string) error {
+	byKey := map[string]*ReleaseSpec{}
+	for _, r := range s.Releases {
+		if err := chartutil.ValidateReleaseName(r.Name); err != nil {
+			return errors.Errorf("release name is invalid: %s", r.Name)
+		}
+		if r.Chart == "" {
+			return errors.Errorf("release %s has no chart", r.Name)
+		}
+		if r.Namespace == "" {
+			r.Namespace = namespace
+		}
+		if _, ok := byKey[r.key()]; ok {
+			return errors.Errorf("release %s is listed more than once", r.key())
+		}
+		byKey[r.key()] = r
+	}
+	for _, r := range s.Releases {
+		for _, need := range r.Needs {
+			if _, ok := byKey[r.needKey(need)]; !ok {
+				return errors.Errorf("release %s needs %s, which is not in the release set", r.key(), need)
+			}
+		}
+	}
+
+	// Depth-first search for a release that needs itself
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(r *ReleaseSpec) error
+	visit = func(r *ReleaseSpec) error {
+		switch state[r.key()] {
+		case visiting:
+			for i, key := range path {
+				if key == r.key() {
+					return errors.Errorf("release set has a dependency cycle: %s", strings.Join(append(path[i:], key), " -> "))
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[r.key()] = visiting
+		path = append(path, r.key())
+		for _, need := range r.Needs {
+			if err := visit(byKey[r.needKey(need)]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[r.key()] = visited
+		return nil
+	}
+	for _, r := range s.Releases {
+		if err := visit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectReleases returns the releases of the set matching the label selector.
+// The name and namespace of a release can be selected as the "name" and
+// "namespace" labels.
+func (s *ReleaseSet) selectReleases(selector string) ([]*ReleaseSpec, error) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid selector %q", selector)
+	}
+	var selected []*ReleaseSpec
+	for _, r := range s.Releases {
+		set := labels.Set{"name": r.Name, "namespace": r.Namespace}
+		for k, v := range r.Labels {
+			set[k] = v
+		}
+		if sel.Matches(set) {
+			selected = append(selected, r)
+		}
+	}
+	return selected, nil
+}
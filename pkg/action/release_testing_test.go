@@ -0,0 +1,92 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func releaseTestingAction(t *testing.T) *ReleaseTesting {
+	config := actionConfigFixture(t)
+	return NewReleaseTesting(config)
+}
+
+func TestReleaseTesting_Run(t *testing.T) {
+	is := assert.New(t)
+
+	client := releaseTestingAction(t)
+	rel := releaseStub()
+	is.NoError(client.cfg.Releases.Create(rel))
+
+	res, err := client.Run(rel.Name)
+	is.NoError(err)
+
+	stored, err := client.cfg.Releases.Get(rel.Name, rel.Version)
+	is.NoError(err)
+	for _, r := range []*release.Release{res, stored} {
+		for _, h := range r.Hooks {
+			if h.Name == "finding-nemo" {
+				is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase)
+				is.False(h.LastRun.StartedAt.IsZero())
+			}
+		}
+	}
+}
+
+func TestReleaseTesting_RunFilters(t *testing.T) {
+	is := assert.New(t)
+
+	rel := releaseStub()
+	rel.Hooks = append(rel.Hooks, &release.Hook{
+		Name:     "finding-dory",
+		Kind:     "Pod",
+		Path:     "finding-dory",
+		Manifest: manifestWithTestHook,
+		Events:   []release.HookEvent{release.HookTest},
+	})
+
+	for _, tt := range []struct {
+		name     string
+		filters  map[string][]string
+		executed []string
+	}{
+		{"include", map[string][]string{"name": {"finding-dory"}}, []string{"finding-dory"}},
+		{"exclude", map[string][]string{"!name": {"finding-dory"}}, []string{"finding-nemo"}},
+		{"none", map[string][]string{}, []string{"finding-nemo", "finding-dory"}},
+	} {
+		client := releaseTestingAction(t)
+		is.NoError(client.cfg.Releases.Create(rel))
+		client.Filters = tt.filters
+
+		res, err := client.Run(rel.Name)
+		is.NoError(err, tt.name)
+
+		var executed []string
+		for _, h := range res.Hooks {
+			if !h.LastRun.StartedAt.IsZero() {
+				executed = append(executed, h.Name)
+			}
+			// Reset the shared stub for the next case
+			h.LastRun = release.HookExecution{}
+		}
+		is.ElementsMatch(tt.executed, executed, tt.name)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// ReleaseUnlock is the action for breaking the lock of a release.
+//
+// It provides the implementation of 'helm release unlock'.
+type ReleaseUnlock struct {
+	cfg *Configuration
+}
+
+// NewReleaseUnlock creates a new ReleaseUnlock object with the given configuration.
+func NewReleaseUnlock(cfg *Configuration) *ReleaseUnlock {
+	return &ReleaseUnlock{
+		cfg: cfg,
+	}
+}
+
+// Run removes the lock of the named release, regardless of which operation
+// holds it, and returns the removed lock.
+//
+// This is meant for locks left behind by a process that was killed. Breaking
+// the lock of an operation that is still running allows other operations to
+// run concurrently with it.
+func (r *ReleaseUnlock) Run(name string) (*driver.Lock, error) {
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	locker := r.cfg.Releases.Locker
+	if locker == nil {
+		return nil, errors.New("the storage driver does not support release locks")
+	}
+
+	l, err := locker.GetLock(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := locker.BreakLock(name); err != nil {
+		return nil, err
+	}
+	r.cfg.Log("removed lock of release %s held by %s", name, l.Holder)
+	return l, nil
+}
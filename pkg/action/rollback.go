@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/pkg/errors"
 
 	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
 )
@@ -45,6 +47,16 @@ type Rollback struct {
 	Force         bool // will (if true) force resource upgrade through uninstall/recreate if needed
 	CleanupOnFail bool
 	MaxHistory    int // MaxHistory limits the maximum number of revisions saved per release
+	// ServerSideApply updates the resources with server-side apply
+	ServerSideApply bool
+	// ForceConflicts takes over fields owned by other field managers when ServerSideApply is set
+	ForceConflicts bool
+	// WaitForLock is how long to wait for the release lock if another operation holds it
+	WaitForLock time.Duration
+	// WaitProgress receives which resources are not ready yet, and why, while
+	// waiting for them. It requires a KubeClient implementing
+	// kube.InterfaceWaitProgress.
+	WaitProgress kube.ProgressFunc
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
@@ -56,11 +68,24 @@ func NewRollback(cfg *Configuration) *Rollback {
 
 // Run executes 'helm rollback' against the given release.
 func (r *Rollback) Run(name string) error {
+	return r.RunWithContext(context.Background(), name)
+}
+
+// RunWithContext executes 'helm rollback' against the given release until ctx
+// is done. If ctx is canceled while the release is being rolled back, hooks and
+// waits are stopped and the rolled back release is marked as failed.
+func (r *Rollback) RunWithContext(ctx context.Context, name string) error {
 	if err := r.cfg.KubeClient.IsReachable(); err != nil {
 		return err
 	}
 
-	r.cfg.Releases.MaxHistory = r.MaxHistory
+	if !r.DryRun {
+		unlock, err := r.cfg.lockRelease(name, r.WaitForLock)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
 
 	r.cfg.Log("preparing rollback of %s", name)
 	currentRelease, targetRelease, err := r.prepareRollback(name)
@@ -70,19 +95,19 @@ func (r *Rollback) Run(name string) error {
 
 	if !r.DryRun {
 		r.cfg.Log("creating rolled back release for %s", name)
-		if err := r.cfg.Releases.Create(targetRelease); err != nil {
+		if err := r.cfg.createRelease(targetRelease, maxHistoryRetention(r.MaxHistory)); err != nil {
 			return err
 		}
 	}
 
 	r.cfg.Log("performing rollback of %s", name)
-	if _, err := r.performRollback(currentRelease, targetRelease); err != nil {
+	if _, err := r.performRollback(ctx, currentRelease, targetRelease); err != nil {
 		return err
 	}
 
 	if !r.DryRun {
 		r.cfg.Log("updating status for rolled back release for %s", name)
-		if err := r.cfg.Releases.Update(targetRelease); err != nil {
+		if err := r.cfg.updateRelease(targetRelease); err != nil {
 			return err
 		}
 	}
@@ -135,12 +160,13 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 		Version:  currentRelease.Version + 1,
 		Manifest: previousRelease.Manifest,
 		Hooks:    previousRelease.Hooks,
+		Labels:   previousRelease.Labels,
 	}
 
 	return currentRelease, targetRelease, nil
 }
 
-func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release) (*release.Release, error) {
+func (r *Rollback) performRollback(ctx context.Context, currentRelease, targetRelease *release.Release) (*release.Release, error) {
 	if r.DryRun {
 		r.cfg.Log("dry run for %s", targetRelease.Name)
 		return targetRelease, nil
@@ -157,18 +183,21 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 
 	// pre-rollback hooks
 	if !r.DisableHooks {
-		if err := r.cfg.execHook(targetRelease, release.HookPreRollback, r.Timeout); err != nil {
-			return targetRelease, err
+		if err := r.cfg.execHook(ctx, targetRelease, release.HookPreRollback, r.Timeout); err != nil {
+			r.execFailureHook(ctx, targetRelease, err)
+			return targetRelease, r.failCanceled(ctx, targetRelease, err)
 		}
 	} else {
 		r.cfg.Log("rollback hooks disabled for %s", targetRelease.Name)
 	}
 
-	results, err := r.cfg.KubeClient.Update(current, target, r.Force)
+	results, err := r.cfg.updateInWaves(ctx, targetRelease, current, target, r.Force, r.ServerSideApply, r.ForceConflicts, r.Timeout, r.WaitProgress)
 
 	if err != nil {
+		err = canceled(ctx, "rollback", err)
 		msg := fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err)
 		r.cfg.Log("warning: %s", msg)
+		r.execFailureHook(ctx, targetRelease, err)
 		currentRelease.Info.Status = release.StatusSuperseded
 		targetRelease.Info.Status = release.StatusFailed
 		targetRelease.Info.Description = msg
@@ -176,7 +205,8 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		r.cfg.recordRelease(targetRelease)
 		if r.CleanupOnFail {
 			r.cfg.Log("Cleanup on fail set, cleaning up %d resources", len(results.Created))
-			_, errs := r.cfg.KubeClient.Delete(results.Created)
+			res, errs := r.cfg.KubeClient.Delete(results.Created)
+			r.cfg.notifyResourcesChanged(targetRelease, res)
 			if errs != nil {
 				var errorList []string
 				for _, e := range errs {
@@ -200,27 +230,21 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 	}
 
 	if r.Wait {
-		if r.WaitForJobs {
-			if err := r.cfg.KubeClient.WaitWithJobs(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
-		} else {
-			if err := r.cfg.KubeClient.Wait(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
+		if err := r.cfg.waitForResources(ctx, targetRelease, target, r.Timeout, r.WaitForJobs, r.WaitProgress); err != nil {
+			err = canceled(ctx, "rollback", err)
+			r.execFailureHook(ctx, targetRelease, err)
+			targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
+			r.cfg.recordRelease(currentRelease)
+			r.cfg.recordRelease(targetRelease)
+			return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
 		}
 	}
 
 	// post-rollback hooks
 	if !r.DisableHooks {
-		if err := r.cfg.execHook(targetRelease, release.HookPostRollback, r.Timeout); err != nil {
-			return targetRelease, err
+		if err := r.cfg.execHook(ctx, targetRelease, release.HookPostRollback, r.Timeout); err != nil {
+			r.execFailureHook(ctx, targetRelease, err)
+			return targetRelease, r.failCanceled(ctx, targetRelease, err)
 		}
 	}
 
@@ -239,3 +263,24 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 
 	return targetRelease, nil
 }
+
+// failCanceled marks the rolled back release as failed if the rollback was
+// canceled while its hooks were running, so that it is not left pending.
+func (r *Rollback) failCanceled(ctx context.Context, targetRelease *release.Release, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	err = canceled(ctx, "rollback", err)
+	targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err))
+	r.cfg.recordRelease(targetRelease)
+	return err
+}
+
+// execFailureHook runs the post-rollback-failure hooks of the rolled back
+// release unless hooks are disabled.
+func (r *Rollback) execFailureHook(ctx context.Context, targetRelease *release.Release, err error) {
+	if r.DisableHooks {
+		return
+	}
+	r.cfg.execFailureHook(ctx, targetRelease, release.HookPostRollbackFailure, err, r.Timeout, nil)
+}
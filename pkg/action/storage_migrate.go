@@ -0,0 +1,158 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+)
+
+// StorageMigrate is the action for copying the releases of one storage
+// backend to another, e.g. from Secrets to SQL.
+//
+// It provides the implementation of 'helm storage migrate'.
+type StorageMigrate struct {
+	cfg *Configuration
+
+	// From is the storage the releases are read from. It is not modified.
+	From *storage.Storage
+	// To is the storage the releases are written to.
+	To *storage.Storage
+	// DryRun checks for conflicts and reports the revisions that would be
+	// migrated without writing them.
+	DryRun bool
+	// WaitForLock is how long to wait for the lock of each release in the
+	// source storage if another operation holds it
+	WaitForLock time.Duration
+}
+
+// NewStorageMigrate creates a new StorageMigrate object with the given configuration.
+func NewStorageMigrate(cfg *Configuration) *StorageMigrate {
+	return &StorageMigrate{
+		cfg: cfg,
+	}
+}
+
+// Run copies every revision of every release from the source to the
+// destination storage, one release at a time. Each revision is read back
+// from the destination and its checksum compared with the source revision.
+//
+// Nothing is migrated if any release already exists in the destination.
+// It returns the migrated revisions with their checksums.
+func (m *StorageMigrate) Run() ([]ReleaseArchiveEntry, error) {
+	if m.From == nil || m.To == nil {
+		return nil, errors.New("source and destination storage are required")
+	}
+
+	all, err := m.From.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkReleaseConflicts(m.To, all); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, rel := range all {
+		names[rel.Name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	// Lock the releases in the source so they do not change while they are copied
+	src := &Configuration{Releases: m.From, Log: m.cfg.Log}
+
+	var migrated []ReleaseArchiveEntry
+	for _, name := range sorted {
+		entries, err := m.migrateRelease(src, name)
+		migrated = append(migrated, entries...)
+		if err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+func (m *StorageMigrate) migrateRelease(src *Configuration, name string) ([]ReleaseArchiveEntry, error) {
+	if !m.DryRun {
+		unlock, err := src.lockRelease(name, m.WaitForLock)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	history, err := m.From.History(name)
+	if err != nil {
+		return nil, err
+	}
+	sortReleases(history)
+
+	var migrated []ReleaseArchiveEntry
+	for _, rel := range history {
+		_, digest, err := encodeArchivedRelease(rel)
+		if err != nil {
+			return migrated, err
+		}
+		entry := ReleaseArchiveEntry{
+			Name:      rel.Name,
+			Namespace: rel.Namespace,
+			Version:   rel.Version,
+			Status:    rel.Info.Status.String(),
+			Digest:    digest,
+		}
+		if m.DryRun {
+			migrated = append(migrated, entry)
+			continue
+		}
+
+		m.cfg.Log("migrating release %s revision %d", rel.Name, rel.Version)
+		if err := m.To.Create(rel); err != nil {
+			return migrated, errors.Wrapf(err, "failed to migrate release %s revision %d", rel.Name, rel.Version)
+		}
+		if err := verifyMigrated(m.To, rel, digest); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, entry)
+	}
+	return migrated, nil
+}
+
+// verifyMigrated reads a revision back from the destination and compares its
+// checksum with the checksum of the source revision.
+func verifyMigrated(to *storage.Storage, rel *release.Release, digest string) error {
+	stored, err := to.Get(rel.Name, rel.Version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read back release %s revision %d", rel.Name, rel.Version)
+	}
+	_, storedDigest, err := encodeArchivedRelease(stored)
+	if err != nil {
+		return err
+	}
+	if storedDigest != digest {
+		return errors.Errorf("checksum mismatch for release %s revision %d: expected %s, got %s", rel.Name, rel.Version, digest, storedDigest)
+	}
+	return nil
+}
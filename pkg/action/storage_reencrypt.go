@@ -0,0 +1,108 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// StorageReencrypt is the action for rewriting the records of releases, so
+// that they are encrypted with the current encryption key of the storage
+// driver.
+//
+// It provides the implementation of 'helm storage reencrypt'.
+type StorageReencrypt struct {
+	cfg *Configuration
+
+	// DryRun reports the revisions that would be rewritten without writing
+	// them.
+	DryRun bool
+	// WaitForLock is how long to wait for the lock of each release if another
+	// operation holds it
+	WaitForLock time.Duration
+}
+
+// NewStorageReencrypt creates a new StorageReencrypt object with the given configuration.
+func NewStorageReencrypt(cfg *Configuration) *StorageReencrypt {
+	return &StorageReencrypt{
+		cfg: cfg,
+	}
+}
+
+// Run rewrites every revision of every release of the storage, one release
+// at a time. Revisions that were stored without encryption, or encrypted
+// with a previous key, are encrypted with the current key. It returns the
+// rewritten revisions.
+func (r *StorageReencrypt) Run() ([]*release.Release, error) {
+	all, err := r.cfg.Releases.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, rel := range all {
+		names[rel.Name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var rewritten []*release.Release
+	for _, name := range sorted {
+		revisions, err := r.reencryptRelease(name)
+		rewritten = append(rewritten, revisions...)
+		if err != nil {
+			return rewritten, err
+		}
+	}
+	return rewritten, nil
+}
+
+func (r *StorageReencrypt) reencryptRelease(name string) ([]*release.Release, error) {
+	if !r.DryRun {
+		unlock, err := r.cfg.lockRelease(name, r.WaitForLock)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	history, err := r.cfg.Releases.History(name)
+	if err != nil {
+		return nil, err
+	}
+	sortReleases(history)
+	if r.DryRun {
+		return history, nil
+	}
+
+	var rewritten []*release.Release
+	for _, rel := range history {
+		r.cfg.Log("re-encrypting release %s revision %d", rel.Name, rel.Version)
+		if err := r.cfg.Releases.Update(rel); err != nil {
+			return rewritten, errors.Wrapf(err, "failed to re-encrypt release %s revision %d", rel.Name, rel.Version)
+		}
+		rewritten = append(rewritten, rel)
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestStorageReencrypt(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	secrets := driver.NewSecrets(fake.NewSimpleClientset().CoreV1().Secrets("default"))
+	cfg.Releases = storage.Init(secrets)
+
+	superseded := namedReleaseStub("apple", release.StatusSuperseded)
+	superseded.Namespace = "default"
+	deployed := namedReleaseStub("apple", release.StatusDeployed)
+	deployed.Namespace = "default"
+	deployed.Version = 2
+	require.NoError(t, cfg.Releases.Create(superseded))
+	require.NoError(t, cfg.Releases.Create(deployed))
+
+	enc, err := driver.NewAESGCMEncrypter(driver.EncryptionKey{ID: "current", Secret: bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	secrets.Encrypter = enc
+
+	reencrypt := NewStorageReencrypt(cfg)
+	reencrypt.DryRun = true
+	rewritten, err := reencrypt.Run()
+	require.NoError(t, err)
+	assert.Len(t, rewritten, 2)
+
+	// The records are still readable without encryption after a dry run
+	secrets.Encrypter = nil
+	_, err = cfg.Releases.Get("apple", 1)
+	require.NoError(t, err)
+
+	secrets.Encrypter = enc
+	reencrypt.DryRun = false
+	rewritten, err = reencrypt.Run()
+	require.NoError(t, err)
+	require.Len(t, rewritten, 2)
+	assert.Equal(t, []int{1, 2}, []int{rewritten[0].Version, rewritten[1].Version})
+
+	rel, err := cfg.Releases.Get("apple", 2)
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+
+	secrets.Encrypter = nil
+	_, err = cfg.Releases.Get("apple", 1)
+	assert.Error(t, err, "expected the records to be encrypted")
+}
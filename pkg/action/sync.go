@@ -0,0 +1,346 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// SyncOperation is what a sync does to a release.
+type SyncOperation string
+
+// The operations of a sync.
+const (
+	// SyncInstall installs a release that does not exist.
+	SyncInstall SyncOperation = "install"
+	// SyncUpgrade upgrades a release that differs from the release set.
+	SyncUpgrade SyncOperation = "upgrade"
+	// SyncUnchanged leaves a release that matches the release set as it is.
+	SyncUnchanged SyncOperation = "unchanged"
+	// SyncSkipped does not sync a release because a release it needs was not
+	// synced successfully.
+	SyncSkipped SyncOperation = "skipped"
+)
+
+// SyncResult is the result of the sync of a release.
+type SyncResult struct {
+	Name      string
+	Namespace string
+	// Operation is what was done, or would be done by a dry run. It is empty
+	// if the sync failed before the operation was decided.
+	Operation SyncOperation
+	// Release is the installed or upgraded release.
+	Release *release.Release
+	// Err is why the release failed or was skipped.
+	Err error
+}
+
+// Sync is the action for installing and upgrading the releases of a release
+// set, in the order of their needs.
+//
+// It provides the implementation of 'helm sync'.
+type Sync struct {
+	cfg *Configuration
+
+	// Settings are used to locate the charts and read the values files.
+	Settings *cli.EnvSettings
+	// Namespace is the namespace of the releases that do not set one.
+	Namespace string
+	// ConfigFor returns the configuration of the releases of a namespace. It
+	// is called for every release, which has its own configuration. If it is
+	// not set, every release must be in Namespace.
+	ConfigFor func(namespace string) (*Configuration, error)
+	// Selector is a label selector of the releases to sync. Releases needed
+	// by the selected releases and not selected themselves are not synced.
+	Selector string
+	// Concurrency is the maximum number of releases synced at the same time.
+	// Values of 0 or less mean no limit.
+	Concurrency int
+	// DryRun reports what would be done to every release without doing it.
+	DryRun bool
+	// CreateNamespace creates the namespace of the releases that are
+	// installed if it is not present.
+	CreateNamespace bool
+	// Timeout is the timeout of the operation on each release.
+	Timeout time.Duration
+	// Wait waits for the resources of each release to be ready before the
+	// releases that need it are synced.
+	Wait bool
+	// WaitForJobs waits for the Jobs of each release to be completed if Wait
+	// is set.
+	WaitForJobs bool
+	// Atomic rolls back, or uninstalls, a release that fails to sync.
+	Atomic bool
+	// WaitForLock is how long to wait for the lock of each release if another
+	// operation holds it
+	WaitForLock time.Duration
+}
+
+// NewSync creates a new Sync object with the given configuration.
+func NewSync(cfg *Configuration) *Sync {
+	return &Sync{
+		cfg: cfg,
+	}
+}
+
+// Run syncs the releases of the set until ctx is done. A release is synced
+// once every release it needs is synced successfully, concurrently with the
+// other releases, and skipped if any of them fails.
+//
+// A release that does not exist is installed. A release that exists is
+// upgraded, unless the chart, the values and the rendered manifest are the
+// same as the ones of its last revision.
+//
+// It returns the results in the order of the set, and an error if the set is
+// invalid or any release failed.
+func (s *Sync) Run(ctx context.Context, set *ReleaseSet) ([]SyncResult, error) {
+	if err := set.validate(s.Namespace); err != nil {
+		return nil, err
+	}
+	selected, err := set.selectReleases(s.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if s.ConfigFor == nil {
+		for _, r := range selected {
+			if r.Namespace != s.Namespace {
+				return nil, errors.Errorf("release %s is not in namespace %q", r.key(), s.Namespace)
+			}
+		}
+		// The releases share the configuration, so that its capabilities
+		// must be discovered before they are synced concurrently.
+		if _, err := s.cfg.getCapabilities(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]SyncResult, len(selected))
+	done := make(map[string]chan struct{}, len(selected))
+	index := make(map[string]int, len(selected))
+	for i, r := range selected {
+		results[i] = SyncResult{Name: r.Name, Namespace: r.Namespace}
+		done[r.key()] = make(chan struct{})
+		index[r.key()] = i
+	}
+
+	limit := s.Concurrency
+	if limit <= 0 {
+		limit = len(selected)
+	}
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, r := range selected {
+		wg.Add(1)
+		go func(r *ReleaseSpec, result *SyncResult) {
+			defer wg.Done()
+			defer close(done[r.key()])
+
+			for _, need := range r.Needs {
+				key := r.needKey(need)
+				ch, ok := done[key]
+				if !ok {
+					// Not selected
+					continue
+				}
+				<-ch
+				if needed := results[index[key]]; needed.Err != nil || needed.Operation == SyncSkipped {
+					result.Operation = SyncSkipped
+					result.Err = errors.Errorf("%s was not synced", key)
+					return
+				}
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				result.Operation = SyncSkipped
+				result.Err = ctx.Err()
+				return
+			}
+			if err := ctx.Err(); err != nil {
+				result.Operation = SyncSkipped
+				result.Err = err
+				return
+			}
+			result.Operation, result.Release, result.Err = s.syncRelease(ctx, r)
+		}(r, &results[i])
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil && result.Operation != SyncSkipped {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, errors.Errorf("%d of %d releases failed to sync", failed, len(results))
+	}
+	return results, nil
+}
+
+// syncRelease installs or upgrades a release of the set.
+func (s *Sync) syncRelease(ctx context.Context, r *ReleaseSpec) (SyncOperation, *release.Release, error) {
+	cfg := s.cfg
+	if s.ConfigFor != nil {
+		var err error
+		if cfg, err = s.ConfigFor(r.Namespace); err != nil {
+			return "", nil, err
+		}
+	}
+
+	ch, vals, err := s.loadRelease(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	history, err := cfg.Releases.History(r.Name)
+	if errors.Is(err, driver.ErrReleaseNotFound) || (err == nil && len(history) == 0) {
+		cfg.Log("installing release %s", r.key())
+		install := NewInstall(cfg)
+		install.ReleaseName = r.Name
+		install.Namespace = r.Namespace
+		install.CreateNamespace = s.CreateNamespace
+		install.DryRun = s.DryRun
+		install.Timeout = s.Timeout
+		install.Wait = s.Wait
+		install.WaitForJobs = s.WaitForJobs
+		install.Atomic = s.Atomic
+		install.WaitForLock = s.WaitForLock
+		rel, err := install.RunWithContext(ctx, ch, vals)
+		return SyncInstall, rel, err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	upgrade := NewUpgrade(cfg)
+	upgrade.Namespace = r.Namespace
+	upgrade.ResetValues = true
+	upgrade.Timeout = s.Timeout
+	upgrade.Wait = s.Wait
+	upgrade.WaitForJobs = s.WaitForJobs
+	upgrade.Atomic = s.Atomic
+	upgrade.WaitForLock = s.WaitForLock
+
+	// Render the upgrade to find out whether the release differs
+	upgrade.DryRun = true
+	rendered, err := upgrade.RunWithContext(ctx, r.Name, ch, vals)
+	if err != nil {
+		return SyncUpgrade, rendered, err
+	}
+	last, err := cfg.Releases.Last(r.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	if !releaseChanged(last, rendered) {
+		return SyncUnchanged, last, nil
+	}
+	if s.DryRun {
+		return SyncUpgrade, rendered, nil
+	}
+
+	cfg.Log("upgrading release %s", r.key())
+	upgrade.DryRun = false
+	rel, err := upgrade.RunWithContext(ctx, r.Name, ch, vals)
+	return SyncUpgrade, rel, err
+}
+
+// loadRelease loads the chart and merges the values of a release of the set.
+func (s *Sync) loadRelease(r *ReleaseSpec) (*chart.Chart, map[string]interface{}, error) {
+	settings := s.Settings
+	if settings == nil {
+		settings = cli.New()
+	}
+
+	cpo := ChartPathOptions{Version: r.Version}
+	chartPath, err := cpo.LocateChart(r.Chart, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req := ch.Metadata.Dependencies; req != nil {
+		if err := CheckDependencies(ch, req); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	vals := map[string]interface{}{}
+	for _, f := range r.Values {
+		fileVals, err := chartutil.ReadValuesFile(f)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse %s", f)
+		}
+		vals = mergeValues(vals, fileVals)
+	}
+	return ch, mergeValues(vals, r.Set), nil
+}
+
+// mergeValues returns the values in override merged into base, without
+// modifying either.
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if m, ok := v.(map[string]interface{}); ok {
+			if bm, ok := out[k].(map[string]interface{}); ok {
+				out[k] = mergeValues(bm, m)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// releaseChanged returns whether an upgrade rendered from a release set
+// differs from the last revision of the release.
+func releaseChanged(last, rendered *release.Release) bool {
+	if last.Info.Status != release.StatusDeployed {
+		return true
+	}
+	if last.Chart == nil || last.Chart.Metadata == nil ||
+		last.Chart.Metadata.Name != rendered.Chart.Metadata.Name ||
+		last.Chart.Metadata.Version != rendered.Chart.Metadata.Version {
+		return true
+	}
+	if len(last.Config) != 0 || len(rendered.Config) != 0 {
+		if !reflect.DeepEqual(last.Config, rendered.Config) {
+			return true
+		}
+	}
+	return last.Manifest != rendered.Manifest
+}
@@ -0,0 +1,305 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/internal/test/ensure"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const syncReleaseSet = `releases:
+- name: db
+  chart: ./db
+  labels:
+    tier: data
+- name: cache
+  chart: ./db
+  labels:
+    tier: data
+- name: app
+  chart: ./app
+  values:
+  - values/app.yaml
+  set:
+    image:
+      tag: v2
+  needs:
+  - db
+  - spaced/cache
+- name: web
+  chart: ./app
+  needs:
+  - app
+`
+
+// syncFixture writes a release set with its charts and values to a
+// directory and returns the path of the release set.
+func syncFixture(t *testing.T, releaseSet string) string {
+	t.Helper()
+	dir := ensure.TempDir(t)
+	for _, name := range []string{"db", "app"} {
+		ch := buildChart(withName(name), withWaveTemplates(map[string]string{name: ""}))
+		require.NoError(t, chartutil.SaveDir(ch, dir))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "values"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "values", "app.yaml"), []byte("replicas: 2\nimage:\n  repository: nginx\n"), 0644))
+	filename := filepath.Join(dir, "releases.yaml")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(releaseSet), 0644))
+	return filename
+}
+
+// syncAction returns a sync recording the order in which the releases are
+// deployed.
+func syncAction(t *testing.T) (*Sync, *[]string) {
+	t.Helper()
+	cfg := actionConfigFixture(t)
+	var deployed []string
+	cfg.Observer = ObserverFunc(func(e Event) {
+		if e.Type == EventReleaseRecorded && e.Release.Info.Status == release.StatusDeployed {
+			deployed = append(deployed, e.Release.Name)
+		}
+	})
+	s := NewSync(cfg)
+	s.Namespace = "spaced"
+	return s, &deployed
+}
+
+func syncOperations(results []SyncResult) map[string]SyncOperation {
+	ops := map[string]SyncOperation{}
+	for _, r := range results {
+		ops[r.Name] = r.Operation
+	}
+	return ops
+}
+
+// indexOf returns the position of name in names, or -1.
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestSync(t *testing.T) {
+	filename := syncFixture(t, syncReleaseSet)
+	s, deployed := syncAction(t)
+
+	set, err := LoadReleaseSet(filename)
+	require.NoError(t, err)
+	results, err := s.Run(context.Background(), set)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]SyncOperation{"db": SyncInstall, "cache": SyncInstall, "app": SyncInstall, "web": SyncInstall}, syncOperations(results))
+	assert.Equal(t, []string{"db", "cache", "app", "web"}, []string{results[0].Name, results[1].Name, results[2].Name, results[3].Name})
+	require.Len(t, *deployed, 4)
+	assert.Greater(t, indexOf(*deployed, "app"), indexOf(*deployed, "db"))
+	assert.Greater(t, indexOf(*deployed, "app"), indexOf(*deployed, "cache"))
+	assert.Greater(t, indexOf(*deployed, "web"), indexOf(*deployed, "app"))
+
+	app, err := s.cfg.Releases.Last("app")
+	require.NoError(t, err)
+	assert.Equal(t, "spaced", app.Namespace)
+	assert.Equal(t, map[string]interface{}{
+		"replicas": float64(2),
+		"image":    map[string]interface{}{"repository": "nginx", "tag": "v2"},
+	}, app.Config)
+
+	t.Run("unchanged releases are left as they are", func(t *testing.T) {
+		set, err := LoadReleaseSet(filename)
+		require.NoError(t, err)
+		results, err := s.Run(context.Background(), set)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]SyncOperation{"db": SyncUnchanged, "cache": SyncUnchanged, "app": SyncUnchanged, "web": SyncUnchanged}, syncOperations(results))
+	})
+
+	t.Run("changed releases are upgraded", func(t *testing.T) {
+		valuesFile := filepath.Join(filepath.Dir(filename), "values", "app.yaml")
+		require.NoError(t, ioutil.WriteFile(valuesFile, []byte("replicas: 3\n"), 0644))
+		set, err := LoadReleaseSet(filename)
+		require.NoError(t, err)
+		results, err := s.Run(context.Background(), set)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]SyncOperation{"db": SyncUnchanged, "cache": SyncUnchanged, "app": SyncUpgrade, "web": SyncUnchanged}, syncOperations(results))
+
+		app, err := s.cfg.Releases.Last("app")
+		require.NoError(t, err)
+		assert.Equal(t, 2, app.Version)
+		assert.Equal(t, release.StatusDeployed, app.Info.Status)
+	})
+}
+
+func TestSync_DryRun(t *testing.T) {
+	filename := syncFixture(t, syncReleaseSet)
+	s, deployed := syncAction(t)
+	s.DryRun = true
+
+	set, err := LoadReleaseSet(filename)
+	require.NoError(t, err)
+	results, err := s.Run(context.Background(), set)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]SyncOperation{"db": SyncInstall, "cache": SyncInstall, "app": SyncInstall, "web": SyncInstall}, syncOperations(results))
+	assert.Empty(t, *deployed)
+	_, err = s.cfg.Releases.Last("db")
+	assert.Error(t, err, "a dry run should not install releases")
+}
+
+func TestSync_Selector(t *testing.T) {
+	filename := syncFixture(t, syncReleaseSet)
+	s, _ := syncAction(t)
+	s.Selector = "tier=data"
+
+	set, err := LoadReleaseSet(filename)
+	require.NoError(t, err)
+	results, err := s.Run(context.Background(), set)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncOperation{"db": SyncInstall, "cache": SyncInstall}, syncOperations(results))
+
+	s.Selector = "name in (web)"
+	results, err = s.Run(context.Background(), set)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncOperation{"web": SyncInstall}, syncOperations(results))
+
+	s.Selector = "tier in (data"
+	_, err = s.Run(context.Background(), set)
+	assert.Error(t, err)
+}
+
+func TestSync_FailedNeed(t *testing.T) {
+	filename := syncFixture(t, `releases:
+- name: db
+  chart: ./missing
+- name: cache
+  chart: ./db
+- name: app
+  chart: ./app
+  needs: [db, cache]
+- name: web
+  chart: ./app
+  needs: [app]
+`)
+	s, _ := syncAction(t)
+	s.Concurrency = 1
+
+	set, err := LoadReleaseSet(filename)
+	require.NoError(t, err)
+	results, err := s.Run(context.Background(), set)
+	assert.EqualError(t, err, "1 of 4 releases failed to sync")
+
+	assert.Equal(t, map[string]SyncOperation{"db": "", "cache": SyncInstall, "app": SyncSkipped, "web": SyncSkipped}, syncOperations(results))
+	assert.Error(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.EqualError(t, results[2].Err, "spaced/db was not synced")
+	assert.EqualError(t, results[3].Err, "spaced/app was not synced")
+}
+
+func TestSync_Canceled(t *testing.T) {
+	filename := syncFixture(t, syncReleaseSet)
+	s, deployed := syncAction(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	set, err := LoadReleaseSet(filename)
+	require.NoError(t, err)
+	results, err := s.Run(ctx, set)
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.Equal(t, SyncSkipped, r.Operation, r.Name)
+	}
+	assert.Empty(t, *deployed)
+}
+
+func TestReleaseSetValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		set  string
+		err  string
+	}{
+		{
+			name: "cycle",
+			set:  "releases:\n- {name: a, chart: c, needs: [b]}\n- {name: b, chart: c, needs: [c]}\n- {name: c, chart: c, needs: [a]}\n",
+			err:  "release set has a dependency cycle: spaced/a -> spaced/b -> spaced/c -> spaced/a",
+		},
+		{
+			name: "self",
+			set:  "releases:\n- {name: a, chart: c, needs: [a]}\n",
+			err:  "release set has a dependency cycle: spaced/a -> spaced/a",
+		},
+		{
+			name: "unknown need",
+			set:  "releases:\n- {name: a, chart: c, needs: [other/a]}\n",
+			err:  "release spaced/a needs other/a, which is not in the release set",
+		},
+		{
+			name: "duplicate",
+			set:  "releases:\n- {name: a, chart: c}\n- {name: a, namespace: spaced, chart: c}\n",
+			err:  "release spaced/a is listed more than once",
+		},
+		{
+			name: "no chart",
+			set:  "releases:\n- {name: a}\n",
+			err:  "release a has no chart",
+		},
+		{
+			name: "same name in another namespace",
+			set:  "releases:\n- {name: a, chart: c}\n- {name: a, namespace: other, chart: c, needs: [spaced/a]}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(ensure.TempDir(t), "releases.yaml")
+			require.NoError(t, ioutil.WriteFile(filename, []byte(tt.set), 0644))
+			set, err := LoadReleaseSet(filename)
+			require.NoError(t, err)
+			err = set.validate("spaced")
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+
+	t.Run("releases in other namespaces need a configuration", func(t *testing.T) {
+		filename := filepath.Join(ensure.TempDir(t), "releases.yaml")
+		require.NoError(t, ioutil.WriteFile(filename, []byte("releases:\n- {name: a, namespace: other, chart: c}\n"), 0644))
+		set, err := LoadReleaseSet(filename)
+		require.NoError(t, err)
+		s, _ := syncAction(t)
+		_, err = s.Run(context.Background(), set)
+		assert.EqualError(t, err, `release other/a is not in namespace "spaced"`)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		filename := filepath.Join(ensure.TempDir(t), "releases.yaml")
+		require.NoError(t, ioutil.WriteFile(filename, []byte("releases:\n- {name: a, chart: c, need: [b]}\n"), 0644))
+		_, err := LoadReleaseSet(filename)
+		assert.Error(t, err)
+	})
+}
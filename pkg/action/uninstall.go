@@ -17,12 +17,16 @@ limitations under the License.
 package action
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -39,6 +43,13 @@ type Uninstall struct {
 	KeepHistory  bool
 	Timeout      time.Duration
 	Description  string
+	// WaitForLock is how long to wait for the release lock if another operation holds it
+	WaitForLock time.Duration
+	// Wait makes Run wait until the deleted resources are gone, for as long as Timeout
+	Wait bool
+	// DeletionPropagation is how the dependents of the deleted resources are
+	// deleted: "background", "foreground" or "orphan". It defaults to "background".
+	DeletionPropagation string
 }
 
 // NewUninstall creates a new Uninstall object with the given configuration.
@@ -50,10 +61,26 @@ func NewUninstall(cfg *Configuration) *Uninstall {
 
 // Run uninstalls the given release.
 func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error) {
+	return u.RunWithContext(context.Background(), name)
+}
+
+// RunWithContext uninstalls the given release until ctx is done. If ctx is
+// canceled while the resources of the release are deleted, the release is
+// kept as uninstalling, so that uninstalling it again deletes the remaining
+// resources.
+func (u *Uninstall) RunWithContext(ctx context.Context, name string) (*release.UninstallReleaseResponse, error) {
 	if err := u.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
 
+	policy, err := parseDeletionPropagation(u.DeletionPropagation)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := u.cfg.KubeClient.(kube.InterfaceDeletion); !ok && policy != metav1.DeletePropagationBackground {
+		return nil, errors.Errorf("the Kubernetes client does not support the %s deletion propagation policy", policy)
+	}
+
 	if u.DryRun {
 		// In the dry run case, just see if the release exists
 		r, err := u.cfg.releaseContent(name, 0)
@@ -67,6 +94,12 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		return nil, errors.Errorf("uninstall: Release name is invalid: %s", name)
 	}
 
+	unlock, err := u.cfg.lockRelease(name, u.WaitForLock)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	rels, err := u.cfg.Releases.History(name)
 	if err != nil {
 		return nil, errors.Wrapf(err, "uninstall: Release not loaded: %s", name)
@@ -97,7 +130,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	res := &release.UninstallReleaseResponse{Release: rel}
 
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(rel, release.HookPreDelete, u.Timeout); err != nil {
+		if err := u.cfg.execHook(ctx, rel, release.HookPreDelete, u.Timeout); err != nil {
 			return res, err
 		}
 	} else {
@@ -106,11 +139,17 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 
 	// From here on out, the release is currently considered to be in StatusUninstalling
 	// state.
-	if err := u.cfg.Releases.Update(rel); err != nil {
+	if err := u.cfg.updateRelease(rel); err != nil {
 		u.cfg.Log("uninstall: Failed to store updated release: %s", err)
 	}
 
-	kept, errs := u.deleteRelease(rel)
+	kept, errs := u.deleteRelease(ctx, rel, policy)
+	if ctx.Err() != nil {
+		err := canceled(ctx, "uninstall", ctx.Err())
+		rel.Info.Description = fmt.Sprintf("Uninstallation failed: %s", err)
+		u.cfg.recordRelease(rel)
+		return res, err
+	}
 
 	if kept != "" {
 		kept = "These resources were kept due to the resource policy:\n" + kept
@@ -118,7 +157,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	res.Info = kept
 
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(rel, release.HookPostDelete, u.Timeout); err != nil {
+		if err := u.cfg.execHook(ctx, rel, release.HookPostDelete, u.Timeout); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -145,7 +184,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		return res, nil
 	}
 
-	if err := u.cfg.Releases.Update(rel); err != nil {
+	if err := u.cfg.updateRelease(rel); err != nil {
 		u.cfg.Log("uninstall: Failed to store updated release: %s", err)
 	}
 
@@ -172,8 +211,22 @@ func joinErrors(errs []error) string {
 	return strings.Join(es, "; ")
 }
 
+// parseDeletionPropagation returns the deletion propagation policy named by s.
+func parseDeletionPropagation(s string) (metav1.DeletionPropagation, error) {
+	switch strings.ToLower(s) {
+	case "", "background":
+		return metav1.DeletePropagationBackground, nil
+	case "foreground":
+		return metav1.DeletePropagationForeground, nil
+	case "orphan":
+		return metav1.DeletePropagationOrphan, nil
+	default:
+		return "", errors.Errorf("invalid deletion propagation %q: must be \"background\", \"foreground\" or \"orphan\"", s)
+	}
+}
+
 // deleteRelease deletes the release and returns manifests that were kept in the deletion process
-func (u *Uninstall) deleteRelease(rel *release.Release) (string, []error) {
+func (u *Uninstall) deleteRelease(ctx context.Context, rel *release.Release, policy metav1.DeletionPropagation) (string, []error) {
 	var errs []error
 	caps, err := u.cfg.getCapabilities()
 	if err != nil {
@@ -206,7 +259,7 @@ func (u *Uninstall) deleteRelease(rel *release.Release) (string, []error) {
 		return "", []error{errors.Wrap(err, "unable to build kubernetes objects for delete")}
 	}
 	if len(resources) > 0 {
-		_, errs = u.cfg.KubeClient.Delete(resources)
+		errs = u.cfg.deleteInWaves(ctx, rel, resources, policy, u.Wait, u.Timeout)
 	}
 	return kept, errs
 }
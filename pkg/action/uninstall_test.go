@@ -17,9 +17,16 @@ limitations under the License.
 package action
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 func uninstallAction(t *testing.T) *Uninstall {
@@ -60,3 +67,81 @@ func TestUninstallRelease_deleteRelease(t *testing.T) {
 `
 	is.Contains(res.Info, expected)
 }
+
+// deletionRecordingKubeClient records the propagation policy of the deletes
+// and the waits for deleted resources to be gone.
+type deletionRecordingKubeClient struct {
+	waveRecordingKubeClient
+}
+
+func (c *deletionRecordingKubeClient) DeleteWithPropagationPolicy(resources kube.ResourceList, policy metav1.DeletionPropagation) (*kube.Result, []error) {
+	c.record("delete-"+strings.ToLower(string(policy)), resources)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *deletionRecordingKubeClient) WaitForDelete(resources kube.ResourceList, _ time.Duration) error {
+	c.record("wait-delete", resources)
+	return nil
+}
+
+func TestUninstallRelease_WaitAndDeletionPropagation(t *testing.T) {
+	newRelease := func(t *testing.T, unAction *Uninstall) string {
+		rel := releaseStub()
+		rel.Name = "cascade"
+		rel.Manifest = strings.Join([]string{
+			configMapManifest("app", ""),
+			configMapManifest("web", "1"),
+		}, "---\n")
+		require.NoError(t, unAction.cfg.Releases.Create(rel))
+		return rel.Name
+	}
+
+	t.Run("wait for each wave", func(t *testing.T) {
+		unAction := uninstallAction(t)
+		client := &deletionRecordingKubeClient{waveRecordingKubeClient{t: t}}
+		unAction.cfg.KubeClient = client
+		unAction.DisableHooks = true
+		unAction.Wait = true
+		unAction.DeletionPropagation = "foreground"
+
+		_, err := unAction.Run(newRelease(t, unAction))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"delete-foreground web", "wait-delete web", "delete-foreground app", "wait-delete app"}, client.ops)
+	})
+
+	t.Run("background without wait by default", func(t *testing.T) {
+		unAction := uninstallAction(t)
+		client := &deletionRecordingKubeClient{waveRecordingKubeClient{t: t}}
+		unAction.cfg.KubeClient = client
+		unAction.DisableHooks = true
+
+		_, err := unAction.Run(newRelease(t, unAction))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"delete-background web", "delete-background app"}, client.ops)
+	})
+
+	t.Run("invalid propagation", func(t *testing.T) {
+		unAction := uninstallAction(t)
+		unAction.DeletionPropagation = "sideways"
+
+		_, err := unAction.Run(newRelease(t, unAction))
+		assert.EqualError(t, err, `invalid deletion propagation "sideways": must be "background", "foreground" or "orphan"`)
+	})
+
+	t.Run("propagation not supported by the client", func(t *testing.T) {
+		unAction := uninstallAction(t)
+		client := &waveRecordingKubeClient{t: t}
+		unAction.cfg.KubeClient = client
+		unAction.DisableHooks = true
+		unAction.DeletionPropagation = "orphan"
+
+		_, err := unAction.Run(newRelease(t, unAction))
+		require.Error(t, err)
+		assert.EqualError(t, err, "the Kubernetes client does not support the Orphan deletion propagation policy")
+		assert.Empty(t, client.ops)
+
+		rel, err := unAction.cfg.Releases.Last("cascade")
+		require.NoError(t, err)
+		assert.Equal(t, release.StatusDeployed, rel.Info.Status)
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
@@ -71,6 +73,10 @@ type Upgrade struct {
 	// DryRun controls whether the operation is prepared, but not executed.
 	// If `true`, the upgrade is prepared but not performed.
 	DryRun bool
+	// DryRunOption is how the dry run is done if DryRun is set: DryRunClient,
+	// the default, or DryRunServer. A server-side dry run also lets the
+	// templates look up resources in the cluster.
+	DryRunOption string
 	// Force will, if set to `true`, ignore certain warnings and perform the upgrade anyway.
 	//
 	// This should be used with caution.
@@ -100,6 +106,31 @@ type Upgrade struct {
 	DisableOpenAPIValidation bool
 	// Get missing dependencies
 	DependencyUpdate bool
+	// Labels are merged into the labels of the last release. A label with the
+	// value "null" is removed.
+	Labels map[string]string
+	// ServerSideApply updates the resources with server-side apply instead of
+	// a client-side three-way merge patch.
+	ServerSideApply bool
+	// ForceConflicts takes over fields owned by other field managers when
+	// ServerSideApply is set.
+	ForceConflicts bool
+	// WaitForLock is how long to wait for the release lock if another
+	// operation holds it. By default the upgrade fails immediately.
+	WaitForLock time.Duration
+	// TakeOwnership adopts resources that already exist in the cluster into
+	// the release, even if they are not annotated as owned by it.
+	TakeOwnership bool
+	// Retention, if set, removes the revisions of the release history it does
+	// not keep when the upgraded revision is recorded, instead of MaxHistory.
+	Retention *storage.RetentionPolicy
+	// WaitProgress receives which resources are not ready yet, and why, while
+	// waiting for them. It requires a KubeClient implementing
+	// kube.InterfaceWaitProgress.
+	WaitProgress kube.ProgressFunc
+	// PlanOut, if set, receives the plan of the upgrade instead of the upgrade
+	// being performed. The plan is executed later by ApplyPlan.
+	PlanOut io.Writer
 }
 
 // NewUpgrade creates a new Upgrade object with the given configuration.
@@ -111,6 +142,14 @@ func NewUpgrade(cfg *Configuration) *Upgrade {
 
 // Run executes the upgrade on the given release.
 func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	return u.RunWithContext(context.Background(), name, chart, vals)
+}
+
+// RunWithContext executes the upgrade on the given release until ctx is done.
+// If ctx is canceled while the release is being upgraded, hooks and waits are
+// stopped and the release is marked as failed, which rolls it back if Atomic
+// is set.
+func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
 	if err := u.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
@@ -122,23 +161,48 @@ func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface
 	if err := chartutil.ValidateReleaseName(name); err != nil {
 		return nil, errors.Errorf("release name is invalid: %s", name)
 	}
+
+	if err := checkDryRunOption(u.DryRunOption); err != nil {
+		return nil, err
+	}
+
+	if u.PlanOut != nil && u.DryRun {
+		return nil, errors.New("a plan cannot be made during a dry run")
+	}
+
+	if !u.DryRun && u.PlanOut == nil {
+		unlock, err := u.cfg.lockRelease(name, u.WaitForLock)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	u.cfg.Log("preparing upgrade for %s", name)
 	currentRelease, upgradedRelease, err := u.prepareUpgrade(name, chart, vals)
 	if err != nil {
 		return nil, err
 	}
 
-	u.cfg.Releases.MaxHistory = u.MaxHistory
+	if u.PlanOut != nil {
+		u.cfg.Log("writing plan for %s", name)
+		plan, err := newPlan(upgradedRelease, upgradedRelease.Version-1, u.cfg.Now().Time)
+		if err != nil {
+			return nil, err
+		}
+		upgradedRelease.Info.Description = "Plan written"
+		return upgradedRelease, plan.Write(u.PlanOut)
+	}
 
 	u.cfg.Log("performing update for %s", name)
-	res, err := u.performUpgrade(currentRelease, upgradedRelease)
+	res, err := u.performUpgrade(ctx, currentRelease, upgradedRelease)
 	if err != nil {
 		return res, err
 	}
 
 	if !u.DryRun {
 		u.cfg.Log("updating status for upgraded release for %s", name)
-		if err := u.cfg.Releases.Update(upgradedRelease); err != nil {
+		if err := u.cfg.updateRelease(upgradedRelease); err != nil {
 			return res, err
 		}
 	}
@@ -146,42 +210,74 @@ func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface
 	return res, nil
 }
 
+// ApplyPlan executes a plan made by an upgrade with PlanOut set, until ctx
+// is done. The manifest, hooks and values of the plan are used as they are,
+// so it is refused if the release has a revision newer than the base
+// revision of the plan, or if its chart does not match the chart digest.
+func (u *Upgrade) ApplyPlan(ctx context.Context, plan *Plan) (*release.Release, error) {
+	if err := u.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	u.Wait = u.Wait || u.Atomic
+
+	if u.Namespace != "" && u.Namespace != plan.Namespace {
+		return nil, errors.Errorf("the plan is for namespace %q, not %q", plan.Namespace, u.Namespace)
+	}
+	if err := plan.verifyChart(); err != nil {
+		return nil, err
+	}
+
+	unlock, err := u.cfg.lockRelease(plan.Name, u.WaitForLock)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	lastRelease, currentRelease, err := u.lastAndCurrentRelease(plan.Name)
+	if err != nil {
+		return nil, err
+	}
+	if lastRelease.Version != plan.BaseRevision {
+		return nil, errors.Errorf("release %q is at revision %d but the plan was made for revision %d: make a new plan", plan.Name, lastRelease.Version, plan.BaseRevision)
+	}
+
+	planned := *plan.Release
+	upgradedRelease := &planned
+	upgradedRelease.Info = &release.Info{
+		FirstDeployed: currentRelease.Info.FirstDeployed,
+		LastDeployed:  Timestamper(),
+		Status:        release.StatusPendingUpgrade,
+		Description:   "Preparing upgrade", // This should be overwritten later.
+		Notes:         plan.Release.Info.Notes,
+	}
+
+	u.cfg.Log("applying plan for %s", plan.Name)
+	res, err := u.performUpgrade(ctx, currentRelease, upgradedRelease)
+	if err != nil {
+		return res, err
+	}
+
+	u.cfg.Log("updating status for upgraded release for %s", plan.Name)
+	if err := u.cfg.updateRelease(upgradedRelease); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
 // prepareUpgrade builds an upgraded release for an upgrade operation.
 func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[string]interface{}) (*release.Release, *release.Release, error) {
 	if chart == nil {
 		return nil, nil, errMissingChart
 	}
 
-	// finds the last non-deleted release with the given name
-	lastRelease, err := u.cfg.Releases.Last(name)
-	if err != nil {
-		// to keep existing behavior of returning the "%q has no deployed releases" error when an existing release does not exist
-		if errors.Is(err, driver.ErrReleaseNotFound) {
-			return nil, nil, driver.NewErrNoDeployedReleases(name)
-		}
-		return nil, nil, err
-	}
-
-	// Concurrent `helm upgrade`s will either fail here with `errPending` or when creating the release with "already exists". This should act as a pessimistic lock.
-	if lastRelease.Info.Status.IsPending() {
-		return nil, nil, errPending
+	if driver.ContainsSystemLabels(u.Labels) {
+		return nil, nil, fmt.Errorf("user supplied labels contains system reserved label name. System labels: %+v", driver.GetSystemLabels())
 	}
 
-	var currentRelease *release.Release
-	if lastRelease.Info.Status == release.StatusDeployed {
-		// no need to retrieve the last deployed release from storage as the last release is deployed
-		currentRelease = lastRelease
-	} else {
-		// finds the deployed release with the given name
-		currentRelease, err = u.cfg.Releases.Deployed(name)
-		if err != nil {
-			if errors.Is(err, driver.ErrNoDeployedReleases) &&
-				(lastRelease.Info.Status == release.StatusFailed || lastRelease.Info.Status == release.StatusSuperseded) {
-				currentRelease = lastRelease
-			} else {
-				return nil, nil, err
-			}
-		}
+	lastRelease, currentRelease, err := u.lastAndCurrentRelease(name)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// determine if values will be reused
@@ -214,7 +310,7 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		return nil, nil, err
 	}
 
-	hooks, manifestDoc, notesTxt, err := u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, u.DryRun)
+	hooks, manifestDoc, notesTxt, err := u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, u.DryRun && u.DryRunOption != DryRunServer)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -234,16 +330,56 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		Version:  revision,
 		Manifest: manifestDoc.String(),
 		Hooks:    hooks,
+		Labels:   mergeCustomLabels(lastRelease.Labels, u.Labels),
 	}
 
 	if len(notesTxt) > 0 {
 		upgradedRelease.Info.Notes = notesTxt
 	}
+	u.cfg.notify(Event{Type: EventRenderFinished, Release: upgradedRelease})
 	err = validateManifest(u.cfg.KubeClient, manifestDoc.Bytes(), !u.DisableOpenAPIValidation)
 	return currentRelease, upgradedRelease, err
 }
 
-func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Release) (*release.Release, error) {
+// lastAndCurrentRelease returns the last revision of a release and the one
+// to upgrade from: the deployed revision, or the last one if none is deployed.
+func (u *Upgrade) lastAndCurrentRelease(name string) (*release.Release, *release.Release, error) {
+	// finds the last non-deleted release with the given name
+	lastRelease, err := u.cfg.Releases.Last(name)
+	if err != nil {
+		// to keep existing behavior of returning the "%q has no deployed releases" error when an existing release does not exist
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return nil, nil, driver.NewErrNoDeployedReleases(name)
+		}
+		return nil, nil, err
+	}
+
+	// Concurrent `helm upgrade`s will either fail here with `errPending` or when creating the release with "already exists". This should act as a pessimistic lock.
+	if lastRelease.Info.Status.IsPending() {
+		return nil, nil, errPending
+	}
+
+	var currentRelease *release.Release
+	if lastRelease.Info.Status == release.StatusDeployed {
+		// no need to retrieve the last deployed release from storage as the last release is deployed
+		currentRelease = lastRelease
+	} else {
+		// finds the deployed release with the given name
+		currentRelease, err = u.cfg.Releases.Deployed(name)
+		if err != nil {
+			if errors.Is(err, driver.ErrNoDeployedReleases) &&
+				(lastRelease.Info.Status == release.StatusFailed || lastRelease.Info.Status == release.StatusSuperseded) {
+				currentRelease = lastRelease
+			} else {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return lastRelease, currentRelease, nil
+}
+
+func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedRelease *release.Release) (*release.Release, error) {
 	current, err := u.cfg.KubeClient.Build(bytes.NewBufferString(originalRelease.Manifest), false)
 	if err != nil {
 		// Checking for removed Kubernetes API error so can provide a more informative error message to the user
@@ -279,7 +415,12 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 		}
 	}
 
-	toBeUpdated, err := existingResourceConflict(toBeCreated, upgradedRelease.Name, upgradedRelease.Namespace)
+	var toBeUpdated kube.ResourceList
+	if u.TakeOwnership {
+		toBeUpdated, err = requireAdoption(toBeCreated)
+	} else {
+		toBeUpdated, err = existingResourceConflict(toBeCreated, upgradedRelease.Name, upgradedRelease.Namespace)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "rendered manifests contain a resource that already exists. Unable to continue with update")
 	}
@@ -294,6 +435,11 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 
 	if u.DryRun {
 		u.cfg.Log("dry run for %s", upgradedRelease.Name)
+		if u.DryRunOption == DryRunServer {
+			if err := u.cfg.serverDryRun(upgradedRelease, current, target); err != nil {
+				return upgradedRelease, err
+			}
+		}
 		if len(u.Description) > 0 {
 			upgradedRelease.Info.Description = u.Description
 		} else {
@@ -303,23 +449,23 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 	}
 
 	u.cfg.Log("creating upgraded release for %s", upgradedRelease.Name)
-	if err := u.cfg.Releases.Create(upgradedRelease); err != nil {
+	if err := u.cfg.createRelease(upgradedRelease, u.retention()); err != nil {
 		return nil, err
 	}
 
 	// pre-upgrade hooks
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPreUpgrade, u.Timeout); err != nil {
-			return u.failRelease(upgradedRelease, kube.ResourceList{}, fmt.Errorf("pre-upgrade hooks failed: %s", err))
+		if err := u.cfg.execHook(ctx, upgradedRelease, release.HookPreUpgrade, u.Timeout); err != nil {
+			return u.failRelease(ctx, upgradedRelease, kube.ResourceList{}, fmt.Errorf("pre-upgrade hooks failed: %s", err))
 		}
 	} else {
 		u.cfg.Log("upgrade hooks disabled for %s", upgradedRelease.Name)
 	}
 
-	results, err := u.cfg.KubeClient.Update(current, target, u.Force)
+	results, err := u.cfg.updateInWaves(ctx, upgradedRelease, current, target, u.Force, u.ServerSideApply, u.ForceConflicts, u.Timeout, u.WaitProgress)
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
-		return u.failRelease(upgradedRelease, results.Created, err)
+		return u.failRelease(ctx, upgradedRelease, results.Created, err)
 	}
 
 	if u.Recreate {
@@ -333,23 +479,16 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 	}
 
 	if u.Wait {
-		if u.WaitForJobs {
-			if err := u.cfg.KubeClient.WaitWithJobs(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				return u.failRelease(upgradedRelease, results.Created, err)
-			}
-		} else {
-			if err := u.cfg.KubeClient.Wait(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				return u.failRelease(upgradedRelease, results.Created, err)
-			}
+		if err := u.cfg.waitForResources(ctx, upgradedRelease, target, u.Timeout, u.WaitForJobs, u.WaitProgress); err != nil {
+			u.cfg.recordRelease(originalRelease)
+			return u.failRelease(ctx, upgradedRelease, results.Created, err)
 		}
 	}
 
 	// post-upgrade hooks
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPostUpgrade, u.Timeout); err != nil {
-			return u.failRelease(upgradedRelease, results.Created, fmt.Errorf("post-upgrade hooks failed: %s", err))
+		if err := u.cfg.execHook(ctx, upgradedRelease, release.HookPostUpgrade, u.Timeout); err != nil {
+			return u.failRelease(ctx, upgradedRelease, results.Created, fmt.Errorf("post-upgrade hooks failed: %s", err))
 		}
 	}
 
@@ -366,16 +505,37 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 	return upgradedRelease, nil
 }
 
-func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, err error) (*release.Release, error) {
+// mergeCustomLabels merges the desired labels into the current ones. Labels
+// with the value "null" are removed.
+func mergeCustomLabels(current, desired map[string]string) map[string]string {
+	if len(current) == 0 && len(desired) == 0 {
+		return nil
+	}
+	labels := mergeStrStrMaps(current, desired)
+	for k, v := range labels {
+		if v == "null" {
+			delete(labels, k)
+		}
+	}
+	return labels
+}
+
+func (u *Upgrade) failRelease(ctx context.Context, rel *release.Release, created kube.ResourceList, err error) (*release.Release, error) {
+	err = canceled(ctx, "upgrade", err)
 	msg := fmt.Sprintf("Upgrade %q failed: %s", rel.Name, err)
 	u.cfg.Log("warning: %s", msg)
 
+	if !u.DisableHooks {
+		u.cfg.execFailureHook(ctx, rel, release.HookPostUpgradeFailure, err, u.Timeout, u.PostRenderer)
+	}
+
 	rel.Info.Status = release.StatusFailed
 	rel.Info.Description = msg
 	u.cfg.recordRelease(rel)
 	if u.CleanupOnFail && len(created) > 0 {
 		u.cfg.Log("Cleanup on fail set, cleaning up %d resources", len(created))
-		_, errs := u.cfg.KubeClient.Delete(created)
+		res, errs := u.cfg.KubeClient.Delete(created)
+		u.cfg.notifyResourcesChanged(rel, res)
 		if errs != nil {
 			var errorList []string
 			for _, e := range errs {
@@ -415,7 +575,10 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		rollin.DisableHooks = u.DisableHooks
 		rollin.Recreate = u.Recreate
 		rollin.Force = u.Force
+		rollin.ServerSideApply = u.ServerSideApply
+		rollin.ForceConflicts = u.ForceConflicts
 		rollin.Timeout = u.Timeout
+		rollin.WaitProgress = u.WaitProgress
 		if rollErr := rollin.Run(rel.Name); rollErr != nil {
 			return rel, errors.Wrapf(rollErr, "an error occurred while rolling back the release. original upgrade error: %s", err)
 		}
@@ -425,6 +588,25 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 	return rel, err
 }
 
+// retention returns the policy applied to the release history when the
+// upgraded revision is recorded. It is passed along rather than set on the
+// storage, which may be shared by concurrent operations.
+func (u *Upgrade) retention() *storage.RetentionPolicy {
+	if u.Retention != nil {
+		return u.Retention
+	}
+	return maxHistoryRetention(u.MaxHistory)
+}
+
+// maxHistoryRetention returns the policy keeping at most max revisions, or
+// nil to apply the limits of the storage if max is 0 or less.
+func maxHistoryRetention(max int) *storage.RetentionPolicy {
+	if max <= 0 {
+		return nil
+	}
+	return &storage.RetentionPolicy{MaxHistory: max}
+}
+
 // reuseValues copies values from the current release to a new release if the
 // new release does not have any values.
 //
@@ -27,6 +27,7 @@ import (
 
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
 	"helm.sh/helm/v3/pkg/time"
 )
 
@@ -60,6 +61,41 @@ func TestUpgradeRelease_Wait(t *testing.T) {
 	is.Equal(res.Info.Status, release.StatusFailed)
 }
 
+func TestUpgradeRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "come-fail-away"
+	rel.Info.Status = release.StatusDeployed
+	upAction.cfg.Releases.Create(rel)
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WaitError = fmt.Errorf("I timed out")
+	upAction.cfg.KubeClient = failer
+	upAction.Wait = true
+
+	res, err := upAction.Run(rel.Name, buildChart(withFailureHooks()), map[string]interface{}{})
+	req.Error(err)
+	is.Equal(release.StatusFailed, res.Info.Status)
+
+	var ran []string
+	for _, h := range res.Hooks {
+		if !h.LastRun.StartedAt.IsZero() {
+			ran = append(ran, h.Name)
+		}
+		if h.Name == "failure-cm" {
+			is.Contains(h.Manifest, "I timed out")
+		}
+	}
+	is.Equal([]string{"failure-cm"}, ran, "only the post-upgrade-failure hooks should run")
+
+	stored, err := upAction.cfg.Releases.Get(res.Name, res.Version)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, stored.Info.Status)
+}
+
 func TestUpgradeRelease_WaitForJobs(t *testing.T) {
 	is := assert.New(t)
 	req := require.New(t)
@@ -296,3 +332,121 @@ func TestUpgradeRelease_Pending(t *testing.T) {
 	_, err := upAction.Run(rel.Name, buildChart(), vals)
 	req.Contains(err.Error(), "progress", err)
 }
+
+func TestUpgradeRelease_Retention(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	for i, status := range []release.Status{release.StatusSuperseded, release.StatusFailed, release.StatusFailed, release.StatusDeployed} {
+		rel := releaseStub()
+		rel.Name = "retained"
+		rel.Version = i + 1
+		rel.Info.Status = status
+		req.NoError(upAction.cfg.Releases.Create(rel))
+	}
+
+	upAction.MaxHistory = 10
+	upAction.Retention = &storage.RetentionPolicy{MaxFailed: 1}
+	res, err := upAction.Run("retained", buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(5, res.Version)
+
+	// The older failed revision was removed when the upgrade was recorded
+	history, err := upAction.cfg.Releases.History("retained")
+	req.NoError(err)
+	var versions []int
+	for _, rel := range history {
+		versions = append(versions, rel.Version)
+	}
+	is.ElementsMatch([]int{1, 3, 4, 5}, versions)
+}
+
+func TestUpgradeRelease_Concurrent(t *testing.T) {
+	req := require.New(t)
+
+	// Upgrades of different releases share the configuration, as in helm sync
+	config := actionConfigFixture(t)
+	names := []string{"first", "second"}
+	for _, name := range names {
+		rel := releaseStub()
+		rel.Name = name
+		rel.Info.Status = release.StatusDeployed
+		req.NoError(config.Releases.Create(rel))
+	}
+
+	errs := make(chan error, len(names))
+	for i, name := range names {
+		go func(name string, maxHistory int) {
+			upAction := NewUpgrade(config)
+			upAction.Namespace = "spaced"
+			upAction.MaxHistory = maxHistory
+			_, err := upAction.Run(name, buildChart(), map[string]interface{}{})
+			errs <- err
+		}(name, i+1)
+	}
+	for range names {
+		req.NoError(<-errs)
+	}
+	req.Zero(config.Releases.MaxHistory, "the upgrades should not change the shared storage")
+
+	for _, name := range names {
+		last, err := config.Releases.Last(name)
+		req.NoError(err)
+		req.Equal(2, last.Version)
+		req.Equal(release.StatusDeployed, last.Info.Status)
+	}
+}
+
+func TestUpgradeRelease_ServerSideApply(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "server-side"
+	rel.Info.Status = release.StatusDeployed
+	upAction.cfg.Releases.Create(rel)
+
+	upAction.ServerSideApply = true
+	res, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+
+	// Replacing resources cannot be combined with server-side apply
+	upAction.Force = true
+	res, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "server-side apply")
+	is.Equal(release.StatusFailed, res.Info.Status)
+}
+
+func TestUpgradeRelease_Labels(t *testing.T) {
+	is := assert.New(t)
+	upAction := upgradeAction(t)
+
+	rel := releaseStub()
+	rel.Name = "labels"
+	rel.Labels = map[string]string{
+		"key1": "val1",
+		"key2": "val2.1",
+	}
+	rel.Info.Status = release.StatusDeployed
+	is.NoError(upAction.cfg.Releases.Create(rel))
+
+	upAction.Labels = map[string]string{
+		"key1": "null",
+		"key2": "val2.2",
+		"key3": "val3",
+	}
+	res, err := upAction.Run(rel.Name, buildChart(), nil)
+	is.NoError(err)
+
+	// key1 is removed, key2 is updated and key3 is added
+	is.Equal(map[string]string{"key2": "val2.2", "key3": "val3"}, res.Labels)
+
+	upAction.Labels = map[string]string{"status": "deployed"}
+	_, err = upAction.Run(rel.Name, buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "system reserved label name")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// waitForResources waits for the resources to be ready, and for their Jobs to
+// complete if waitForJobs is set. If the KubeClient implements
+// kube.InterfaceContext, waiting stops when ctx is done. The progress is
+// reported to progress if it is set and the KubeClient supports it.
+func (cfg *Configuration) waitForResources(ctx context.Context, rel *release.Release, resources kube.ResourceList, timeout time.Duration, waitForJobs bool, progress kube.ProgressFunc) error {
+	cfg.notify(Event{Type: EventWaitStarted, Release: rel, Resources: resources})
+	var err error
+	if kubeClient, ok := cfg.KubeClient.(kube.InterfaceContext); ok {
+		err = kubeClient.WaitWithContext(ctx, resources, timeout, waitForJobs, progress)
+	} else if kubeClient, ok := cfg.KubeClient.(kube.InterfaceWaitProgress); ok && progress != nil {
+		err = kubeClient.WaitWithProgress(resources, timeout, waitForJobs, progress)
+	} else if waitForJobs {
+		err = cfg.KubeClient.WaitWithJobs(resources, timeout)
+	} else {
+		err = cfg.KubeClient.Wait(resources, timeout)
+	}
+	cfg.notify(Event{Type: EventWaitFinished, Release: rel, Resources: resources, Err: err})
+	return err
+}
+
+// canceled wraps err to tell that the operation was canceled if ctx is done,
+// so that the description of the failed release says why it failed.
+func canceled(ctx context.Context, operation string, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	return errors.Wrapf(err, "%s canceled", operation)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+)
+
+// progressKubeClient reports a single pending resource while waiting.
+type progressKubeClient struct {
+	kubefake.PrintingKubeClient
+	waits       []string
+	waitForJobs bool
+}
+
+func (c *progressKubeClient) Wait(_ kube.ResourceList, _ time.Duration) error {
+	c.waits = append(c.waits, "wait")
+	return nil
+}
+
+func (c *progressKubeClient) WaitWithProgress(_ kube.ResourceList, timeout time.Duration, waitForJobs bool, progress kube.ProgressFunc) error {
+	c.waits = append(c.waits, "wait-with-progress")
+	c.waitForJobs = waitForJobs
+	progress(kube.WaitProgress{
+		Resources: []kube.ResourceStatus{{Object: "Deployment/api", Namespace: "spaced", Reason: "2/3 replicas ready"}},
+		Timeout:   timeout,
+	})
+	return nil
+}
+
+func TestInstallRelease_WaitProgress(t *testing.T) {
+	instAction := installAction(t)
+	client := &progressKubeClient{}
+	instAction.cfg.KubeClient = client
+	instAction.Wait = true
+	instAction.WaitForJobs = true
+	instAction.Timeout = time.Minute
+
+	var reports []kube.WaitProgress
+	instAction.WaitProgress = func(p kube.WaitProgress) {
+		reports = append(reports, p)
+	}
+
+	_, err := instAction.Run(buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wait-with-progress"}, client.waits)
+	assert.True(t, client.waitForJobs)
+	require.Len(t, reports, 1)
+	assert.Equal(t, time.Minute, reports[0].Timeout)
+	assert.Equal(t, "Deployment/api", reports[0].Pending()[0].Object)
+}
+
+func TestUpgradeRelease_WaitWithoutProgress(t *testing.T) {
+	upAction := upgradeAction(t)
+	client := &progressKubeClient{}
+	upAction.cfg.KubeClient = client
+	upAction.Wait = true
+
+	rel := releaseStub()
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wait"}, client.waits)
+}
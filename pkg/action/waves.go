@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// applyInWaves applies the waves of resources one at a time with apply.
+//
+// Every wave but the last one has to become ready, and its Jobs complete,
+// before the next wave is applied. Each wait is given the whole timeout.
+// Waiting for the last wave is left to the caller, as it depends on --wait.
+// No more waves are applied once ctx is done.
+func (cfg *Configuration) applyInWaves(ctx context.Context, rel *release.Release, waves []kube.ResourceList, timeout time.Duration, progress kube.ProgressFunc, apply func(kube.ResourceList) (*kube.Result, error)) (*kube.Result, error) {
+	result := &kube.Result{}
+	for i, wave := range waves {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		res, err := apply(wave)
+		appendResult(result, res)
+		cfg.notifyResourcesChanged(rel, res)
+		if err != nil {
+			return result, err
+		}
+		if i == len(waves)-1 {
+			break
+		}
+		cfg.Log("waiting for wave %d of %d (%d resources) to be ready", i+1, len(waves), len(wave))
+		if err := cfg.waitForResources(ctx, rel, wave, timeout, true, progress); err != nil {
+			return result, errors.Wrapf(err, "wave %d of %d did not become ready", i+1, len(waves))
+		}
+	}
+	return result, nil
+}
+
+// updateInWaves updates the resources in the cluster from original to target
+// one wave at a time, see applyInWaves. Resources of original that are not
+// in target are deleted once all the waves are applied, the highest wave
+// first.
+func (cfg *Configuration) updateInWaves(ctx context.Context, rel *release.Release, original, target kube.ResourceList, force, serverSide, forceConflicts bool, timeout time.Duration, progress kube.ProgressFunc) (*kube.Result, error) {
+	waves, err := target.Waves()
+	if err != nil {
+		return &kube.Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return &kube.Result{}, err
+	}
+	if len(waves) <= 1 {
+		result, err := cfg.updateResources(ctx, original, target, force, serverSide, forceConflicts)
+		cfg.notifyResourcesChanged(rel, result)
+		return result, err
+	}
+
+	result, err := cfg.applyInWaves(ctx, rel, waves, timeout, progress, func(wave kube.ResourceList) (*kube.Result, error) {
+		return cfg.updateResources(ctx, original.Intersect(wave), wave, force, serverSide, forceConflicts)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	removed, err := original.Difference(target).Waves()
+	if err != nil {
+		return result, err
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		res, err := cfg.updateResources(ctx, removed[i], kube.ResourceList{}, force, serverSide, forceConflicts)
+		appendResult(result, res)
+		cfg.notifyResourcesChanged(rel, res)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// deleteInWaves deletes the resources one wave at a time, the highest wave
+// first, with the given propagation policy. Clients that do not implement
+// kube.InterfaceDeletion always use the background policy. Resources of a wave are deleted
+// even if deleting a previous wave failed. If wait is set, the deleted
+// resources of a wave have to be gone before the next wave is deleted. Each
+// wait is given the whole timeout. No more waves are deleted once ctx is
+// done, and if the KubeClient implements kube.InterfaceContext, the deletion
+// of the current wave stops too.
+func (cfg *Configuration) deleteInWaves(ctx context.Context, rel *release.Release, resources kube.ResourceList, policy metav1.DeletionPropagation, wait bool, timeout time.Duration) []error {
+	kubeClient, ok := cfg.KubeClient.(kube.InterfaceDeletion)
+	contextClient, withContext := cfg.KubeClient.(kube.InterfaceContext)
+	if !ok && wait {
+		cfg.Log("warning: the Kubernetes client cannot wait for resources to be deleted")
+		wait = false
+	}
+
+	waves, err := resources.Waves()
+	if err != nil {
+		cfg.Log("warning: deleting all resources at once: %s", err)
+		waves = []kube.ResourceList{resources}
+	}
+	var errs []error
+	for i := len(waves) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return append(errs, err)
+		}
+		var res *kube.Result
+		var e []error
+		if withContext {
+			res, e = contextClient.DeleteWithContext(ctx, waves[i], policy)
+		} else if ok {
+			res, e = kubeClient.DeleteWithPropagationPolicy(waves[i], policy)
+		} else {
+			res, e = cfg.KubeClient.Delete(waves[i])
+		}
+		cfg.notifyResourcesChanged(rel, res)
+		errs = append(errs, e...)
+		if wait && res != nil && len(res.Deleted) > 0 {
+			if err := kubeClient.WaitForDelete(res.Deleted, timeout); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+func appendResult(result, res *kube.Result) {
+	if res == nil {
+		return
+	}
+	result.Created = append(result.Created, res.Created...)
+	result.Updated = append(result.Updated, res.Updated...)
+	result.Deleted = append(result.Deleted, res.Deleted...)
+}
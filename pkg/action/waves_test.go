@@ -0,0 +1,270 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// waveRecordingKubeClient builds config maps from the manifest and records
+// the operations done on them. The order of the resources of an operation is
+// not recorded.
+type waveRecordingKubeClient struct {
+	kubefake.PrintingKubeClient
+	t *testing.T
+	// failWait fails waiting for the resources containing the named one
+	failWait string
+
+	ops []string
+}
+
+func (c *waveRecordingKubeClient) Build(reader io.Reader, _ bool) (kube.ResourceList, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var resources kube.ResourceList
+	for _, m := range releaseutil.SplitManifests(string(data)) {
+		var cm v1.ConfigMap
+		if err := yaml.Unmarshal([]byte(m), &cm); err != nil {
+			return nil, err
+		}
+		if cm.Name == "" {
+			continue
+		}
+		info := newConfigMapResources(c.t, nil, cm.Name)[0]
+		info.Object.(*v1.ConfigMap).Annotations = cm.Annotations
+		resources = append(resources, info)
+	}
+	return resources, nil
+}
+
+func (c *waveRecordingKubeClient) record(op string, resources kube.ResourceList) {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	c.ops = append(c.ops, op+" "+strings.Join(names, ","))
+}
+
+func (c *waveRecordingKubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.record("create", resources)
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *waveRecordingKubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	if len(target) > 0 {
+		c.record("update", target)
+	}
+	if removed := original.Difference(target); len(removed) > 0 {
+		c.record("remove", removed)
+	}
+	return &kube.Result{Created: target.Difference(original), Updated: target.Intersect(original)}, nil
+}
+
+func (c *waveRecordingKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.record("delete", resources)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *waveRecordingKubeClient) Wait(resources kube.ResourceList, _ time.Duration) error {
+	c.record("wait", resources)
+	return nil
+}
+
+func (c *waveRecordingKubeClient) WaitWithJobs(resources kube.ResourceList, _ time.Duration) error {
+	c.record("wait-jobs", resources)
+	for _, r := range resources {
+		if r.Name == c.failWait {
+			return fmt.Errorf("%s is not ready", r.Name)
+		}
+	}
+	return nil
+}
+
+func configMapManifest(name, wave string) string {
+	m := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n"
+	if wave != "" {
+		m += "  annotations:\n    " + kube.WaveAnnotation + ": \"" + wave + "\"\n"
+	}
+	return m
+}
+
+// withWaveTemplates replaces the templates of the chart with config maps in
+// the given waves.
+func withWaveTemplates(waves map[string]string) chartOption {
+	return func(opts *chartOptions) {
+		var names []string
+		for name := range waves {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		opts.Templates = nil
+		for _, name := range names {
+			opts.Templates = append(opts.Templates, &chart.File{
+				Name: "templates/" + name + ".yaml",
+				Data: []byte(configMapManifest(name, waves[name])),
+			})
+		}
+	}
+}
+
+func TestInstallRelease_Waves(t *testing.T) {
+	instAction := installAction(t)
+	client := &waveRecordingKubeClient{t: t}
+	instAction.cfg.KubeClient = client
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"db": "-1", "app": "", "web": "1", "worker": "1"}))
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"create db",
+		"wait-jobs db",
+		"create app",
+		"wait-jobs app",
+		"create web,worker",
+	}, client.ops)
+}
+
+func TestInstallRelease_WavesWait(t *testing.T) {
+	instAction := installAction(t)
+	client := &waveRecordingKubeClient{t: t}
+	instAction.cfg.KubeClient = client
+	instAction.Wait = true
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"db": "-1", "app": ""}))
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"create db",
+		"wait-jobs db",
+		"create app",
+		"wait app,db",
+	}, client.ops)
+}
+
+func TestInstallRelease_WaveNotReady(t *testing.T) {
+	instAction := installAction(t)
+	client := &waveRecordingKubeClient{t: t, failWait: "db"}
+	instAction.cfg.KubeClient = client
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"db": "-1", "app": ""}))
+	res, err := instAction.Run(chrt, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wave 1 of 2 did not become ready: db is not ready")
+	assert.Equal(t, release.StatusFailed, res.Info.Status)
+	assert.Equal(t, []string{"create db", "wait-jobs db"}, client.ops)
+}
+
+func TestInstallRelease_InvalidWave(t *testing.T) {
+	instAction := installAction(t)
+	instAction.cfg.KubeClient = &waveRecordingKubeClient{t: t}
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"db": "first"}))
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid helm.sh/wave annotation "first" on "db"`)
+}
+
+func TestUpgradeRelease_Waves(t *testing.T) {
+	upAction := upgradeAction(t)
+	client := &waveRecordingKubeClient{t: t}
+	upAction.cfg.KubeClient = client
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Manifest = strings.Join([]string{
+		configMapManifest("db", "-1"),
+		configMapManifest("app", ""),
+		configMapManifest("old", "2"),
+		configMapManifest("legacy", ""),
+	}, "---\n")
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"db": "-1", "app": "", "web": "1"}))
+	_, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"update db",
+		"wait-jobs db",
+		"update app",
+		"wait-jobs app",
+		"update web",
+		"remove old",
+		"remove legacy",
+	}, client.ops)
+}
+
+func TestUpgradeRelease_SingleWave(t *testing.T) {
+	upAction := upgradeAction(t)
+	client := &waveRecordingKubeClient{t: t}
+	upAction.cfg.KubeClient = client
+
+	rel := releaseStub()
+	rel.Name = "no-waves"
+	rel.Manifest = configMapManifest("app", "") + "---\n" + configMapManifest("old", "")
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	chrt := buildChart(withWaveTemplates(map[string]string{"app": ""}))
+	_, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"update app", "remove old"}, client.ops)
+}
+
+func TestUninstallRelease_Waves(t *testing.T) {
+	unAction := uninstallAction(t)
+	client := &waveRecordingKubeClient{t: t}
+	unAction.cfg.KubeClient = client
+	unAction.DisableHooks = true
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Manifest = strings.Join([]string{
+		configMapManifest("db", "-1"),
+		configMapManifest("app", ""),
+		configMapManifest("web", "1"),
+	}, "---\n")
+	require.NoError(t, unAction.cfg.Releases.Create(rel))
+
+	_, err := unAction.Run(rel.Name)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"delete web", "delete app", "delete db"}, client.ops)
+}
@@ -129,6 +129,9 @@ type ReleaseOptions struct {
 	Revision  int
 	IsUpgrade bool
 	IsInstall bool
+	// FailureReason is the error of the failed operation when rendering
+	// failure hooks, and empty otherwise.
+	FailureReason string
 }
 
 // ToRenderValues composes the struct from the data coming from the Releases, Charts and Values files
@@ -142,12 +145,13 @@ func ToRenderValues(chrt *chart.Chart, chrtVals map[string]interface{}, options
 		"Chart":        chrt.Metadata,
 		"Capabilities": caps,
 		"Release": map[string]interface{}{
-			"Name":      options.Name,
-			"Namespace": options.Namespace,
-			"IsUpgrade": options.IsUpgrade,
-			"IsInstall": options.IsInstall,
-			"Revision":  options.Revision,
-			"Service":   "Helm",
+			"Name":          options.Name,
+			"Namespace":     options.Namespace,
+			"IsUpgrade":     options.IsUpgrade,
+			"IsInstall":     options.IsInstall,
+			"Revision":      options.Revision,
+			"FailureReason": options.FailureReason,
+			"Service":       "Helm",
 		},
 	}
 
@@ -128,6 +128,9 @@ func TestToRenderValues(t *testing.T) {
 	if !relmap["IsInstall"].(bool) {
 		t.Errorf("Expected install to be true.")
 	}
+	if reason := relmap["FailureReason"]; reason.(string) != "" {
+		t.Errorf("Expected no failure reason, got %q", reason)
+	}
 	if !res["Capabilities"].(*Capabilities).APIVersions.Has("v1") {
 		t.Error("Expected Capabilities to have v1 as an API")
 	}
@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// FieldConflict is a field of an object that is owned by another field manager.
+type FieldConflict struct {
+	// Manager is the field manager that owns the field.
+	Manager string
+	// Field is the path of the field, e.g. ".spec.replicas".
+	Field string
+	// Message is the message returned by the API server.
+	Message string
+}
+
+// ApplyConflictError is returned when a server-side apply fails because fields
+// of the object are owned by other field managers.
+type ApplyConflictError struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Conflicts []FieldConflict
+}
+
+func (e *ApplyConflictError) Error() string {
+	fields := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fields = append(fields, fmt.Sprintf("%s (owned by %q)", c.Field, c.Manager))
+	}
+	return fmt.Sprintf("conflict applying %s %q: fields managed by other managers: %s. Use --force-conflicts to take ownership of these fields",
+		e.Kind, e.Name, strings.Join(fields, ", "))
+}
+
+// UpdateServerSide updates the resources in target with server-side apply,
+// using Helm's field manager. It creates the resources that do not exist yet
+// and deletes the resources in original that are not present in target.
+//
+// If forceConflicts is true, fields owned by other field managers are taken
+// over. Otherwise an *ApplyConflictError is returned for each object that has
+// conflicting fields.
+func (c *Client) UpdateServerSide(original, target ResourceList, forceConflicts bool) (*Result, error) {
+	apply := func(info *resource.Info) error {
+		return applyResource(context.Background(), c, info, forceConflicts)
+	}
+	return c.update(context.Background(), original, target, apply, func(info *resource.Info, _ runtime.Object) error {
+		return apply(info)
+	})
+}
+
+func applyResource(ctx context.Context, c *Client, target *resource.Info, forceConflicts bool) error {
+	kind := target.Mapping.GroupVersionKind.Kind
+	data, err := json.Marshal(target.Object)
+	if err != nil {
+		return errors.Wrapf(err, "serializing %q with kind %s", target.Name, kind)
+	}
+
+	obj, err := newHelper(ctx, target).Patch(target.Namespace, target.Name, types.ApplyPatchType, data, &metav1.PatchOptions{
+		Force: &forceConflicts,
+	})
+	if err != nil {
+		if conflicts := applyConflicts(err); len(conflicts) > 0 {
+			return &ApplyConflictError{
+				Kind:      kind,
+				Name:      target.Name,
+				Namespace: target.Namespace,
+				Conflicts: conflicts,
+			}
+		}
+		return errors.Wrapf(err, "cannot apply %q with kind %s", target.Name, kind)
+	}
+	c.Log("Applied %q with kind %s", target.Name, kind)
+
+	return target.Refresh(obj, true)
+}
+
+// conflictManager matches the manager in a field manager conflict message,
+// e.g. `conflict with "kube-controller-manager" using apps/v1`.
+var conflictManager = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// applyConflicts returns the field manager conflicts described by err, if any.
+func applyConflicts(err error) []FieldConflict {
+	if !apierrors.IsConflict(err) {
+		return nil
+	}
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return nil
+	}
+
+	var conflicts []FieldConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := FieldConflict{Field: cause.Field, Message: cause.Message}
+		if m := conflictManager.FindStringSubmatch(cause.Message); m != nil {
+			conflict.Manager = m[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
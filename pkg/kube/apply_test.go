@@ -0,0 +1,198 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func conflictBody() *metav1.Status {
+	return &metav1.Status{
+		Code:    http.StatusConflict,
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonConflict,
+		Message: "Apply failed with 1 conflict: conflict with \"kube-controller-manager\" using v1: .spec.containers",
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: "conflict with \"kube-controller-manager\" using v1",
+				Field:   ".spec.containers",
+			}},
+		},
+	}
+}
+
+func TestUpdateServerSide(t *testing.T) {
+	listA := newPodList("starfish", "squid")
+	listB := newPodList("starfish", "dolphin")
+
+	var actions []string
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			actions = append(actions, p+":"+m)
+			switch {
+			case p == "/namespaces/default/pods/starfish" && m == "GET":
+				return newResponse(200, &listA.Items[0])
+			case p == "/namespaces/default/pods/dolphin" && m == "GET":
+				return newResponse(404, notFoundBody())
+			case (p == "/namespaces/default/pods/starfish" || p == "/namespaces/default/pods/dolphin") && m == "PATCH":
+				if ct := req.Header.Get("Content-Type"); ct != string(types.ApplyPatchType) {
+					t.Errorf("expected apply patch, got content type %s", ct)
+				}
+				if force := req.URL.Query().Get("force"); force != "true" {
+					t.Errorf("expected force=true, got %q", force)
+				}
+				return newResponse(200, &listB.Items[0])
+			case p == "/namespaces/default/pods/squid" && m == "GET":
+				return newResponse(200, &listA.Items[1])
+			case p == "/namespaces/default/pods/squid" && m == "DELETE":
+				return newResponse(200, &listA.Items[1])
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UpdateServerSide(first, second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created) != 1 {
+		t.Errorf("expected 1 resource created, got %d", len(result.Created))
+	}
+	if len(result.Updated) != 1 {
+		t.Errorf("expected 1 resource updated, got %d", len(result.Updated))
+	}
+	if len(result.Deleted) != 1 {
+		t.Errorf("expected 1 resource deleted, got %d", len(result.Deleted))
+	}
+
+	expectedActions := []string{
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:PATCH",
+		"/namespaces/default/pods/dolphin:GET",
+		"/namespaces/default/pods/dolphin:PATCH",
+		"/namespaces/default/pods/squid:GET",
+		"/namespaces/default/pods/squid:DELETE",
+	}
+	if len(expectedActions) != len(actions) {
+		t.Fatalf("unexpected requests, expected %v, got %v", expectedActions, actions)
+	}
+	for k, v := range expectedActions {
+		if actions[k] != v {
+			t.Errorf("expected %s request got %s", v, actions[k])
+		}
+	}
+}
+
+func TestUpdateServerSideConflict(t *testing.T) {
+	list := newPodList("starfish")
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch req.Method {
+			case "GET":
+				return newResponse(200, &list.Items[0])
+			case "PATCH":
+				return newResponse(409, conflictBody())
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	resources, err := c.Build(objBody(&list), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.UpdateServerSide(resources, resources, false)
+	if err == nil {
+		t.Fatal("expected a conflict error")
+	}
+	conflictErr := &ApplyConflictError{}
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected an ApplyConflictError, got %T: %s", err, err)
+	}
+	if len(conflictErr.Conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(conflictErr.Conflicts))
+	}
+	if c := conflictErr.Conflicts[0]; c.Manager != "kube-controller-manager" || c.Field != ".spec.containers" {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+}
+
+func TestUpdateServerSideConflicts(t *testing.T) {
+	list := newPodList("starfish", "otter")
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch req.Method {
+			case "GET":
+				return newResponse(200, &list.Items[0])
+			case "PATCH":
+				return newResponse(409, conflictBody())
+			default:
+				t.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return newResponse(http.StatusMethodNotAllowed, &metav1.Status{Status: metav1.StatusFailure})
+			}
+		}),
+	}
+	resources, err := c.Build(objBody(&list), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each conflict is kept when several resources fail
+	_, err = c.UpdateServerSide(resources, resources, false)
+	updateErrs := UpdateErrors{}
+	if !errors.As(err, &updateErrs) || len(updateErrs) != 2 {
+		t.Fatalf("expected 2 update errors, got %T: %v", err, err)
+	}
+	conflictErr := &ApplyConflictError{}
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected an ApplyConflictError, got %T: %s", err, err)
+	}
+	if !strings.Contains(err.Error(), " && ") {
+		t.Errorf("expected the messages of both errors, got %q", err)
+	}
+}
@@ -125,8 +125,16 @@ func (c *Client) IsReachable() error {
 
 // Create creates Kubernetes resources specified in the resource list.
 func (c *Client) Create(resources ResourceList) (*Result, error) {
+	return c.CreateWithContext(context.Background(), resources)
+}
+
+// CreateWithContext creates the resources like Create, and cancels the
+// requests to the API server when ctx is done.
+func (c *Client) CreateWithContext(ctx context.Context, resources ResourceList) (*Result, error) {
 	c.Log("creating %d resource(s)", len(resources))
-	if err := perform(resources, createResource); err != nil {
+	if err := perform(resources, func(info *resource.Info) error {
+		return createResource(ctx, info)
+	}); err != nil {
 		return nil, err
 	}
 	return &Result{Created: resources}, nil
@@ -134,32 +142,41 @@ func (c *Client) Create(resources ResourceList) (*Result, error) {
 
 // Wait waits up to the given timeout for the specified resources to be ready.
 func (c *Client) Wait(resources ResourceList, timeout time.Duration) error {
-	cs, err := c.getKubeClient()
-	if err != nil {
-		return err
-	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true))
+	return c.WaitWithContext(context.Background(), resources, timeout, false, nil)
+}
+
+// WaitForDelete waits up to the given timeout for the specified resources to
+// be gone from the cluster.
+func (c *Client) WaitForDelete(resources ResourceList, timeout time.Duration) error {
 	w := waiter{
-		c:       checker,
 		log:     c.Log,
 		timeout: timeout,
 	}
-	return w.waitForResources(resources)
+	return w.waitForDeletedResources(resources)
 }
 
 // WaitWithJobs wait up to the given timeout for the specified resources to be ready, including jobs.
 func (c *Client) WaitWithJobs(resources ResourceList, timeout time.Duration) error {
+	return c.WaitWithContext(context.Background(), resources, timeout, true, nil)
+}
+
+// WaitWithContext waits up to the given timeout for the specified resources
+// to be ready, including jobs if waitForJobs is true, or until ctx is done.
+// If progress is not nil, it receives the readiness of the resources every
+// time they are checked.
+func (c *Client) WaitWithContext(ctx context.Context, resources ResourceList, timeout time.Duration, waitForJobs bool, progress ProgressFunc) error {
 	cs, err := c.getKubeClient()
 	if err != nil {
 		return err
 	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true), CheckJobs(true))
+	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true), CheckJobs(waitForJobs))
 	w := waiter{
-		c:       checker,
-		log:     c.Log,
-		timeout: timeout,
+		c:        checker,
+		log:      c.Log,
+		timeout:  timeout,
+		progress: progress,
 	}
-	return w.waitForResources(resources)
+	return w.waitForResources(ctx, resources)
 }
 
 func (c *Client) namespace() string {
@@ -203,7 +220,25 @@ func (c *Client) Build(reader io.Reader, validate bool) (ResourceList, error) {
 // resource updates, creations, and deletions that were attempted. These can be
 // used for cleanup or other logging purposes.
 func (c *Client) Update(original, target ResourceList, force bool) (*Result, error) {
-	updateErrors := []string{}
+	return c.UpdateWithContext(context.Background(), original, target, force)
+}
+
+// UpdateWithContext updates the resources like Update, and cancels the
+// requests to the API server when ctx is done.
+func (c *Client) UpdateWithContext(ctx context.Context, original, target ResourceList, force bool) (*Result, error) {
+	return c.update(ctx, original, target, func(info *resource.Info) error {
+		return createResource(ctx, info)
+	}, func(info *resource.Info, current runtime.Object) error {
+		return updateResource(ctx, c, info, current, force)
+	})
+}
+
+// update creates the resources in target that do not exist yet with create,
+// updates the ones that exist with update and deletes the resources in original
+// that are not present in target. The requests of update itself are canceled
+// when ctx is done.
+func (c *Client) update(ctx context.Context, original, target ResourceList, create func(*resource.Info) error, update func(*resource.Info, runtime.Object) error) (*Result, error) {
+	updateErrors := []error{}
 	res := &Result{}
 
 	c.Log("checking %d resources for changes", len(target))
@@ -212,8 +247,7 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			return err
 		}
 
-		helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager())
-		if _, err := helper.Get(info.Namespace, info.Name); err != nil {
+		if _, err := newHelper(ctx, info).Get(info.Namespace, info.Name); err != nil {
 			if !apierrors.IsNotFound(err) {
 				return errors.Wrap(err, "could not get information about the resource")
 			}
@@ -222,7 +256,7 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			res.Created = append(res.Created, info)
 
 			// Since the resource does not exist, create it.
-			if err := createResource(info); err != nil {
+			if err := create(info); err != nil {
 				return errors.Wrap(err, "failed to create resource")
 			}
 
@@ -237,9 +271,9 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			return errors.Errorf("no %s with the name %q found", kind, info.Name)
 		}
 
-		if err := updateResource(c, info, originalInfo.Object, force); err != nil {
+		if err := update(info, originalInfo.Object); err != nil {
 			c.Log("error updating the resource %q:\n\t %v", info.Name, err)
-			updateErrors = append(updateErrors, err.Error())
+			updateErrors = append(updateErrors, err)
 		}
 		// Because we check for errors later, append the info regardless
 		res.Updated = append(res.Updated, info)
@@ -250,14 +284,17 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 	switch {
 	case err != nil:
 		return res, err
+	case len(updateErrors) == 1:
+		// Return a single error as is, so that callers can inspect it
+		return res, updateErrors[0]
 	case len(updateErrors) != 0:
-		return res, errors.Errorf(strings.Join(updateErrors, " && "))
+		return res, UpdateErrors(updateErrors)
 	}
 
 	for _, info := range original.Difference(target) {
 		c.Log("Deleting %q in %s...", info.Name, info.Namespace)
 
-		if err := info.Get(); err != nil {
+		if err := getInfo(ctx, info); err != nil {
 			c.Log("Unable to get obj %q, err: %s", info.Name, err)
 			continue
 		}
@@ -269,7 +306,7 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			c.Log("Skipping delete of %q due to annotation [%s=%s]", info.Name, ResourcePolicyAnno, KeepPolicy)
 			continue
 		}
-		if err := deleteResource(info); err != nil {
+		if err := deleteResource(ctx, info, metav1.DeletePropagationBackground); err != nil {
 			c.Log("Failed to delete %q, err: %s", info.ObjectName(), err)
 			continue
 		}
@@ -283,12 +320,25 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 // errors. All successfully deleted items will be returned in the `Deleted`
 // ResourceList that is part of the result.
 func (c *Client) Delete(resources ResourceList) (*Result, []error) {
+	return c.DeleteWithPropagationPolicy(resources, metav1.DeletePropagationBackground)
+}
+
+// DeleteWithPropagationPolicy deletes Kubernetes resources like Delete, and
+// deletes their dependents in the background or in the foreground, or orphans
+// them, according to the policy.
+func (c *Client) DeleteWithPropagationPolicy(resources ResourceList, policy metav1.DeletionPropagation) (*Result, []error) {
+	return c.DeleteWithContext(context.Background(), resources, policy)
+}
+
+// DeleteWithContext deletes the resources like DeleteWithPropagationPolicy,
+// and cancels the requests to the API server when ctx is done.
+func (c *Client) DeleteWithContext(ctx context.Context, resources ResourceList, policy metav1.DeletionPropagation) (*Result, []error) {
 	var errs []error
 	res := &Result{}
 	mtx := sync.Mutex{}
 	err := perform(resources, func(info *resource.Info) error {
 		c.Log("Starting delete for %q %s", info.Name, info.Mapping.GroupVersionKind.Kind)
-		if err := c.skipIfNotFound(deleteResource(info)); err != nil {
+		if err := c.skipIfNotFound(deleteResource(ctx, info, policy)); err != nil {
 			mtx.Lock()
 			defer mtx.Unlock()
 			// Collect the error and continue on
@@ -322,9 +372,9 @@ func (c *Client) skipIfNotFound(err error) error {
 	return err
 }
 
-func (c *Client) watchTimeout(t time.Duration) func(*resource.Info) error {
+func (c *Client) watchTimeout(ctx context.Context, t time.Duration) func(*resource.Info) error {
 	return func(info *resource.Info) error {
-		return c.watchUntilReady(t, info)
+		return c.watchUntilReady(ctx, t, info)
 	}
 }
 
@@ -345,7 +395,13 @@ func (c *Client) watchTimeout(t time.Duration) func(*resource.Info) error {
 func (c *Client) WatchUntilReady(resources ResourceList, timeout time.Duration) error {
 	// For jobs, there's also the option to do poll c.Jobs(namespace).Get():
 	// https://github.com/adamreese/kubernetes/blob/master/test/e2e/job.go#L291-L300
-	return perform(resources, c.watchTimeout(timeout))
+	return c.WatchUntilReadyWithContext(context.Background(), resources, timeout)
+}
+
+// WatchUntilReadyWithContext watches the resources like WatchUntilReady, or
+// until ctx is done.
+func (c *Client) WatchUntilReadyWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error {
+	return perform(resources, c.watchTimeout(ctx, timeout))
 }
 
 func perform(infos ResourceList, fn func(*resource.Info) error) error {
@@ -402,22 +458,21 @@ func batchPerform(infos ResourceList, fn func(*resource.Info) error, errs chan<-
 	}
 }
 
-func createResource(info *resource.Info) error {
-	obj, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()).Create(info.Namespace, true, info.Object)
+func createResource(ctx context.Context, info *resource.Info) error {
+	obj, err := newHelper(ctx, info).Create(info.Namespace, true, info.Object)
 	if err != nil {
 		return err
 	}
 	return info.Refresh(obj, true)
 }
 
-func deleteResource(info *resource.Info) error {
-	policy := metav1.DeletePropagationBackground
+func deleteResource(ctx context.Context, info *resource.Info, policy metav1.DeletionPropagation) error {
 	opts := &metav1.DeleteOptions{PropagationPolicy: &policy}
-	_, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()).DeleteWithOptions(info.Namespace, info.Name, opts)
+	_, err := newHelper(ctx, info).DeleteWithOptions(info.Namespace, info.Name, opts)
 	return err
 }
 
-func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.PatchType, error) {
+func createPatch(ctx context.Context, target *resource.Info, current runtime.Object) ([]byte, types.PatchType, error) {
 	oldData, err := json.Marshal(current)
 	if err != nil {
 		return nil, types.StrategicMergePatchType, errors.Wrap(err, "serializing current configuration")
@@ -428,8 +483,7 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 	}
 
 	// Fetch the current object for the three way merge
-	helper := resource.NewHelper(target.Client, target.Mapping).WithFieldManager(getManagedFieldsManager())
-	currentObj, err := helper.Get(target.Namespace, target.Name)
+	currentObj, err := newHelper(ctx, target).Get(target.Namespace, target.Name)
 	if err != nil && !apierrors.IsNotFound(err) {
 		return nil, types.StrategicMergePatchType, errors.Wrapf(err, "unable to get data for current object %s/%s", target.Namespace, target.Name)
 	}
@@ -467,10 +521,10 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 	return patch, types.StrategicMergePatchType, err
 }
 
-func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool) error {
+func updateResource(ctx context.Context, c *Client, target *resource.Info, currentObj runtime.Object, force bool) error {
 	var (
 		obj    runtime.Object
-		helper = resource.NewHelper(target.Client, target.Mapping).WithFieldManager(getManagedFieldsManager())
+		helper = newHelper(ctx, target)
 		kind   = target.Mapping.GroupVersionKind.Kind
 	)
 
@@ -483,7 +537,7 @@ func updateResource(c *Client, target *resource.Info, currentObj runtime.Object,
 		}
 		c.Log("Replaced %q with kind %s for kind %s", target.Name, currentObj.GetObjectKind().GroupVersionKind().Kind, kind)
 	} else {
-		patch, patchType, err := createPatch(target, currentObj)
+		patch, patchType, err := createPatch(ctx, target, currentObj)
 		if err != nil {
 			return errors.Wrap(err, "failed to create patch")
 		}
@@ -492,7 +546,7 @@ func updateResource(c *Client, target *resource.Info, currentObj runtime.Object,
 			c.Log("Looks like there are no changes for %s %q", target.Mapping.GroupVersionKind.Kind, target.Name)
 			// This needs to happen to make sure that Helm has the latest info from the API
 			// Otherwise there will be no labels and other functions that use labels will panic
-			if err := target.Get(); err != nil {
+			if err := getInfo(ctx, target); err != nil {
 				return errors.Wrap(err, "failed to refresh resource information")
 			}
 			return nil
@@ -508,7 +562,7 @@ func updateResource(c *Client, target *resource.Info, currentObj runtime.Object,
 	return nil
 }
 
-func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) error {
+func (c *Client) watchUntilReady(parent context.Context, timeout time.Duration, info *resource.Info) error {
 	kind := info.Mapping.GroupVersionKind.Kind
 	switch kind {
 	case "Job", "Pod":
@@ -532,7 +586,7 @@ func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) err
 	// In the future, we might want to add some special logic for types
 	// like Ingress, Volume, etc.
 
-	ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), timeout)
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(parent, timeout)
 	defer cancel()
 	_, err = watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, nil, func(e watch.Event) (bool, error) {
 		// Make sure the incoming object is versioned as we use unstructured
@@ -563,6 +617,10 @@ func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) err
 			return false, nil
 		}
 	})
+	if err != nil && parent.Err() != nil {
+		// The watch stopped because the operation was canceled, not because it timed out
+		return parent.Err()
+	}
 	return err
 }
 
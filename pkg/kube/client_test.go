@@ -18,10 +18,14 @@ package kube
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
 	"strings"
+	"sync"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
@@ -214,6 +218,129 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestDeleteWithPropagationPolicy(t *testing.T) {
+	list := newPodList("starfish", "otter")
+
+	for _, policy := range []metav1.DeletionPropagation{metav1.DeletePropagationBackground, metav1.DeletePropagationForeground, metav1.DeletePropagationOrphan} {
+		t.Run(string(policy), func(t *testing.T) {
+			// Resources are deleted concurrently, so every resource gets its
+			// own fake client, which is not safe for concurrent use
+			var mu sync.Mutex
+			var deleted []string
+			handler := func(req *http.Request) (*http.Response, error) {
+				if req.Method != "DELETE" {
+					t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+					return newResponse(http.StatusMethodNotAllowed, &metav1.Status{Status: metav1.StatusFailure})
+				}
+				var opts metav1.DeleteOptions
+				if err := json.NewDecoder(req.Body).Decode(&opts); err != nil {
+					t.Errorf("unable to decode the delete options: %s", err)
+					return newResponse(http.StatusBadRequest, &metav1.Status{Status: metav1.StatusFailure})
+				}
+				if opts.PropagationPolicy == nil || *opts.PropagationPolicy != policy {
+					t.Errorf("expected propagation policy %s, got %v", policy, opts.PropagationPolicy)
+				}
+				mu.Lock()
+				deleted = append(deleted, req.URL.Path)
+				mu.Unlock()
+				return newResponse(200, &metav1.Status{Status: metav1.StatusSuccess})
+			}
+
+			c := newTestClient(t)
+			c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+				NegotiatedSerializer: unstructuredSerializer,
+				Client:               fake.CreateHTTPClient(handler),
+			}
+			resources, err := c.Build(objBody(&list), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, info := range resources {
+				info.Client = &fake.RESTClient{
+					NegotiatedSerializer: unstructuredSerializer,
+					Client:               fake.CreateHTTPClient(handler),
+				}
+			}
+
+			result, errs := c.DeleteWithPropagationPolicy(resources, policy)
+			if errs != nil {
+				t.Fatal(errs)
+			}
+			if len(result.Deleted) != 2 || len(deleted) != 2 {
+				t.Errorf("expected 2 resources to be deleted, got %d (%v)", len(result.Deleted), deleted)
+			}
+		})
+	}
+}
+
+type contextKey struct{}
+
+func TestContextVariants(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contextKey{}, "install")
+	list := newPodList("starfish", "otter")
+	changed := newPodList("starfish", "otter")
+	changed.Items[0].Spec.Containers[0].Ports = []v1.ContainerPort{{Name: "https", ContainerPort: 443}}
+
+	var mu sync.Mutex
+	var actions []string
+	handler := func(req *http.Request) (*http.Response, error) {
+		if v := req.Context().Value(contextKey{}); v != "install" {
+			t.Errorf("expected the context of the operation for %s %s, got value %v", req.Method, req.URL.Path, v)
+		}
+		mu.Lock()
+		actions = append(actions, req.Method)
+		mu.Unlock()
+		switch req.Method {
+		case "DELETE":
+			return newResponse(200, &metav1.Status{Status: metav1.StatusSuccess})
+		case "POST":
+			var pod v1.Pod
+			if err := json.NewDecoder(req.Body).Decode(&pod); err != nil {
+				t.Errorf("unable to decode the created pod: %s", err)
+			}
+			return newResponse(201, &pod)
+		default:
+			pod := newPod(path.Base(req.URL.Path))
+			return newResponse(200, &pod)
+		}
+	}
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client:               fake.CreateHTTPClient(handler),
+	}
+	build := func(obj runtime.Object) ResourceList {
+		resources, err := c.Build(objBody(obj), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Resources are created and deleted concurrently, so every resource
+		// gets its own fake client, which is not safe for concurrent use
+		for _, info := range resources {
+			info.Client = &fake.RESTClient{
+				NegotiatedSerializer: unstructuredSerializer,
+				Client:               fake.CreateHTTPClient(handler),
+			}
+		}
+		return resources
+	}
+	original, target := build(&list), build(&changed)
+
+	if _, err := c.CreateWithContext(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateWithContext(ctx, original, target, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, errs := c.DeleteWithContext(ctx, target, metav1.DeletePropagationBackground); errs != nil {
+		t.Fatal(errs)
+	}
+	if len(actions) == 0 {
+		t.Error("expected requests to the API server")
+	}
+}
+
 func TestBuild(t *testing.T) {
 	tests := []struct {
 		name      string
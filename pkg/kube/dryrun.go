@@ -0,0 +1,90 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// InterfaceServerDryRun is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceServerDryRun and integrate its method(s) into the Interface.
+type InterfaceServerDryRun interface {
+	// DryRunUpdate sends the resources in target to the API server as dry-run
+	// requests: the ones that do not exist yet are created and the others are
+	// patched like Update does. Nothing is persisted and nothing is deleted.
+	// It returns the objects as the API server would have stored them, after
+	// admission and defaulting.
+	DryRunUpdate(original, target ResourceList) (ResourceList, error)
+}
+
+var _ InterfaceServerDryRun = (*Client)(nil)
+
+// DryRunUpdate sends the resources in target to the API server with
+// dryRun=All and returns the objects the API server responded with.
+func (c *Client) DryRunUpdate(original, target ResourceList) (ResourceList, error) {
+	var result ResourceList
+	record := func(info *resource.Info, obj runtime.Object) {
+		result = append(result, &resource.Info{
+			Client:    info.Client,
+			Mapping:   info.Mapping,
+			Namespace: info.Namespace,
+			Name:      info.Name,
+			Source:    info.Source,
+			Object:    obj,
+		})
+	}
+
+	// Only the resources in target are sent, so that none are deleted
+	_, err := c.update(context.Background(), original.Intersect(target), target, func(info *resource.Info) error {
+		obj, err := dryRunHelper(info).Create(info.Namespace, true, info.Object)
+		if err != nil {
+			return err
+		}
+		record(info, obj)
+		return nil
+	}, func(info *resource.Info, current runtime.Object) error {
+		kind := info.Mapping.GroupVersionKind.Kind
+		patch, patchType, err := createPatch(context.Background(), info, current)
+		if err != nil {
+			return errors.Wrap(err, "failed to create patch")
+		}
+
+		var obj runtime.Object
+		if patch == nil || string(patch) == "{}" {
+			obj, err = dryRunHelper(info).Get(info.Namespace, info.Name)
+		} else {
+			obj, err = dryRunHelper(info).Patch(info.Namespace, info.Name, patchType, patch, nil)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot patch %q with kind %s", info.Name, kind)
+		}
+		record(info, obj)
+		return nil
+	})
+	return result, err
+}
+
+func dryRunHelper(info *resource.Info) *helper {
+	h := newHelper(context.Background(), info)
+	h.ServerDryRun = true
+	return h
+}
@@ -0,0 +1,114 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestDryRunUpdate(t *testing.T) {
+	listA := newPodList("starfish", "squid")
+	listB := newPodList("starfish", "dolphin")
+	listB.Items[0].Spec.Containers[0].Ports = []v1.ContainerPort{{Name: "https", ContainerPort: 443}}
+
+	// The API server defaults and mutates the objects it receives
+	mutated := func(pod v1.Pod) *v1.Pod {
+		pod.Labels = map[string]string{"mutated": "true"}
+		return &pod
+	}
+
+	var actions []string
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			actions = append(actions, p+":"+m)
+			if m != "GET" && req.URL.Query().Get("dryRun") != metav1.DryRunAll {
+				t.Errorf("expected %s %s to be a dry run, got query %q", m, p, req.URL.RawQuery)
+			}
+			switch {
+			case p == "/namespaces/default/pods/starfish" && m == "GET":
+				return newResponse(200, &listA.Items[0])
+			case p == "/namespaces/default/pods/starfish" && m == "PATCH":
+				return newResponse(200, mutated(listB.Items[0]))
+			case p == "/namespaces/default/pods/dolphin" && m == "GET":
+				return newResponse(404, notFoundBody())
+			case p == "/namespaces/default/pods" && m == "POST":
+				return newResponse(201, mutated(listB.Items[1]))
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.DryRunUpdate(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedActions := []string{
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:PATCH",
+		"/namespaces/default/pods/dolphin:GET",
+		"/namespaces/default/pods:POST",
+	}
+	if strings.Join(actions, " ") != strings.Join(expectedActions, " ") {
+		t.Errorf("expected requests %v, got %v", expectedActions, actions)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(result))
+	}
+	for _, info := range result {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accessor.GetLabels()["mutated"] != "true" {
+			t.Errorf("expected %s to be the object returned by the API server", info.Name)
+		}
+	}
+	// The target resources are left as they were rendered
+	for _, info := range second {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accessor.GetLabels()["mutated"] != "" {
+			t.Errorf("expected %s not to be modified", info.Name)
+		}
+	}
+}
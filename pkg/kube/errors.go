@@ -0,0 +1,62 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UpdateErrors is returned when more than one resource failed to be updated.
+// errors.Is and errors.As look into each of the errors, so that callers can
+// find an *ApplyConflictError among them.
+type UpdateErrors []error
+
+func (e UpdateErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, " && ")
+}
+
+// Unwrap returns the errors.
+func (e UpdateErrors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target.
+func (e UpdateErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, and if so, sets
+// target to it.
+func (e UpdateErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
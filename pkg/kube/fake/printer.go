@@ -86,6 +86,11 @@ func (p *PrintingKubeClient) Update(_, modified kube.ResourceList, _ bool) (*kub
 	return &kube.Result{Updated: modified}, nil
 }
 
+// UpdateServerSide implements KubeClient UpdateServerSide.
+func (p *PrintingKubeClient) UpdateServerSide(original, modified kube.ResourceList, _ bool) (*kube.Result, error) {
+	return p.Update(original, modified, false)
+}
+
 // Build implements KubeClient Build.
 func (p *PrintingKubeClient) Build(_ io.Reader, _ bool) (kube.ResourceList, error) {
 	return []*resource.Info{}, nil
@@ -0,0 +1,151 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// helper is a resource.Helper whose requests are canceled when ctx is done.
+// The requests of resource.Helper cannot be canceled, so the ones Helm uses
+// are sent the same way with ctx.
+type helper struct {
+	*resource.Helper
+	ctx context.Context
+}
+
+// newHelper returns the helper of the resource, with Helm's field manager.
+func newHelper(ctx context.Context, info *resource.Info) *helper {
+	return &helper{
+		Helper: resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()),
+		ctx:    ctx,
+	}
+}
+
+func (m *helper) Get(namespace, name string) (runtime.Object, error) {
+	return m.RESTClient.Get().
+		NamespaceIfScoped(namespace, m.NamespaceScoped).
+		Resource(m.Resource).
+		Name(name).
+		Do(m.ctx).
+		Get()
+}
+
+func (m *helper) DeleteWithOptions(namespace, name string, options *metav1.DeleteOptions) (runtime.Object, error) {
+	if options == nil {
+		options = &metav1.DeleteOptions{}
+	}
+	if m.ServerDryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	return m.RESTClient.Delete().
+		NamespaceIfScoped(namespace, m.NamespaceScoped).
+		Resource(m.Resource).
+		Name(name).
+		Body(options).
+		Do(m.ctx).
+		Get()
+}
+
+// Create creates obj. If modify is true, its resource version is cleared.
+func (m *helper) Create(namespace string, modify bool, obj runtime.Object) (runtime.Object, error) {
+	options := &metav1.CreateOptions{FieldManager: m.FieldManager}
+	if m.ServerDryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	if modify {
+		// Objects whose version cannot be cleared are sent as they are
+		if version, err := metadataAccessor.ResourceVersion(obj); err == nil && version != "" {
+			if err := metadataAccessor.SetResourceVersion(obj, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.RESTClient.Post().
+		NamespaceIfScoped(namespace, m.NamespaceScoped).
+		Resource(m.Resource).
+		VersionedParams(options, metav1.ParameterCodec).
+		Body(obj).
+		Do(m.ctx).
+		Get()
+}
+
+func (m *helper) Patch(namespace, name string, pt types.PatchType, data []byte, options *metav1.PatchOptions) (runtime.Object, error) {
+	if options == nil {
+		options = &metav1.PatchOptions{}
+	}
+	if m.ServerDryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	if m.FieldManager != "" {
+		options.FieldManager = m.FieldManager
+	}
+	return m.RESTClient.Patch(pt).
+		NamespaceIfScoped(namespace, m.NamespaceScoped).
+		Resource(m.Resource).
+		Name(name).
+		VersionedParams(options, metav1.ParameterCodec).
+		Body(data).
+		Do(m.ctx).
+		Get()
+}
+
+// Replace replaces the object with obj. If overwrite is true and obj has no
+// resource version, the version of the object on the server is used.
+func (m *helper) Replace(namespace, name string, overwrite bool, obj runtime.Object) (runtime.Object, error) {
+	options := &metav1.UpdateOptions{FieldManager: m.FieldManager}
+	if m.ServerDryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	if version, err := metadataAccessor.ResourceVersion(obj); err == nil && version == "" && overwrite {
+		// If the object does not exist, it is created by the update
+		if serverObj, err := m.Get(namespace, name); err == nil {
+			serverVersion, err := metadataAccessor.ResourceVersion(serverObj)
+			if err != nil {
+				return nil, err
+			}
+			if err := metadataAccessor.SetResourceVersion(obj, serverVersion); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.RESTClient.Put().
+		NamespaceIfScoped(namespace, m.NamespaceScoped).
+		Resource(m.Resource).
+		Name(name).
+		VersionedParams(options, metav1.ParameterCodec).
+		Body(obj).
+		Do(m.ctx).
+		Get()
+}
+
+// getInfo gets the object of the resource from the server, like
+// resource.Info.Get.
+func getInfo(ctx context.Context, info *resource.Info) error {
+	obj, err := newHelper(ctx, info).Get(info.Namespace, info.Name)
+	if err != nil {
+		return err
+	}
+	info.Object = obj
+	info.ResourceVersion, _ = metadataAccessor.ResourceVersion(obj)
+	return nil
+}
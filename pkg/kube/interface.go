@@ -17,10 +17,12 @@ limitations under the License.
 package kube
 
 import (
+	"context"
 	"io"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Interface represents a client capable of communicating with the Kubernetes API.
@@ -70,4 +72,58 @@ type Interface interface {
 	IsReachable() error
 }
 
+// InterfaceServerSideApply is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceServerSideApply and integrate its method(s) into the Interface.
+type InterfaceServerSideApply interface {
+	// UpdateServerSide updates one or more resources with server-side apply or
+	// creates the resource if it doesn't exist. Fields owned by other field
+	// managers are taken over only if forceConflicts is true.
+	UpdateServerSide(original, target ResourceList, forceConflicts bool) (*Result, error)
+}
+
+// InterfaceContext is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceContext and integrate its method(s) into the Interface.
+type InterfaceContext interface {
+	// CreateWithContext creates the resources like Create, and stops with the
+	// error of ctx when ctx is done.
+	CreateWithContext(ctx context.Context, resources ResourceList) (*Result, error)
+
+	// UpdateWithContext updates the resources like Update, and stops with the
+	// error of ctx when ctx is done.
+	UpdateWithContext(ctx context.Context, original, target ResourceList, force bool) (*Result, error)
+
+	// DeleteWithContext deletes the resources like Delete, and deletes their
+	// dependents according to the propagation policy. The resources that are
+	// not deleted yet when ctx is done fail with the error of ctx.
+	DeleteWithContext(ctx context.Context, resources ResourceList, policy metav1.DeletionPropagation) (*Result, []error)
+
+	// WaitWithContext waits like Wait, or like WaitWithJobs if waitForJobs is
+	// true, and stops with the error of ctx when ctx is done. If progress is
+	// not nil, it receives the readiness of the resources like with
+	// InterfaceWaitProgress.
+	WaitWithContext(ctx context.Context, resources ResourceList, timeout time.Duration, waitForJobs bool, progress ProgressFunc) error
+
+	// WatchUntilReadyWithContext watches the resources like WatchUntilReady,
+	// and stops with the error of ctx when ctx is done.
+	WatchUntilReadyWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error
+}
+
+// InterfaceDeletion is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceDeletion and integrate its method(s) into the Interface.
+type InterfaceDeletion interface {
+	// DeleteWithPropagationPolicy destroys one or more resources like Delete,
+	// and deletes their dependents according to the propagation policy.
+	DeleteWithPropagationPolicy(resources ResourceList, policy metav1.DeletionPropagation) (*Result, []error)
+
+	// WaitForDelete waits up to the given timeout for the specified resources
+	// to be gone, including the ones held by finalizers.
+	WaitForDelete(resources ResourceList, timeout time.Duration) error
+}
+
 var _ Interface = (*Client)(nil)
+var _ InterfaceServerSideApply = (*Client)(nil)
+var _ InterfaceContext = (*Client)(nil)
+var _ InterfaceDeletion = (*Client)(nil)
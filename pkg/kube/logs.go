@@ -0,0 +1,230 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// InterfaceLogs is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceLogs and integrate its method(s) into the Interface.
+type InterfaceLogs interface {
+	// GetLogs returns the logs of the containers of the pods run by the given
+	// Jobs and Pods, and the Kubernetes events of the resources and their
+	// pods. Only the last limitBytes bytes of the log of each container are
+	// returned, and no more than maxLogs logs if maxLogs is positive.
+	//
+	// Logs and events that could be fetched are returned along with the
+	// error, if any.
+	GetLogs(resources ResourceList, limitBytes, maxLogs int) ([]ContainerLog, []ObjectEvent, error)
+}
+
+var _ InterfaceLogs = (*Client)(nil)
+
+// ContainerLog is the log of a container of a pod.
+type ContainerLog struct {
+	Pod       string
+	Container string
+	Log       string
+	// Truncated is true if the beginning of the log was cut off.
+	Truncated bool
+	// Err is set if the log could not be fetched.
+	Err error
+}
+
+// ObjectEvent is a Kubernetes event about an object.
+type ObjectEvent struct {
+	// Object is the kind and name of the object, e.g. "Job/migrate".
+	Object   string
+	Type     string
+	Reason   string
+	Message  string
+	Count    int32
+	LastSeen time.Time
+}
+
+// GetLogs returns the logs of the containers of the pods run by the given
+// Jobs and Pods, and the Kubernetes events of the resources and their pods
+// ordered by when they were last seen.
+func (c *Client) GetLogs(resources ResourceList, limitBytes, maxLogs int) ([]ContainerLog, []ObjectEvent, error) {
+	client, err := c.getKubeClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	return getLogs(client, resources, limitBytes, maxLogs)
+}
+
+func getLogs(client kubernetes.Interface, resources ResourceList, limitBytes, maxLogs int) ([]ContainerLog, []ObjectEvent, error) {
+	var logs []ContainerLog
+	var events []ObjectEvent
+	// Errors do not stop the collection, since the output of the other
+	// containers and pods is the most useful when something went wrong.
+	var errs []string
+	full := func() bool { return maxLogs > 0 && len(logs) >= maxLogs }
+
+	for _, info := range resources {
+		if full() {
+			break
+		}
+		kind := info.Mapping.GroupVersionKind.Kind
+		var pods []v1.Pod
+		switch kind {
+		case "Job":
+			// The job controller labels the pods it creates with the name of the job
+			list, err := client.CoreV1().Pods(info.Namespace).List(context.Background(), metav1.ListOptions{
+				LabelSelector: labels.Set{"job-name": info.Name}.String(),
+			})
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "unable to list the pods of job %s", info.Name).Error())
+			} else {
+				pods = list.Items
+			}
+		case "Pod":
+			pod, err := client.CoreV1().Pods(info.Namespace).Get(context.Background(), info.Name, metav1.GetOptions{})
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "unable to get pod %s", info.Name).Error())
+			} else {
+				pods = []v1.Pod{*pod}
+			}
+		default:
+			continue
+		}
+
+		e, err := objectEvents(client, info.Namespace, kind, info.Name)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		events = append(events, e...)
+
+		// Most recent pods first, the last attempt of a job is usually the interesting one
+		sort.SliceStable(pods, func(i, j int) bool {
+			return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+		})
+		for _, pod := range pods {
+			if full() {
+				break
+			}
+			e, err := objectEvents(client, pod.Namespace, "Pod", pod.Name)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			events = append(events, e...)
+
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				if full() {
+					break
+				}
+				logs = append(logs, containerLog(client, pod, container.Name, limitBytes))
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastSeen.Before(events[j].LastSeen)
+	})
+	if len(errs) > 0 {
+		return logs, events, errors.New(strings.Join(errs, "; "))
+	}
+	return logs, events, nil
+}
+
+// containerLog returns the end of the log of a container. If the log cannot
+// be fetched, for instance because the container has not started, the error
+// is recorded in the returned ContainerLog.
+func containerLog(client kubernetes.Interface, pod v1.Pod, container string, limitBytes int) ContainerLog {
+	l := ContainerLog{Pod: pod.Name, Container: container}
+	// Every line takes at least one byte, so the last limitBytes lines hold
+	// the last limitBytes bytes without streaming the whole log.
+	tailLines := int64(limitBytes)
+	opts := &v1.PodLogOptions{Container: container, TailLines: &tailLines}
+	stream, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(context.Background())
+	if err != nil {
+		l.Err = errors.Wrapf(err, "unable to get the log of container %s of pod %s", container, pod.Name)
+		return l
+	}
+	defer stream.Close()
+
+	w := &tailWriter{limit: limitBytes}
+	if _, err := io.Copy(w, stream); err != nil {
+		l.Err = errors.Wrapf(err, "unable to read the log of container %s of pod %s", container, pod.Name)
+	}
+	l.Log = string(w.buf)
+	l.Truncated = w.truncated
+	return l
+}
+
+func objectEvents(client kubernetes.Interface, namespace, kind, name string) ([]ObjectEvent, error) {
+	list, err := client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the events of %s %s", kind, name)
+	}
+
+	events := make([]ObjectEvent, 0, len(list.Items))
+	for _, e := range list.Items {
+		if e.InvolvedObject.Kind != kind || e.InvolvedObject.Name != name {
+			continue
+		}
+		events = append(events, ObjectEvent{
+			Object:   kind + "/" + name,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastSeen: eventTime(e),
+		})
+	}
+	return events, nil
+}
+
+// eventTime returns when the event was last seen. Events reported through
+// the events.k8s.io API only set EventTime.
+func eventTime(e v1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	return e.EventTime.Time
+}
+
+// tailWriter keeps the last limit bytes written to it.
+type tailWriter struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.limit {
+		w.buf = append(w.buf[:0], w.buf[len(w.buf)-w.limit:]...)
+		w.truncated = true
+	}
+	return len(p), nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestGetLogs(t *testing.T) {
+	now := time.Now()
+	pod := func(name string, created time.Time) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				Labels:            map[string]string{"job-name": "migrate"},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: v1.PodSpec{Containers: []v1.Container{{Name: "migrate"}}},
+		}
+	}
+	event := func(name, kind, object, reason string, seen time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: v1.ObjectReference{Kind: kind, Name: object, Namespace: "default"},
+			Type:           v1.EventTypeWarning,
+			Reason:         reason,
+			LastTimestamp:  metav1.NewTime(seen),
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("migrate-first", now.Add(-time.Minute)),
+		pod("migrate-second", now),
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+		event("e1", "Job", "migrate", "BackoffLimitExceeded", now),
+		event("e2", "Job", "migrate", "SuccessfulCreate", now.Add(-time.Minute)),
+		event("e3", "Pod", "migrate-second", "BackOff", now),
+		event("e4", "Pod", "unrelated", "Pulled", now),
+	)
+
+	job := &resource.Info{
+		Name:      "migrate",
+		Namespace: "default",
+		Mapping: &meta.RESTMapping{
+			GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		},
+	}
+	cm := &resource.Info{
+		Name:      "config",
+		Namespace: "default",
+		Mapping: &meta.RESTMapping{
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		},
+	}
+
+	logs, events, err := getLogs(client, ResourceList{job, cm}, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 2 {
+		t.Fatalf("expected the logs of 2 containers, got %d", len(logs))
+	}
+	if logs[0].Pod != "migrate-second" || logs[1].Pod != "migrate-first" {
+		t.Errorf("expected the most recent pod first, got %s, %s", logs[0].Pod, logs[1].Pod)
+	}
+	// The fake clientset returns "fake logs" for every container
+	if logs[0].Log != "logs" || !logs[0].Truncated {
+		t.Errorf("expected the log to be truncated to its last 4 bytes, got %q (truncated: %t)", logs[0].Log, logs[0].Truncated)
+	}
+
+	var reasons []string
+	for _, e := range events {
+		reasons = append(reasons, e.Object+":"+e.Reason)
+	}
+	expected := []string{"Job/migrate:SuccessfulCreate", "Job/migrate:BackoffLimitExceeded", "Pod/migrate-second:BackOff"}
+	if len(reasons) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, reasons)
+	}
+	for i := range expected {
+		if reasons[i] != expected[i] {
+			t.Errorf("expected events %v, got %v", expected, reasons)
+			break
+		}
+	}
+}
+
+func TestGetLogsContinuesOnErrors(t *testing.T) {
+	pod := func(name string, created time.Time) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				Labels:            map[string]string{"job-name": "migrate"},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: v1.PodSpec{Containers: []v1.Container{{Name: "migrate"}}},
+		}
+	}
+	now := time.Now()
+	client := fake.NewSimpleClientset(
+		pod("migrate-first", now.Add(-2*time.Minute)),
+		pod("migrate-second", now.Add(-time.Minute)),
+		pod("migrate-third", now),
+	)
+	client.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if strings.Contains(action.(k8stesting.ListAction).GetListRestrictions().Fields.String(), "migrate-third") {
+			return true, nil, errors.New("events are unavailable")
+		}
+		return false, nil, nil
+	})
+	job := &resource.Info{
+		Name:      "migrate",
+		Namespace: "default",
+		Mapping: &meta.RESTMapping{
+			GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		},
+	}
+
+	logs, _, err := getLogs(client, ResourceList{job}, 1024, 2)
+	if err == nil || !strings.Contains(err.Error(), "events are unavailable") {
+		t.Errorf("expected the events error to be returned, got %v", err)
+	}
+	// The logs are still collected, up to the limit
+	if len(logs) != 2 {
+		t.Fatalf("expected the logs of 2 containers, got %d", len(logs))
+	}
+	if logs[0].Pod != "migrate-third" || logs[1].Pod != "migrate-second" {
+		t.Errorf("expected the logs of the 2 most recent pods, got %s, %s", logs[0].Pod, logs[1].Pod)
+	}
+}
+
+func TestTailWriter(t *testing.T) {
+	w := &tailWriter{limit: 10}
+	w.Write([]byte("hello "))
+	if w.truncated {
+		t.Error("expected the log not to be truncated")
+	}
+	w.Write([]byte("world, again"))
+	if got := string(w.buf); got != "rld, again" {
+		t.Errorf("expected the last 10 bytes, got %q", got)
+	}
+	if !w.truncated {
+		t.Error("expected the log to be truncated")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"time"
+)
+
+// InterfaceWaitProgress is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceWaitProgress and integrate its method(s) into the Interface.
+type InterfaceWaitProgress interface {
+	// WaitWithProgress waits like Wait, or like WaitWithJobs if waitForJobs
+	// is true, and reports the readiness of the resources to progress every
+	// time they are checked.
+	WaitWithProgress(resources ResourceList, timeout time.Duration, waitForJobs bool, progress ProgressFunc) error
+}
+
+var _ InterfaceWaitProgress = (*Client)(nil)
+
+// ProgressFunc receives the progress of waiting for resources.
+type ProgressFunc func(WaitProgress)
+
+// WaitProgress is the readiness of the resources being waited for.
+type WaitProgress struct {
+	// Resources are the resources being waited for, in the order they were given.
+	Resources []ResourceStatus
+	// Ready is how many of the resources are ready.
+	Ready int
+	// Elapsed is how long the resources have been waited for.
+	Elapsed time.Duration
+	// Timeout is how long the resources are waited for at most.
+	Timeout time.Duration
+}
+
+// Pending returns the resources that are not ready yet.
+func (p WaitProgress) Pending() []ResourceStatus {
+	var pending []ResourceStatus
+	for _, r := range p.Resources {
+		if !r.Ready {
+			pending = append(pending, r)
+		}
+	}
+	return pending
+}
+
+// ResourceStatus is the readiness of a resource being waited for.
+type ResourceStatus struct {
+	// Object is the kind and name of the resource, e.g. "Deployment/api".
+	Object    string
+	Namespace string
+	Ready     bool
+	// Reason is why the resource is not ready, e.g. "2/3 replicas ready".
+	Reason string
+}
+
+// WaitWithProgress waits up to the given timeout for the specified resources
+// to be ready, including jobs if waitForJobs is true, and reports the
+// readiness of the resources to progress every time they are checked.
+func (c *Client) WaitWithProgress(resources ResourceList, timeout time.Duration, waitForJobs bool, progress ProgressFunc) error {
+	return c.WaitWithContext(context.Background(), resources, timeout, waitForJobs, progress)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWaitWithProgress(t *testing.T) {
+	info := func(kind string, obj runtime.Object) *resource.Info {
+		accessor, _ := meta.Accessor(obj)
+		return &resource.Info{
+			Name:      accessor.GetName(),
+			Namespace: defaultNamespace,
+			Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: kind}},
+			Object:    obj,
+		}
+	}
+	bound := newPersistentVolumeClaim("bound", corev1.ClaimBound)
+	pending := newPersistentVolumeClaim("data", corev1.ClaimPending)
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "data.1", Namespace: defaultNamespace},
+		InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data"},
+		Type:           corev1.EventTypeWarning,
+		Message:        "no storage class is set",
+		LastTimestamp:  metav1.Now(),
+	}
+
+	var reports []WaitProgress
+	w := waiter{
+		c:       NewReadyChecker(fake.NewSimpleClientset(bound, pending, event), nil),
+		log:     nopLogger,
+		timeout: 100 * time.Millisecond,
+		progress: func(p WaitProgress) {
+			reports = append(reports, p)
+		},
+	}
+	err := w.waitForResources(context.Background(), ResourceList{
+		info("PersistentVolumeClaim", bound),
+		info("PersistentVolumeClaim", pending),
+	})
+	if err == nil {
+		t.Fatal("expected the wait to time out")
+	}
+	if !strings.Contains(err.Error(), "resources not ready: PersistentVolumeClaim/data (Pending: no storage class is set)") {
+		t.Errorf("expected the error to name the pending resource, got %q", err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("expected the progress to be reported")
+	}
+	p := reports[len(reports)-1]
+	expected := []ResourceStatus{
+		{Object: "PersistentVolumeClaim/bound", Namespace: defaultNamespace, Ready: true},
+		{Object: "PersistentVolumeClaim/data", Namespace: defaultNamespace, Reason: "Pending: no storage class is set"},
+	}
+	if !reflect.DeepEqual(p.Resources, expected) {
+		t.Errorf("expected resources %+v, got %+v", expected, p.Resources)
+	}
+	if p.Ready != 1 || p.Timeout != w.timeout {
+		t.Errorf("expected 1 ready resource and the timeout, got %+v", p)
+	}
+	if pending := p.Pending(); len(pending) != 1 || pending[0].Object != "PersistentVolumeClaim/data" {
+		t.Errorf("expected data to be pending, got %+v", pending)
+	}
+}
+
+func TestWaitWithProgressReady(t *testing.T) {
+	bound := newPersistentVolumeClaim("bound", corev1.ClaimBound)
+	var reports []WaitProgress
+	w := waiter{
+		c:       NewReadyChecker(fake.NewSimpleClientset(bound), nil),
+		log:     nopLogger,
+		timeout: time.Minute,
+		progress: func(p WaitProgress) {
+			reports = append(reports, p)
+		},
+	}
+	err := w.waitForResources(context.Background(), ResourceList{&resource.Info{
+		Name:      "bound",
+		Namespace: defaultNamespace,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}},
+		Object:    bound,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Ready != 1 {
+		t.Errorf("expected one report with the claim ready, got %+v", reports)
+	}
+}
@@ -18,6 +18,7 @@ package kube // import "helm.sh/helm/v3/pkg/kube"
 
 import (
 	"context"
+	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
@@ -28,6 +29,8 @@ import (
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -79,12 +82,16 @@ type ReadyChecker struct {
 	log           func(string, ...interface{})
 	checkJobs     bool
 	pausedAsReady bool
+	// reason receives why the resource being checked is not ready, see check
+	reason *string
 }
 
 // IsReady checks if v is ready. It supports checking readiness for pods,
 // deployments, persistent volume claims, services, daemon sets, custom
 // resource definitions, stateful sets, replication controllers, and replica
-// sets. All other resource kinds are always considered ready.
+// sets. Custom resources are checked using their status conditions, see
+// ReadyConditionAnnotation. All other resource kinds are always considered
+// ready.
 //
 // IsReady will fetch the latest state of the object from the server prior to
 // performing readiness checks, and it will return any error encountered.
@@ -121,6 +128,7 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 		// Find RS associated with deployment
 		newReplicaSet, err := deploymentutil.GetNewReplicaSet(currentDeployment, c.client.AppsV1())
 		if err != nil || newReplicaSet == nil {
+			c.notReady("waiting for the new replica set", "Deployment has no new replica set yet: %s/%s", v.Namespace, v.Name)
 			return false, err
 		}
 		if !c.deploymentReady(newReplicaSet, currentDeployment) {
@@ -132,6 +140,7 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 			return false, err
 		}
 		if !c.volumeReady(claim) {
+			c.explainWithEvents(ctx, "PersistentVolumeClaim", claim.Namespace, claim.Name)
 			return false, nil
 		}
 	case *corev1.Service:
@@ -182,6 +191,16 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 		}
 	case *corev1.ReplicationController, *extensionsv1beta1.ReplicaSet, *appsv1beta2.ReplicaSet, *appsv1.ReplicaSet:
 		ok, err = c.podsReadyForObject(ctx, v.Namespace, value)
+	case *unstructured.Unstructured:
+		// Not a kind Kubernetes ships with, most likely a custom resource
+		if err := v.Get(); err != nil {
+			return false, err
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(v.Object)
+		if err != nil {
+			return false, err
+		}
+		return c.customResourceReady(&unstructured.Unstructured{Object: obj})
 	}
 	if !ok || err != nil {
 		return false, err
@@ -189,6 +208,54 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 	return true, nil
 }
 
+// check checks if v is ready like IsReady, and returns why it is not.
+func (c *ReadyChecker) check(ctx context.Context, v *resource.Info) (bool, string, error) {
+	var reason string
+	checker := *c
+	checker.reason = &reason
+	ready, err := checker.IsReady(ctx, v)
+	if !ready && reason == "" {
+		reason = "not ready"
+	}
+	return ready, reason, err
+}
+
+// notReady logs why a resource is not ready, and records the short reason for
+// check.
+func (c *ReadyChecker) notReady(reason, format string, args ...interface{}) {
+	c.log(format, args...)
+	if c.reason != nil {
+		*c.reason = reason
+	}
+}
+
+// explainWithEvents adds the message of the last warning event of the object
+// to the reason it is not ready. It is only done for check, as it queries the
+// events.
+func (c *ReadyChecker) explainWithEvents(ctx context.Context, kind, namespace, name string) {
+	if c.reason == nil {
+		return
+	}
+	list, err := c.client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		return
+	}
+	var last *corev1.Event
+	for i, e := range list.Items {
+		if e.Type != corev1.EventTypeWarning || e.InvolvedObject.Kind != kind || e.InvolvedObject.Name != name {
+			continue
+		}
+		if last == nil || eventTime(*last).Before(eventTime(e)) {
+			last = &list.Items[i]
+		}
+	}
+	if last != nil {
+		*c.reason += ": " + last.Message
+	}
+}
+
 func (c *ReadyChecker) podsReadyForObject(ctx context.Context, namespace string, obj runtime.Object) (bool, error) {
 	pods, err := c.podsforObject(ctx, namespace, obj)
 	if err != nil {
@@ -218,17 +285,17 @@ func (c *ReadyChecker) isPodReady(pod *corev1.Pod) bool {
 			return true
 		}
 	}
-	c.log("Pod is not ready: %s/%s", pod.GetNamespace(), pod.GetName())
+	c.notReady(fmt.Sprintf("pod %s is not ready", pod.GetName()), "Pod is not ready: %s/%s", pod.GetNamespace(), pod.GetName())
 	return false
 }
 
 func (c *ReadyChecker) jobReady(job *batchv1.Job) bool {
 	if job.Status.Failed > *job.Spec.BackoffLimit {
-		c.log("Job is failed: %s/%s", job.GetNamespace(), job.GetName())
+		c.notReady(fmt.Sprintf("failed %d times", job.Status.Failed), "Job is failed: %s/%s", job.GetNamespace(), job.GetName())
 		return false
 	}
 	if job.Status.Succeeded < *job.Spec.Completions {
-		c.log("Job is not completed: %s/%s", job.GetNamespace(), job.GetName())
+		c.notReady(fmt.Sprintf("%d/%d completions", job.Status.Succeeded, *job.Spec.Completions), "Job is not completed: %s/%s", job.GetNamespace(), job.GetName())
 		return false
 	}
 	return true
@@ -242,7 +309,7 @@ func (c *ReadyChecker) serviceReady(s *corev1.Service) bool {
 
 	// Ensure that the service cluster IP is not empty
 	if s.Spec.ClusterIP == "" {
-		c.log("Service does not have cluster IP address: %s/%s", s.GetNamespace(), s.GetName())
+		c.notReady("no cluster IP address", "Service does not have cluster IP address: %s/%s", s.GetNamespace(), s.GetName())
 		return false
 	}
 
@@ -255,7 +322,7 @@ func (c *ReadyChecker) serviceReady(s *corev1.Service) bool {
 		}
 
 		if s.Status.LoadBalancer.Ingress == nil {
-			c.log("Service does not have load balancer ingress IP address: %s/%s", s.GetNamespace(), s.GetName())
+			c.notReady("no load balancer ingress IP address", "Service does not have load balancer ingress IP address: %s/%s", s.GetNamespace(), s.GetName())
 			return false
 		}
 	}
@@ -265,7 +332,7 @@ func (c *ReadyChecker) serviceReady(s *corev1.Service) bool {
 
 func (c *ReadyChecker) volumeReady(v *corev1.PersistentVolumeClaim) bool {
 	if v.Status.Phase != corev1.ClaimBound {
-		c.log("PersistentVolumeClaim is not bound: %s/%s", v.GetNamespace(), v.GetName())
+		c.notReady(string(v.Status.Phase), "PersistentVolumeClaim is not bound: %s/%s", v.GetNamespace(), v.GetName())
 		return false
 	}
 	return true
@@ -274,7 +341,7 @@ func (c *ReadyChecker) volumeReady(v *corev1.PersistentVolumeClaim) bool {
 func (c *ReadyChecker) deploymentReady(rs *appsv1.ReplicaSet, dep *appsv1.Deployment) bool {
 	expectedReady := *dep.Spec.Replicas - deploymentutil.MaxUnavailable(*dep)
 	if !(rs.Status.ReadyReplicas >= expectedReady) {
-		c.log("Deployment is not ready: %s/%s. %d out of %d expected pods are ready", dep.Namespace, dep.Name, rs.Status.ReadyReplicas, expectedReady)
+		c.notReady(fmt.Sprintf("%d/%d replicas ready", rs.Status.ReadyReplicas, expectedReady), "Deployment is not ready: %s/%s. %d out of %d expected pods are ready", dep.Namespace, dep.Name, rs.Status.ReadyReplicas, expectedReady)
 		return false
 	}
 	return true
@@ -288,7 +355,7 @@ func (c *ReadyChecker) daemonSetReady(ds *appsv1.DaemonSet) bool {
 
 	// Make sure all the updated pods have been scheduled
 	if ds.Status.UpdatedNumberScheduled != ds.Status.DesiredNumberScheduled {
-		c.log("DaemonSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", ds.Namespace, ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+		c.notReady(fmt.Sprintf("%d/%d pods scheduled", ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled), "DaemonSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", ds.Namespace, ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
 		return false
 	}
 	maxUnavailable, err := intstr.GetValueFromIntOrPercent(ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable, int(ds.Status.DesiredNumberScheduled), true)
@@ -301,7 +368,7 @@ func (c *ReadyChecker) daemonSetReady(ds *appsv1.DaemonSet) bool {
 
 	expectedReady := int(ds.Status.DesiredNumberScheduled) - maxUnavailable
 	if !(int(ds.Status.NumberReady) >= expectedReady) {
-		c.log("DaemonSet is not ready: %s/%s. %d out of %d expected pods are ready", ds.Namespace, ds.Name, ds.Status.NumberReady, expectedReady)
+		c.notReady(fmt.Sprintf("%d/%d pods ready", ds.Status.NumberReady, expectedReady), "DaemonSet is not ready: %s/%s. %d out of %d expected pods are ready", ds.Namespace, ds.Name, ds.Status.NumberReady, expectedReady)
 		return false
 	}
 	return true
@@ -327,6 +394,7 @@ func (c *ReadyChecker) crdBetaReady(crd apiextv1beta1.CustomResourceDefinition)
 			}
 		}
 	}
+	c.notReady("not established", "CustomResourceDefinition is not established: %s", crd.GetName())
 	return false
 }
 
@@ -347,6 +415,7 @@ func (c *ReadyChecker) crdReady(crd apiextv1.CustomResourceDefinition) bool {
 			}
 		}
 	}
+	c.notReady("not established", "CustomResourceDefinition is not established: %s", crd.GetName())
 	return false
 }
 
@@ -378,12 +447,12 @@ func (c *ReadyChecker) statefulSetReady(sts *appsv1.StatefulSet) bool {
 
 	// Make sure all the updated pods have been scheduled
 	if int(sts.Status.UpdatedReplicas) != expectedReplicas {
-		c.log("StatefulSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", sts.Namespace, sts.Name, sts.Status.UpdatedReplicas, expectedReplicas)
+		c.notReady(fmt.Sprintf("%d/%d replicas updated", sts.Status.UpdatedReplicas, expectedReplicas), "StatefulSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", sts.Namespace, sts.Name, sts.Status.UpdatedReplicas, expectedReplicas)
 		return false
 	}
 
 	if int(sts.Status.ReadyReplicas) != replicas {
-		c.log("StatefulSet is not ready: %s/%s. %d out of %d expected pods are ready", sts.Namespace, sts.Name, sts.Status.ReadyReplicas, replicas)
+		c.notReady(fmt.Sprintf("%d/%d replicas ready", sts.Status.ReadyReplicas, replicas), "StatefulSet is not ready: %s/%s. %d out of %d expected pods are ready", sts.Namespace, sts.Name, sts.Status.ReadyReplicas, replicas)
 		return false
 	}
 	return true
@@ -0,0 +1,183 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ReadyConditionAnnotation is the annotation name for the status conditions
+// a custom resource has to meet to be ready.
+//
+// The value is a comma separated list of "Type=Status" or "Type!=Status"
+// requirements, all of which have to be met, e.g. "Ready=True,Degraded!=True".
+// A requirement without a status, e.g. "Synced", is the same as "Synced=True".
+const ReadyConditionAnnotation = "helm.sh/ready-condition"
+
+// statusCondition is a condition of the status of a resource, following the
+// conventions of metav1.Condition.
+type statusCondition struct {
+	Type               string
+	Status             string
+	Reason             string
+	Message            string
+	ObservedGeneration int64
+}
+
+// conditionRequirement is a requirement of a ReadyConditionAnnotation.
+type conditionRequirement struct {
+	Type   string
+	Status string
+	Negate bool
+}
+
+func (r conditionRequirement) String() string {
+	if r.Negate {
+		return r.Type + "!=" + r.Status
+	}
+	return r.Type + "=" + r.Status
+}
+
+// parseReadyCondition parses the value of a ReadyConditionAnnotation.
+func parseReadyCondition(value string) ([]conditionRequirement, error) {
+	var reqs []conditionRequirement
+	for _, term := range strings.Split(value, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req := conditionRequirement{Type: term, Status: "True"}
+		if i := strings.Index(term, "!="); i >= 0 {
+			req = conditionRequirement{Type: term[:i], Status: term[i+2:], Negate: true}
+		} else if i := strings.Index(term, "="); i >= 0 {
+			req = conditionRequirement{Type: term[:i], Status: term[i+1:]}
+		}
+		req.Type = strings.TrimSpace(req.Type)
+		req.Status = strings.TrimSpace(req.Status)
+		if req.Type == "" || req.Status == "" {
+			return nil, errors.Errorf("invalid requirement %q", term)
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("no requirements")
+	}
+	return reqs, nil
+}
+
+// customResourceReady checks the readiness of a resource Helm does not know,
+// using the conventions of its status.
+//
+// The resource is not ready until its status.observedGeneration, if any,
+// catches up with its generation. If the resource has a
+// ReadyConditionAnnotation, its conditions have to meet it. Otherwise the
+// resource is not ready while it is Stalled or Reconciling, and is ready
+// once its Ready condition, or else its Available condition, is True. A
+// resource with none of these conditions is ready.
+func (c *ReadyChecker) customResourceReady(u *unstructured.Unstructured) (bool, error) {
+	kind := u.GetKind()
+	generation := u.GetGeneration()
+	if observed, found, _ := unstructured.NestedInt64(u.Object, "status", "observedGeneration"); found && observed < generation {
+		c.notReady(fmt.Sprintf("generation %d not observed yet", generation), "%s has not observed generation %d yet: %s/%s", kind, generation, u.GetNamespace(), u.GetName())
+		return false, nil
+	}
+
+	conditions := statusConditions(u)
+	// A condition set for an older generation is not known for this one
+	status := func(conditionType string) (statusCondition, bool) {
+		for _, cond := range conditions {
+			if cond.Type != conditionType {
+				continue
+			}
+			if cond.ObservedGeneration > 0 && cond.ObservedGeneration < generation {
+				cond.Status = "Unknown"
+			}
+			return cond, true
+		}
+		return statusCondition{}, false
+	}
+
+	if value, ok := u.GetAnnotations()[ReadyConditionAnnotation]; ok {
+		reqs, err := parseReadyCondition(value)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid %s annotation on %s %s/%s", ReadyConditionAnnotation, kind, u.GetNamespace(), u.GetName())
+		}
+		for _, req := range reqs {
+			cond, _ := status(req.Type)
+			if strings.EqualFold(cond.Status, req.Status) == req.Negate {
+				c.notReady(conditionReason(req.String(), cond), "%s does not meet %s (%s: %s): %s/%s", kind, req, cond.Reason, cond.Message, u.GetNamespace(), u.GetName())
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	for _, conditionType := range []string{"Stalled", "Reconciling"} {
+		if cond, ok := status(conditionType); ok && cond.Status == "True" {
+			c.notReady(conditionReason(conditionType, cond), "%s is %s (%s: %s): %s/%s", kind, strings.ToLower(conditionType), cond.Reason, cond.Message, u.GetNamespace(), u.GetName())
+			return false, nil
+		}
+	}
+	for _, conditionType := range []string{"Ready", "Available"} {
+		if cond, ok := status(conditionType); ok {
+			if cond.Status != "True" {
+				c.notReady(conditionReason(conditionType+"="+cond.Status, cond), "%s is not %s (%s: %s): %s/%s", kind, strings.ToLower(conditionType), cond.Reason, cond.Message, u.GetNamespace(), u.GetName())
+				return false, nil
+			}
+			return true, nil
+		}
+	}
+	return true, nil
+}
+
+// conditionReason returns why a resource is not ready because of a condition.
+func conditionReason(summary string, cond statusCondition) string {
+	switch {
+	case cond.Message != "":
+		return summary + ": " + cond.Message
+	case cond.Reason != "":
+		return summary + ": " + cond.Reason
+	}
+	return summary
+}
+
+// statusConditions returns the status.conditions of the resource. Malformed
+// conditions are ignored.
+func statusConditions(u *unstructured.Unstructured) []statusCondition {
+	list, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	var conditions []statusCondition
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var cond statusCondition
+		cond.Type, _, _ = unstructured.NestedString(m, "type")
+		cond.Status, _, _ = unstructured.NestedString(m, "status")
+		cond.Reason, _, _ = unstructured.NestedString(m, "reason")
+		cond.Message, _, _ = unstructured.NestedString(m, "message")
+		cond.ObservedGeneration, _, _ = unstructured.NestedInt64(m, "observedGeneration")
+		if cond.Type != "" {
+			conditions = append(conditions, cond)
+		}
+	}
+	return conditions
+}
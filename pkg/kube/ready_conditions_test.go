@@ -0,0 +1,215 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest/fake"
+)
+
+func newCustomResource(generation int64, observedGeneration interface{}, annotations map[string]string, conditions ...map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]interface{}{
+			"name":       "foo",
+			"namespace":  defaultNamespace,
+			"generation": generation,
+		},
+		"status": map[string]interface{}{},
+	}}
+	if annotations != nil {
+		u.SetAnnotations(annotations)
+	}
+	if observedGeneration != nil {
+		u.Object["status"].(map[string]interface{})["observedGeneration"] = observedGeneration
+	}
+	if len(conditions) > 0 {
+		var list []interface{}
+		for _, c := range conditions {
+			list = append(list, c)
+		}
+		u.Object["status"].(map[string]interface{})["conditions"] = list
+	}
+	return u
+}
+
+func condition(conditionType, status string) map[string]interface{} {
+	return map[string]interface{}{"type": conditionType, "status": status, "reason": "Testing", "message": "for testing"}
+}
+
+func Test_ReadyChecker_customResourceReady(t *testing.T) {
+	staleReady := condition("Ready", "True")
+	staleReady["observedGeneration"] = int64(1)
+
+	tests := []struct {
+		name    string
+		obj     *unstructured.Unstructured
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "no status is ready",
+			obj:  newCustomResource(1, nil, nil),
+			want: true,
+		},
+		{
+			name: "ready condition is true",
+			obj:  newCustomResource(1, int64(1), nil, condition("Ready", "True")),
+			want: true,
+		},
+		{
+			name: "ready condition is false",
+			obj:  newCustomResource(1, int64(1), nil, condition("Ready", "False")),
+			want: false,
+		},
+		{
+			name: "generation is not observed yet",
+			obj:  newCustomResource(2, int64(1), nil, condition("Ready", "True")),
+			want: false,
+		},
+		{
+			name: "ready condition of an older generation",
+			obj:  newCustomResource(2, nil, nil, staleReady),
+			want: false,
+		},
+		{
+			name: "available condition is true",
+			obj:  newCustomResource(1, nil, nil, condition("Available", "True")),
+			want: true,
+		},
+		{
+			name: "available condition is false",
+			obj:  newCustomResource(1, nil, nil, condition("Available", "False")),
+			want: false,
+		},
+		{
+			name: "ready condition takes precedence over available",
+			obj:  newCustomResource(1, nil, nil, condition("Available", "True"), condition("Ready", "False")),
+			want: false,
+		},
+		{
+			name: "stalled",
+			obj:  newCustomResource(1, nil, nil, condition("Ready", "True"), condition("Stalled", "True")),
+			want: false,
+		},
+		{
+			name: "reconciling",
+			obj:  newCustomResource(1, nil, nil, condition("Reconciling", "True")),
+			want: false,
+		},
+		{
+			name: "unrelated conditions are ignored",
+			obj:  newCustomResource(1, nil, nil, condition("Synced", "False")),
+			want: true,
+		},
+		{
+			name: "annotation is met",
+			obj: newCustomResource(1, nil, map[string]string{ReadyConditionAnnotation: "Synced, Degraded!=True"},
+				condition("Synced", "True"), condition("Ready", "False")),
+			want: true,
+		},
+		{
+			name: "annotation is not met",
+			obj: newCustomResource(1, nil, map[string]string{ReadyConditionAnnotation: "Synced=True,Degraded!=True"},
+				condition("Synced", "True"), condition("Degraded", "True")),
+			want: false,
+		},
+		{
+			name: "annotation condition is missing",
+			obj:  newCustomResource(1, nil, map[string]string{ReadyConditionAnnotation: "Issued=true"}),
+			want: false,
+		},
+		{
+			name:    "annotation is invalid",
+			obj:     newCustomResource(1, nil, map[string]string{ReadyConditionAnnotation: "=True"}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewReadyChecker(kubefake.NewSimpleClientset(), nil)
+			got, err := c.customResourceReady(tt.obj)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("customResourceReady() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("customResourceReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ReadyChecker_IsReady_customResource(t *testing.T) {
+	live := newCustomResource(1, int64(1), nil, condition("Ready", "False"))
+	client := &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/namespaces/default/certificates/foo" {
+				t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+			}
+			data, err := json.Marshal(live.Object)
+			if err != nil {
+				return nil, err
+			}
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(data))}, nil
+		}),
+	}
+	info := &resource.Info{
+		Client:    client,
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"},
+			GroupVersionKind: schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"},
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Object: newCustomResource(1, nil, nil),
+	}
+
+	c := NewReadyChecker(kubefake.NewSimpleClientset(), nil)
+	ready, err := c.IsReady(context.Background(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ready {
+		t.Error("expected the custom resource not to be ready")
+	}
+
+	live = newCustomResource(1, int64(1), nil, condition("Ready", "True"))
+	ready, err = c.IsReady(context.Background(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ready {
+		t.Error("expected the custom resource to be ready")
+	}
+}
@@ -19,6 +19,7 @@ package kube // import "helm.sh/helm/v3/pkg/kube"
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -28,9 +29,12 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
 
 	"k8s.io/apimachinery/pkg/util/wait"
 )
@@ -39,25 +43,138 @@ type waiter struct {
 	c       ReadyChecker
 	timeout time.Duration
 	log     func(string, ...interface{})
+	// progress, if set, receives the readiness of all the resources at every poll
+	progress ProgressFunc
 }
 
 // waitForResources polls to get the current status of all pods, PVCs, Services and
 // Jobs(optional) until all are ready or a timeout is reached
-func (w *waiter) waitForResources(created ResourceList) error {
+//
+// Waiting stops with the error of parent when parent is done.
+func (w *waiter) waitForResources(parent context.Context, created ResourceList) error {
 	w.log("beginning wait for %d resources with timeout of %v", len(created), w.timeout)
 
+	ctx, cancel := context.WithTimeout(parent, w.timeout)
+	defer cancel()
+
+	var err error
+	if w.progress != nil {
+		err = w.waitWithProgress(ctx, created)
+	} else {
+		err = wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+			for _, v := range created {
+				ready, err := w.c.IsReady(ctx, v)
+				if !ready || err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		}, ctx.Done())
+	}
+	if err != nil && parent.Err() != nil {
+		return parent.Err()
+	}
+	return err
+}
+
+// waitForDeletedResources polls until all the deleted resources are gone,
+// including the ones kept by finalizers or by the foreground deletion of their
+// dependents, or until the timeout is reached. On timeout the error names the
+// resources that are still there.
+func (w *waiter) waitForDeletedResources(deleted ResourceList) error {
+	w.log("beginning wait for %d resources to be deleted with timeout of %v", len(deleted), w.timeout)
+
 	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
 	defer cancel()
 
-	return wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
-		for _, v := range created {
-			ready, err := w.c.IsReady(ctx, v)
-			if !ready || err != nil {
+	var remaining []string
+	err := wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		remaining = remaining[:0]
+		for _, v := range deleted {
+			gone, err := isDeleted(v)
+			if err != nil {
 				return false, err
 			}
+			if !gone {
+				remaining = append(remaining, v.Mapping.GroupVersionKind.Kind+"/"+v.Name)
+			}
 		}
+		return len(remaining) == 0, nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout && len(remaining) > 0 {
+		return errors.Wrapf(err, "resources not deleted: %s", strings.Join(remaining, ", "))
+	}
+	return err
+}
+
+// isDeleted returns whether the resource is gone. An object with the same name
+// but another UID has been created again since, so the resource counts as
+// deleted.
+func isDeleted(info *resource.Info) (bool, error) {
+	obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if apierrors.IsNotFound(err) {
 		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get %s %s", info.Mapping.GroupVersionKind.Kind, info.Name)
+	}
+	if info.Object == nil {
+		return false, nil
+	}
+	deleted, err := meta.Accessor(info.Object)
+	if err != nil {
+		return false, nil
+	}
+	live, err := meta.Accessor(obj)
+	if err != nil {
+		return false, nil
+	}
+	return deleted.GetUID() != "" && live.GetUID() != deleted.GetUID(), nil
+}
+
+// waitWithProgress polls like waitForResources, but checks all the resources
+// at every poll to report them to w.progress. On timeout the error names the
+// resources that are not ready.
+func (w *waiter) waitWithProgress(ctx context.Context, created ResourceList) error {
+	start := time.Now()
+	var last WaitProgress
+	err := wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		p := WaitProgress{
+			Resources: make([]ResourceStatus, 0, len(created)),
+			Elapsed:   time.Since(start),
+			Timeout:   w.timeout,
+		}
+		for _, v := range created {
+			ready, reason, err := w.c.check(ctx, v)
+			if err != nil {
+				return false, err
+			}
+			status := ResourceStatus{
+				Object:    v.Mapping.GroupVersionKind.Kind + "/" + v.Name,
+				Namespace: v.Namespace,
+				Ready:     ready,
+			}
+			if ready {
+				p.Ready++
+			} else {
+				status.Reason = reason
+			}
+			p.Resources = append(p.Resources, status)
+		}
+		last = p
+		w.progress(p)
+		return p.Ready == len(created), nil
 	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		var pending []string
+		for _, r := range last.Pending() {
+			pending = append(pending, fmt.Sprintf("%s (%s)", r.Object, r.Reason))
+		}
+		if len(pending) > 0 {
+			return errors.Wrapf(err, "resources not ready: %s", strings.Join(pending, ", "))
+		}
+	}
+	return err
 }
 
 // SelectorsForObject returns the pod label selector for a given object
@@ -0,0 +1,118 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	restfake "k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestWaitCanceled(t *testing.T) {
+	pending := newPersistentVolumeClaim("data", corev1.ClaimPending)
+	w := waiter{
+		c:       NewReadyChecker(fake.NewSimpleClientset(pending), nil),
+		log:     nopLogger,
+		timeout: time.Minute,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := w.waitForResources(ctx, ResourceList{&resource.Info{
+		Name:      "data",
+		Namespace: defaultNamespace,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"}},
+		Object:    pending,
+	}})
+	if err != context.Canceled {
+		t.Errorf("expected the wait to be canceled, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("expected the wait to stop promptly")
+	}
+}
+
+// newDeletingTestClient returns a client whose pods are gone after the given
+// number of GETs. Until then they are returned with the given UID.
+func newDeletingTestClient(t *testing.T, gets int, uid types.UID) *Client {
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &restfake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "GET" {
+				t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+			}
+			if gets == 0 {
+				return newResponse(404, notFoundBody())
+			}
+			gets--
+			pod := newPod(strings.TrimPrefix(req.URL.Path, "/namespaces/default/pods/"))
+			pod.UID = uid
+			return newResponse(200, &pod)
+		}),
+	}
+	return c
+}
+
+func TestWaitForDelete(t *testing.T) {
+	pods := newPodList("starfish", "otter")
+	for i := range pods.Items {
+		pods.Items[i].UID = "deleted"
+	}
+
+	tests := []struct {
+		name    string
+		gets    int
+		uid     types.UID
+		timeout time.Duration
+		wantErr string
+	}{
+		{name: "already gone", timeout: time.Minute},
+		{name: "gone after finalizers ran", gets: 1, uid: "deleted", timeout: time.Minute},
+		{name: "created again", gets: 2, uid: "recreated", timeout: time.Minute},
+		{name: "still there", gets: 100, uid: "deleted", timeout: 100 * time.Millisecond, wantErr: "resources not deleted: Pod/starfish, Pod/otter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newDeletingTestClient(t, tt.gets, tt.uid)
+			resources, err := c.Build(objBody(&pods), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.WaitForDelete(resources, tt.timeout)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
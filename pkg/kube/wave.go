@@ -0,0 +1,76 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// WaveAnnotation is the annotation name for the wave a resource is applied in.
+//
+// Resources are applied one wave at a time in ascending order, and each wave
+// has to become ready before the next one is applied. Resources without the
+// annotation are in wave 0.
+const WaveAnnotation = "helm.sh/wave"
+
+// Waves splits the resources by their wave, in ascending order of the waves.
+// The order of the resources within a wave is kept.
+func (r ResourceList) Waves() ([]ResourceList, error) {
+	byWave := map[int]ResourceList{}
+	var waves []int
+	for _, info := range r {
+		wave, err := resourceWave(info)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := byWave[wave]; !ok {
+			waves = append(waves, wave)
+		}
+		byWave[wave] = append(byWave[wave], info)
+	}
+
+	sort.Ints(waves)
+	result := make([]ResourceList, 0, len(waves))
+	for _, wave := range waves {
+		result = append(result, byWave[wave])
+	}
+	return result, nil
+}
+
+func resourceWave(info *resource.Info) (int, error) {
+	if info.Object == nil {
+		return 0, nil
+	}
+	annotations, err := metadataAccessor.Annotations(info.Object)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to read the annotations of %q", info.Name)
+	}
+	value, ok := annotations[WaveAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, errors.Errorf("invalid %s annotation %q on %q: must be an integer", WaveAnnotation, value, info.Name)
+	}
+	return wave, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestResourceListWaves(t *testing.T) {
+	info := func(name, wave string) *resource.Info {
+		obj := &unstructured.Unstructured{}
+		obj.SetName(name)
+		if wave != "" {
+			obj.SetAnnotations(map[string]string{WaveAnnotation: wave})
+		}
+		return &resource.Info{Name: name, Object: obj}
+	}
+
+	r := ResourceList{
+		info("app", "2"),
+		info("config", ""),
+		info("crd", "-1"),
+		info("secret", "0"),
+		info("worker", " 2 "),
+		info("plain", ""),
+	}
+	r = append(r, &resource.Info{Name: "no-object"})
+
+	waves, err := r.Waves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names [][]string
+	for _, wave := range waves {
+		var n []string
+		for _, info := range wave {
+			n = append(n, info.Name)
+		}
+		names = append(names, n)
+	}
+	expected := [][]string{{"crd"}, {"config", "secret", "plain", "no-object"}, {"app", "worker"}}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected waves %v, got %v", expected, names)
+	}
+
+	if _, err := (ResourceList{info("app", "first")}).Waves(); err == nil {
+		t.Error("expected an error for a wave that is not an integer")
+	}
+
+	waves, err = ResourceList{}.Waves()
+	if err != nil || len(waves) != 0 {
+		t.Errorf("expected no waves for an empty list, got %v, %v", waves, err)
+	}
+}
@@ -25,15 +25,18 @@ type HookEvent string
 
 // Hook event types
 const (
-	HookPreInstall   HookEvent = "pre-install"
-	HookPostInstall  HookEvent = "post-install"
-	HookPreDelete    HookEvent = "pre-delete"
-	HookPostDelete   HookEvent = "post-delete"
-	HookPreUpgrade   HookEvent = "pre-upgrade"
-	HookPostUpgrade  HookEvent = "post-upgrade"
-	HookPreRollback  HookEvent = "pre-rollback"
-	HookPostRollback HookEvent = "post-rollback"
-	HookTest         HookEvent = "test"
+	HookPreInstall          HookEvent = "pre-install"
+	HookPostInstall         HookEvent = "post-install"
+	HookPreDelete           HookEvent = "pre-delete"
+	HookPostDelete          HookEvent = "post-delete"
+	HookPreUpgrade          HookEvent = "pre-upgrade"
+	HookPostUpgrade         HookEvent = "post-upgrade"
+	HookPreRollback         HookEvent = "pre-rollback"
+	HookPostRollback        HookEvent = "post-rollback"
+	HookTest                HookEvent = "test"
+	HookPostInstallFailure  HookEvent = "post-install-failure"
+	HookPostUpgradeFailure  HookEvent = "post-upgrade-failure"
+	HookPostRollbackFailure HookEvent = "post-rollback-failure"
 )
 
 func (x HookEvent) String() string { return string(x) }
@@ -59,6 +62,12 @@ const HookWeightAnnotation = "helm.sh/hook-weight"
 // HookDeleteAnnotation is the label name for the delete policy for a hook
 const HookDeleteAnnotation = "helm.sh/hook-delete-policy"
 
+// HookTimeoutAnnotation is the label name for the time to wait for a hook
+const HookTimeoutAnnotation = "helm.sh/hook-timeout"
+
+// HookRetriesAnnotation is the label name for the number of times a failed hook is retried
+const HookRetriesAnnotation = "helm.sh/hook-retries"
+
 // Hook defines a hook object.
 type Hook struct {
 	Name string `json:"name,omitempty"`
@@ -76,6 +85,11 @@ type Hook struct {
 	Weight int `json:"weight,omitempty"`
 	// DeletePolicies are the policies that indicate when to delete the hook
 	DeletePolicies []HookDeletePolicy `json:"delete_policies,omitempty"`
+	// Timeout is how long to wait for each attempt of the hook. If it is not
+	// set, the timeout of the operation is used.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Retries is how many times the hook is run again after it failed
+	Retries int `json:"retries,omitempty"`
 }
 
 // A HookExecution records the result for the last execution of a hook for a given release.
@@ -86,6 +100,32 @@ type HookExecution struct {
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 	// Phase indicates whether the hook completed successfully
 	Phase HookPhase `json:"phase"`
+	// Logs are the logs of the containers run by the hook, captured when it completed
+	Logs []HookContainerLog `json:"logs,omitempty"`
+	// Events are the Kubernetes events of the hook resources and their pods
+	Events []HookObjectEvent `json:"events,omitempty"`
+}
+
+// HookContainerLog is the log of a container run by a hook.
+type HookContainerLog struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Log       string `json:"log"`
+	// Truncated indicates that only the end of the log was kept
+	Truncated bool `json:"truncated,omitempty"`
+	// Error is why the log could not be captured, if it could not
+	Error string `json:"error,omitempty"`
+}
+
+// HookObjectEvent is a Kubernetes event of a hook resource or one of its pods.
+type HookObjectEvent struct {
+	// Object is the kind and name of the object, e.g. "Job/migrate"
+	Object   string    `json:"object"`
+	Type     string    `json:"type"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count,omitempty"`
+	LastSeen time.Time `json:"last_seen,omitempty"`
 }
 
 // A HookPhase indicates the state of a hook execution
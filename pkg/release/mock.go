@@ -46,6 +46,7 @@ type MockReleaseOptions struct {
 	Chart     *chart.Chart
 	Status    Status
 	Namespace string
+	Labels    map[string]string
 }
 
 // Mock creates a mock release object based on options set by MockReleaseOptions. This function should typically not be used outside of testing.
@@ -112,5 +113,6 @@ func Mock(opts *MockReleaseOptions) *Release {
 			},
 		},
 		Manifest: MockManifest,
+		Labels:   opts.Labels,
 	}
 }
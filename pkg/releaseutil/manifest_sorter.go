@@ -22,12 +22,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/yaml"
 
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
 // Manifest represents a manifest file, which has a name and some content.
@@ -53,15 +55,18 @@ type result struct {
 // TODO: Refactor this out. It's here because naming conventions were not followed through.
 // So fix the Test hook names and then remove this.
 var events = map[string]release.HookEvent{
-	release.HookPreInstall.String():   release.HookPreInstall,
-	release.HookPostInstall.String():  release.HookPostInstall,
-	release.HookPreDelete.String():    release.HookPreDelete,
-	release.HookPostDelete.String():   release.HookPostDelete,
-	release.HookPreUpgrade.String():   release.HookPreUpgrade,
-	release.HookPostUpgrade.String():  release.HookPostUpgrade,
-	release.HookPreRollback.String():  release.HookPreRollback,
-	release.HookPostRollback.String(): release.HookPostRollback,
-	release.HookTest.String():         release.HookTest,
+	release.HookPreInstall.String():          release.HookPreInstall,
+	release.HookPostInstall.String():         release.HookPostInstall,
+	release.HookPreDelete.String():           release.HookPreDelete,
+	release.HookPostDelete.String():          release.HookPostDelete,
+	release.HookPreUpgrade.String():          release.HookPreUpgrade,
+	release.HookPostUpgrade.String():         release.HookPostUpgrade,
+	release.HookPreRollback.String():         release.HookPreRollback,
+	release.HookPostRollback.String():        release.HookPostRollback,
+	release.HookTest.String():                release.HookTest,
+	release.HookPostInstallFailure.String():  release.HookPostInstallFailure,
+	release.HookPostUpgradeFailure.String():  release.HookPostUpgradeFailure,
+	release.HookPostRollbackFailure.String(): release.HookPostRollbackFailure,
 	// Support test-success for backward compatibility with Helm 2 tests
 	"test-success": release.HookTest,
 }
@@ -195,6 +200,9 @@ func (file *manifestFile) sort(result *result) error {
 
 		result.hooks = append(result.hooks, h)
 
+		h.Timeout = helmtime.Duration(calculateHookTimeout(entry))
+		h.Retries = calculateHookRetries(entry)
+
 		operateAnnotationValues(entry, release.HookDeleteAnnotation, func(value string) {
 			h.DeletePolicies = append(h.DeletePolicies, release.HookDeletePolicy(value))
 		})
@@ -222,6 +230,48 @@ func calculateHookWeight(entry SimpleHead) int {
 	return hw
 }
 
+// calculateHookTimeout finds the timeout in the hook timeout annotation. The
+// timeout is a duration such as "40m", or a number of seconds.
+//
+// If no valid timeout is found, the timeout is 0 and the timeout of the
+// operation is used.
+func calculateHookTimeout(entry SimpleHead) time.Duration {
+	hts, ok := entry.Metadata.Annotations[release.HookTimeoutAnnotation]
+	if !ok {
+		return 0
+	}
+	ht, err := time.ParseDuration(hts)
+	if err != nil {
+		seconds, serr := strconv.Atoi(hts)
+		if serr != nil {
+			log.Printf("warning: ignoring invalid %s annotation %q of hook %s: %s", release.HookTimeoutAnnotation, hts, entry.Metadata.Name, err)
+			return 0
+		}
+		ht = time.Duration(seconds) * time.Second
+	}
+	if ht < 0 {
+		log.Printf("warning: ignoring negative %s annotation %q of hook %s", release.HookTimeoutAnnotation, hts, entry.Metadata.Name)
+		return 0
+	}
+	return ht
+}
+
+// calculateHookRetries finds the number of retries in the hook retries annotation.
+//
+// If no valid number is found, the hook is not retried
+func calculateHookRetries(entry SimpleHead) int {
+	hrs, ok := entry.Metadata.Annotations[release.HookRetriesAnnotation]
+	if !ok {
+		return 0
+	}
+	hr, err := strconv.Atoi(hrs)
+	if err != nil || hr < 0 {
+		log.Printf("warning: ignoring invalid %s annotation %q of hook %s", release.HookRetriesAnnotation, hrs, entry.Metadata.Name)
+		return 0
+	}
+	return hr
+}
+
 // operateAnnotationValues finds the given annotation and runs the operate function with the value of that annotation
 func operateAnnotationValues(entry SimpleHead, annotation string, operate func(p string)) {
 	if dps, ok := entry.Metadata.Annotations[annotation]; ok {
@@ -19,6 +19,7 @@ package releaseutil
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/yaml"
 
@@ -130,6 +131,19 @@ metadata:
   name: example-test
   annotations:
     "helm.sh/hook": test
+`,
+		},
+		{
+			name:  []string{"ninth"},
+			path:  "nine",
+			kind:  []string{"Job"},
+			hooks: map[string][]release.HookEvent{"ninth": {release.HookPostInstallFailure, release.HookPostUpgradeFailure, release.HookPostRollbackFailure}},
+			manifest: `kind: Job
+apiVersion: v1
+metadata:
+  name: ninth
+  annotations:
+    "helm.sh/hook": post-install-failure,post-upgrade-failure,post-rollback-failure
 `,
 		},
 	}
@@ -149,8 +163,8 @@ metadata:
 		t.Errorf("Expected 2 generic manifests, got %d", len(generic))
 	}
 
-	if len(hs) != 4 {
-		t.Errorf("Expected 4 hooks, got %d", len(hs))
+	if len(hs) != 5 {
+		t.Errorf("Expected 5 hooks, got %d", len(hs))
 	}
 
 	for _, out := range hs {
@@ -226,3 +240,60 @@ metadata:
 		}
 	}
 }
+
+func TestSortManifestsHookTimeoutAndRetries(t *testing.T) {
+	manifests := map[string]string{
+		"migrate": `kind: Job
+apiVersion: v1
+metadata:
+  name: migrate
+  annotations:
+    "helm.sh/hook": pre-upgrade
+    "helm.sh/hook-timeout": 40m
+    "helm.sh/hook-retries": "3"
+`,
+		"smoke-test": `kind: Job
+apiVersion: v1
+metadata:
+  name: smoke-test
+  annotations:
+    "helm.sh/hook": post-upgrade
+    "helm.sh/hook-timeout": "30"
+`,
+		"invalid": `kind: Job
+apiVersion: v1
+metadata:
+  name: invalid
+  annotations:
+    "helm.sh/hook": post-upgrade
+    "helm.sh/hook-timeout": forever
+    "helm.sh/hook-retries": "-1"
+`,
+	}
+
+	hooks, _, err := SortManifests(manifests, chartutil.VersionSet{"v1"}, InstallOrder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]struct {
+		timeout time.Duration
+		retries int
+	}{
+		"migrate":    {40 * time.Minute, 3},
+		"smoke-test": {30 * time.Second, 0},
+		"invalid":    {0, 0},
+	}
+	if len(hooks) != len(expected) {
+		t.Fatalf("Expected %d hooks, got %d", len(expected), len(hooks))
+	}
+	for _, h := range hooks {
+		e := expected[h.Name]
+		if time.Duration(h.Timeout) != e.timeout {
+			t.Errorf("Expected timeout %s for hook %s, got %s", e.timeout, h.Name, h.Timeout)
+		}
+		if h.Retries != e.retries {
+			t.Errorf("Expected %d retries for hook %s, got %d", e.retries, h.Name, h.Retries)
+		}
+	}
+}
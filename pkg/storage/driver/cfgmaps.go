@@ -43,6 +43,14 @@ const ConfigMapsDriverName = "ConfigMap"
 type ConfigMaps struct {
 	impl corev1.ConfigMapInterface
 	Log  func(string, ...interface{})
+
+	// Encrypter encrypts the stored releases if it is set. Releases stored
+	// without encryption can still be read.
+	Encrypter Encrypter
+	// ChunkSize is the maximum size of the encoded release stored in one
+	// ConfigMap. Larger releases are split across several ConfigMaps. It
+	// defaults to DefaultChunkSize.
+	ChunkSize int
 }
 
 // NewConfigMaps initializes a new ConfigMaps wrapping an implementation of
@@ -73,11 +81,12 @@ func (cfgmaps *ConfigMaps) Get(key string) (*rspb.Release, error) {
 		return nil, err
 	}
 	// found the configmap, decode the base64 data string
-	r, err := decodeRelease(obj.Data["release"])
+	r, err := cfgmaps.decode(obj)
 	if err != nil {
 		cfgmaps.Log("get: failed to decode data %q: %s", key, err)
 		return nil, err
 	}
+	r.Labels = filterSystemLabels(obj.ObjectMeta.Labels)
 	// return the release object
 	return r, nil
 }
@@ -100,13 +109,13 @@ func (cfgmaps *ConfigMaps) List(filter func(*rspb.Release) bool) ([]*rspb.Releas
 	// iterate over the configmaps object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := cfgmaps.decode(&item)
 		if err != nil {
 			cfgmaps.Log("list: failed to decode release: %v: %s", item, err)
 			continue
 		}
 
-		rls.Labels = item.ObjectMeta.Labels
+		rls.Labels = filterSystemLabels(item.ObjectMeta.Labels)
 
 		if filter(rls) {
 			results = append(results, rls)
@@ -140,11 +149,12 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]*rspb.Release, err
 
 	var results []*rspb.Release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := cfgmaps.decode(&item)
 		if err != nil {
 			cfgmaps.Log("query: failed to decode release: %s", err)
 			continue
 		}
+		rls.Labels = filterSystemLabels(item.ObjectMeta.Labels)
 		results = append(results, rls)
 	}
 	return results, nil
@@ -160,13 +170,19 @@ func (cfgmaps *ConfigMaps) Create(key string, rls *rspb.Release) error {
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new configmap to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	obj, err := newConfigMapsObject(key, rls, lbs, cfgmaps.Encrypter)
 	if err != nil {
 		cfgmaps.Log("create: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	chunks, err := cfgmaps.createChunks(obj, rls)
+	if err != nil {
+		cfgmaps.Log("create: failed to create chunks: %s", err)
+		return err
+	}
 	// push the configmap object out into the kubiverse
 	if _, err := cfgmaps.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		cfgmaps.deleteChunks(chunks)
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -187,36 +203,135 @@ func (cfgmaps *ConfigMaps) Update(key string, rls *rspb.Release) error {
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new configmap object to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	obj, err := newConfigMapsObject(key, rls, lbs, cfgmaps.Encrypter)
 	if err != nil {
 		cfgmaps.Log("update: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	chunks, err := cfgmaps.createChunks(obj, rls)
+	if err != nil {
+		cfgmaps.Log("update: failed to create chunks: %s", err)
+		return err
+	}
 	// push the configmap object out into the kubiverse
 	_, err = cfgmaps.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
 	if err != nil {
+		cfgmaps.deleteChunks(chunks)
 		cfgmaps.Log("update: failed to update: %s", err)
 		return err
 	}
+	// the chunks of the previous revision of the object are not linked anymore
+	cfgmaps.deleteStaleChunks(rls.Name, strconv.Itoa(rls.Version), obj.Labels["chunkSet"])
 	return nil
 }
 
-// Delete deletes the ConfigMap holding the release named by key.
+// Delete deletes the ConfigMap holding the release named by key, and the ConfigMaps
+// holding its chunks. The deleted release is returned, but deleting it does
+// not depend on decoding it: a release that cannot be decoded, for instance
+// because one of its chunks is missing, is deleted anyway and returned with
+// the fields recorded in the labels of the ConfigMap.
 func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
-	// fetch the release to check existence
-	if rls, err = cfgmaps.Get(key); err != nil {
-		return nil, err
+	// fetch the ConfigMap to check existence
+	obj, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, errors.Wrapf(err, "delete: failed to get %q", key)
+	}
+	if rls, err = cfgmaps.decode(obj); err != nil {
+		cfgmaps.Log("delete: failed to decode release %q, deleting it anyway: %s", key, err)
+		rls = releaseFromLabels(obj.Labels)
+	} else {
+		rls.Labels = filterSystemLabels(obj.Labels)
 	}
 	// delete the release
 	if err = cfgmaps.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
 		return rls, err
 	}
+	// delete its chunks, and any left over by an interrupted write
+	cfgmaps.deleteStaleChunks(obj.Labels["name"], obj.Labels["version"], "")
 	return rls, nil
 }
 
+// decode decodes the release held by a ConfigMap and the chunks it links.
+func (cfgmaps *ConfigMaps) decode(obj *v1.ConfigMap) (*rspb.Release, error) {
+	data, err := joinChunks(obj.Name, obj.Data["release"], obj.Labels, func(name string) (string, error) {
+		chunk, err := cfgmaps.impl.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return chunk.Data["release"], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeRelease(data, cfgmaps.Encrypter)
+}
+
+// createChunks moves the data of a ConfigMap beyond the chunk size to new
+// ConfigMaps, which are created before the ConfigMap is written. It returns
+// the created ConfigMaps.
+func (cfgmaps *ConfigMaps) createChunks(obj *v1.ConfigMap, rls *rspb.Release) ([]*v1.ConfigMap, error) {
+	parts := splitData(obj.Data["release"], cfgmaps.ChunkSize)
+	if len(parts) == 1 {
+		return nil, nil
+	}
+	set, err := newChunkSet()
+	if err != nil {
+		return nil, err
+	}
+	obj.Data["release"] = parts[0]
+	setChunks(obj.Labels, len(parts), set)
+
+	var chunks []*v1.ConfigMap
+	for i := 1; i < len(parts); i++ {
+		chunk := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkName(obj.Name, set, i),
+				Labels: chunkLabels(rls, set, i),
+			},
+			Data: map[string]string{"release": parts[i]},
+		}
+		if _, err := cfgmaps.impl.Create(context.Background(), chunk, metav1.CreateOptions{}); err != nil {
+			cfgmaps.deleteChunks(chunks)
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// deleteChunks deletes ConfigMaps holding chunks.
+func (cfgmaps *ConfigMaps) deleteChunks(chunks []*v1.ConfigMap) {
+	for _, chunk := range chunks {
+		if err := cfgmaps.impl.Delete(context.Background(), chunk.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			cfgmaps.Log("failed to delete chunk %q: %s", chunk.Name, err)
+		}
+	}
+}
+
+// deleteStaleChunks deletes the ConfigMaps holding chunks of a revision of a
+// release, except the chunks of the set that is in use.
+func (cfgmaps *ConfigMaps) deleteStaleChunks(name, version, inUse string) {
+	opts := metav1.ListOptions{LabelSelector: chunkSelector(name, version)}
+	list, err := cfgmaps.impl.List(context.Background(), opts)
+	if err != nil {
+		cfgmaps.Log("failed to list chunks of release %s version %s: %s", name, version, err)
+		return
+	}
+	var stale []*v1.ConfigMap
+	for i := range list.Items {
+		if list.Items[i].Labels["chunkSet"] != inUse {
+			stale = append(stale, &list.Items[i])
+		}
+	}
+	cfgmaps.deleteChunks(stale)
+}
+
 // newConfigMapsObject constructs a kubernetes ConfigMap object
 // to store a release. Each configmap data entry is the base64
-// encoded gzipped string of a release.
+// encoded gzipped string of a release, encrypted if enc is set.
 //
 // The following labels are used within each configmap:
 //
@@ -227,11 +342,11 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 //    "owner"          - owner of the configmap, currently "helm".
 //    "name"           - name of the release.
 //
-func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigMap, error) {
+func newConfigMapsObject(key string, rls *rspb.Release, lbs labels, enc Encrypter) (*v1.ConfigMap, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeRelease(rls, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -240,6 +355,9 @@ func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigM
 		lbs.init()
 	}
 
+	// apply custom labels first, so that the labels set by Helm take precedence
+	lbs.fromMap(rls.Labels)
+
 	// apply labels
 	lbs.set("name", rls.Name)
 	lbs.set("owner", owner)
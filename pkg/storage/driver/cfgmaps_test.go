@@ -59,7 +59,7 @@ func TestUncompressedConfigMapGet(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	// Create a test fixture which contains an uncompressed release
-	cfgmap, err := newConfigMapsObject(key, rel, nil)
+	cfgmap, err := newConfigMapsObject(key, rel, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create configmap: %s", err)
 	}
@@ -180,6 +180,42 @@ func TestConfigMapCreate(t *testing.T) {
 	}
 }
 
+func TestConfigMapCreateWithCustomLabels(t *testing.T) {
+	cfgmaps := newTestFixtureCfgMaps(t)
+
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+	rel.Labels = map[string]string{"team": "storage", "status": "ignored"}
+
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release with key %q: %s", key, err)
+	}
+
+	got, err := cfgmaps.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release with key %q: %s", key, err)
+	}
+
+	// system labels always reflect the release and are not returned as custom labels
+	expected := map[string]string{"team": "storage"}
+	if !reflect.DeepEqual(expected, got.Labels) {
+		t.Errorf("Expected labels %v, got %v", expected, got.Labels)
+	}
+	if got.Info.Status != rspb.StatusDeployed {
+		t.Errorf("Expected status %s, got %s", rspb.StatusDeployed, got.Info.Status)
+	}
+	listed, err := cfgmaps.List(func(*rspb.Release) bool { return true })
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("Expected 1 release to be listed, got %d, %v", len(listed), err)
+	}
+	if !reflect.DeepEqual(expected, listed[0].Labels) {
+		t.Errorf("Expected listed labels %v, got %v", expected, listed[0].Labels)
+	}
+}
+
 func TestConfigMapUpdate(t *testing.T) {
 	vers := 1
 	name := "smug-pigeon"
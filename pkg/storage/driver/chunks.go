@@ -0,0 +1,126 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	kblabels "k8s.io/apimachinery/pkg/labels"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+// DefaultChunkSize is the default maximum size of the encoded release stored
+// in a single Secret or ConfigMap, below the 1 MiB limit of their data.
+const DefaultChunkSize = 1000 * 1024
+
+// Releases larger than the chunk size of the Secrets and ConfigMaps drivers
+// are split across several objects. The object named by the key of the
+// release holds the first chunk, with the usual labels and the following:
+//
+//    "chunks"         - number of chunks of the release.
+//    "chunkSet"       - ID of the chunks written together with the object.
+//
+// The other chunks are held by objects named after the key, the chunk set and
+// their index. They do not have the "owner" label, so that they are not
+// listed as releases, and are linked to their release by the following:
+//
+//    "name"           - name of the release.
+//    "version"        - version of the release.
+//    "chunk"          - index of the chunk, starting at 1.
+//    "chunkSet"       - ID of the chunks written together.
+//
+// The chunks are always written before the object that links them, and
+// removed after it, with a new chunk set for every write, so that readers
+// see a release as a whole.
+
+// splitData splits the encoded data of a release in parts of at most size
+// bytes.
+func splitData(data string, size int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	parts := make([]string, 0, len(data)/size+1)
+	for len(data) > size {
+		parts = append(parts, data[:size])
+		data = data[size:]
+	}
+	return append(parts, data)
+}
+
+// newChunkSet returns a random ID for the chunks of a release written
+// together.
+func newChunkSet() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// chunkName returns the name of the object holding chunk i of the release
+// stored under key.
+func chunkName(key, set string, i int) string {
+	return fmt.Sprintf("%s.%s.%d", key, set, i)
+}
+
+// chunkLabels returns the labels of the object holding chunk i of a release.
+func chunkLabels(rls *rspb.Release, set string, i int) map[string]string {
+	return map[string]string{
+		"name":     rls.Name,
+		"version":  strconv.Itoa(rls.Version),
+		"chunk":    strconv.Itoa(i),
+		"chunkSet": set,
+	}
+}
+
+// setChunks labels the object holding the first chunk of a release with the
+// number of chunks and their set.
+func setChunks(lbs map[string]string, n int, set string) {
+	lbs["chunks"] = strconv.Itoa(n)
+	lbs["chunkSet"] = set
+}
+
+// chunkSelector selects the objects holding the chunks of a revision of a
+// release.
+func chunkSelector(name, version string) string {
+	return kblabels.Set{"name": name, "version": version}.AsSelector().String() + ",chunk"
+}
+
+// joinChunks returns the data of the object named key, with the data of the
+// chunks it links appended. get returns the data of an object by name.
+func joinChunks(key, data string, lbs map[string]string, get func(name string) (string, error)) (string, error) {
+	n, _ := strconv.Atoi(lbs["chunks"])
+	if n <= 1 {
+		return data, nil
+	}
+	var b strings.Builder
+	b.WriteString(data)
+	for i := 1; i < n; i++ {
+		chunk, err := get(chunkName(key, lbs["chunkSet"], i))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get chunk %d of %d of %q", i+1, n, key)
+		}
+		b.WriteString(chunk)
+	}
+	return b.String(), nil
+}
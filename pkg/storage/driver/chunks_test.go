@@ -0,0 +1,243 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+func TestSplitData(t *testing.T) {
+	tests := []struct {
+		data string
+		size int
+		want []string
+	}{
+		{"", 3, []string{""}},
+		{"abc", 3, []string{"abc"}},
+		{"abcdefg", 3, []string{"abc", "def", "g"}},
+		{"abcdef", 3, []string{"abc", "def"}},
+	}
+	for _, tt := range tests {
+		if got := splitData(tt.data, tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitData(%q, %d) = %q, expected %q", tt.data, tt.size, got, tt.want)
+		}
+	}
+}
+
+// largeReleaseStub returns a release encoded in several chunks of 64 bytes.
+func largeReleaseStub(name string, vers int, status rspb.Status) *rspb.Release {
+	rls := releaseStub(name, vers, "default", status)
+	rls.Manifest = strings.Repeat("apiVersion: v1\nkind: ConfigMap\n", 20)
+	rls.Config = map[string]interface{}{"random": "3ecd6b4ff5e0d1fb9a3d4b3d5df6f1a7c3b41e16c2b0a6a2f8e4f9b8d7c6a5e4f3"}
+	return rls
+}
+
+func TestSecretsChunks(t *testing.T) {
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = 64
+	mock := secrets.impl.(*MockSecretsInterface)
+
+	rel := largeReleaseStub("smug-pigeon", 1, rspb.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	if len(mock.objects) < 3 {
+		t.Fatalf("Expected the release to be split across Secrets, got %d Secrets", len(mock.objects))
+	}
+	chunks := 0
+	for name, obj := range mock.objects {
+		if size := len(obj.Data["release"]); size > 64 {
+			t.Errorf("Expected Secret %s to hold at most 64 bytes, got %d", name, size)
+		}
+		if name != key {
+			chunks++
+			if obj.Labels["owner"] != "" {
+				t.Errorf("Expected chunk %s not to have an owner", name)
+			}
+		}
+	}
+	if got := mock.objects[key].Labels["chunks"]; got != strconv.Itoa(chunks+1) {
+		t.Errorf("Expected the release to link %d chunks, got %s", chunks+1, got)
+	}
+
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	rels, err := secrets.List(func(*rspb.Release) bool { return true })
+	if err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release to be listed, got %d, %v", len(rels), err)
+	}
+	if rels[0].Manifest != rel.Manifest {
+		t.Errorf("Expected the listed release to be reassembled")
+	}
+	rels, err = secrets.Query(map[string]string{"name": rel.Name, "owner": "helm"})
+	if err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release to be queried, got %d, %v", len(rels), err)
+	}
+
+	// updating the release replaces its chunks
+	rel.Info.Status = rspb.StatusSuperseded
+	before := len(mock.objects)
+	set := mock.objects[key].Labels["chunkSet"]
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != before {
+		t.Errorf("Expected the previous chunks to be deleted, got %d Secrets instead of %d", len(mock.objects), before)
+	}
+	if mock.objects[key].Labels["chunkSet"] == set {
+		t.Errorf("Expected the update to write new chunks")
+	}
+	if got, err := secrets.Get(key); err != nil || got.Info.Status != rspb.StatusSuperseded {
+		t.Errorf("Expected the updated release, got %v, %v", got, err)
+	}
+
+	// a release that fits in a single Secret drops its chunks
+	secrets.ChunkSize = 0
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != 1 {
+		t.Errorf("Expected only the release Secret to be left, got %d Secrets", len(mock.objects))
+	}
+
+	secrets.ChunkSize = 64
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if _, err := secrets.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected the release and its chunks to be deleted, got %d Secrets", len(mock.objects))
+	}
+}
+
+func TestSecretsMissingChunk(t *testing.T) {
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = 64
+	mock := secrets.impl.(*MockSecretsInterface)
+
+	rel := largeReleaseStub("smug-pigeon", 1, rspb.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	delete(mock.objects, chunkName(key, mock.objects[key].Labels["chunkSet"], 1))
+
+	if _, err := secrets.Get(key); err == nil || !strings.Contains(err.Error(), "failed to get chunk 2 of") {
+		t.Errorf("Expected a missing chunk error, got %v", err)
+	}
+
+	// the release can still be deleted, along with its remaining chunks
+	deleted, err := secrets.Delete(key)
+	if err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if deleted.Name != rel.Name || deleted.Version != rel.Version || deleted.Info.Status != rspb.StatusDeployed {
+		t.Errorf("Expected the deleted release to be described by its labels, got %v", deleted)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected the release and its chunks to be deleted, got %d Secrets", len(mock.objects))
+	}
+}
+
+func TestSecretsCreateExistingChunked(t *testing.T) {
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = 64
+	mock := secrets.impl.(*MockSecretsInterface)
+
+	rel := largeReleaseStub("smug-pigeon", 1, rspb.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	before := len(mock.objects)
+	if err := secrets.Create(key, rel); err != ErrReleaseExists {
+		t.Fatalf("Expected ErrReleaseExists, got %v", err)
+	}
+	if len(mock.objects) != before {
+		t.Errorf("Expected the chunks of the failed create to be deleted, got %d Secrets instead of %d", len(mock.objects), before)
+	}
+	if _, err := secrets.Get(key); err != nil {
+		t.Errorf("Expected the existing release to be intact, got %v", err)
+	}
+}
+
+func TestConfigMapsChunks(t *testing.T) {
+	cfgmaps := newTestFixtureCfgMaps(t)
+	cfgmaps.ChunkSize = 64
+	mock := cfgmaps.impl.(*MockConfigMapsInterface)
+
+	rel := largeReleaseStub("smug-pigeon", 1, rspb.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	if len(mock.objects) < 3 {
+		t.Fatalf("Expected the release to be split across ConfigMaps, got %d ConfigMaps", len(mock.objects))
+	}
+
+	got, err := cfgmaps.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+	rels, err := cfgmaps.List(func(*rspb.Release) bool { return true })
+	if err != nil || len(rels) != 1 {
+		t.Fatalf("Expected 1 release to be listed, got %d, %v", len(rels), err)
+	}
+
+	before := len(mock.objects)
+	rel.Info.Status = rspb.StatusSuperseded
+	if err := cfgmaps.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != before {
+		t.Errorf("Expected the previous chunks to be deleted, got %d ConfigMaps instead of %d", len(mock.objects), before)
+	}
+
+	if _, err := cfgmaps.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected the release and its chunks to be deleted, got %d ConfigMaps", len(mock.objects))
+	}
+}
+
+func TestChunkLabelsAreSystemLabels(t *testing.T) {
+	lbs := chunkLabels(releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed), "0a1b2c3d", 1)
+	setChunks(lbs, 2, "0a1b2c3d")
+	for k := range lbs {
+		if !isSystemLabel(k) {
+			t.Errorf("Expected the chunk label %q to be reserved", k)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Encrypter encrypts the keys that encrypt the payloads of releases.
+//
+// Every release is encrypted with AES-GCM under a new random data key, which
+// is encrypted by the Encrypter and stored alongside the release, along with
+// the ID of the key that encrypted it. This lets an Encrypter be backed by a
+// key management service that is called once per release, and lets its keys
+// be rotated: releases encrypted with a previous key can be decrypted as long
+// as the Encrypter still knows that key.
+type Encrypter interface {
+	// KeyID returns the ID of the key Encrypt uses.
+	KeyID() string
+	// Encrypt encrypts a data key with the key identified by KeyID.
+	Encrypt(plaintext []byte) ([]byte, error)
+	// Decrypt decrypts a data key that was encrypted with the key identified
+	// by keyID.
+	Decrypt(keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// magicEnvelope starts the payload of encrypted releases. It cannot be
+// mistaken for the gzip header or for JSON, so releases that were stored
+// before encryption was enabled can still be decoded.
+var magicEnvelope = []byte{0x00, 'h', 'e', 'n', 'c', 0x01}
+
+// dataKeySize is the size of the AES-256 data keys.
+const dataKeySize = 32
+
+// isEncrypted returns whether a decoded payload is an encrypted release.
+func isEncrypted(b []byte) bool {
+	return bytes.HasPrefix(b, magicEnvelope)
+}
+
+// seal encrypts a payload under a new data key. The envelope is made of the
+// magic bytes, the key ID, the encrypted data key, the nonce and the
+// encrypted payload. Everything before the nonce is authenticated.
+func seal(enc Encrypter, payload []byte) ([]byte, error) {
+	keyID := enc.KeyID()
+	if len(keyID) > 255 {
+		return nil, errors.Errorf("encryption key ID %q is longer than 255 characters", keyID)
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	encryptedKey, err := enc.Encrypt(dataKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encrypt data key with key %q", keyID)
+	}
+	if len(encryptedKey) > 0xffff {
+		return nil, errors.New("encrypted data key is too large")
+	}
+
+	var header bytes.Buffer
+	header.Write(magicEnvelope)
+	header.WriteByte(byte(len(keyID)))
+	header.WriteString(keyID)
+	keyLen := make([]byte, 2)
+	binary.BigEndian.PutUint16(keyLen, uint16(len(encryptedKey)))
+	header.Write(keyLen)
+	header.Write(encryptedKey)
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(header.Bytes(), nonce...)
+	return aead.Seal(out, nonce, payload, header.Bytes()), nil
+}
+
+// open decrypts a payload sealed by seal.
+func open(enc Encrypter, b []byte) ([]byte, error) {
+	if enc == nil {
+		return nil, errors.New("release is encrypted but no encryption key is configured")
+	}
+	malformed := errors.New("malformed encrypted release")
+
+	r := bytes.NewReader(b[len(magicEnvelope):])
+	idLen, err := r.ReadByte()
+	if err != nil {
+		return nil, malformed
+	}
+	keyID := make([]byte, idLen)
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, malformed
+	}
+	var keyLen uint16
+	if err := binary.Read(r, binary.BigEndian, &keyLen); err != nil {
+		return nil, malformed
+	}
+	encryptedKey := make([]byte, keyLen)
+	if _, err := io.ReadFull(r, encryptedKey); err != nil {
+		return nil, malformed
+	}
+	header := b[:len(b)-r.Len()]
+
+	dataKey, err := enc.Decrypt(string(keyID), encryptedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt data key with key %q", keyID)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	rest := b[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return nil, malformed
+	}
+	payload, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt release")
+	}
+	return payload, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptionKey is an AES key of an AESGCMEncrypter.
+type EncryptionKey struct {
+	// ID identifies the key in the releases it encrypted.
+	ID string `json:"id"`
+	// Secret is a 16, 24 or 32 bytes AES key, base64 encoded in key files.
+	Secret []byte `json:"secret"`
+}
+
+// AESGCMEncrypter encrypts data keys with AES-GCM under local keys.
+type AESGCMEncrypter struct {
+	keyID string
+	keys  map[string]cipher.AEAD
+}
+
+// NewAESGCMEncrypter creates an Encrypter from a list of keys. The first key
+// encrypts, and every key decrypts, so that a key is rotated by adding a new
+// key first and removing the old key once no release uses it anymore.
+func NewAESGCMEncrypter(keys ...EncryptionKey) (*AESGCMEncrypter, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption key")
+	}
+	e := &AESGCMEncrypter{
+		keyID: keys[0].ID,
+		keys:  make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, k := range keys {
+		if k.ID == "" || len(k.ID) > 255 {
+			return nil, errors.Errorf("encryption key ID %q must be between 1 and 255 characters", k.ID)
+		}
+		if _, ok := e.keys[k.ID]; ok {
+			return nil, errors.Errorf("encryption key %q is listed more than once", k.ID)
+		}
+		aead, err := newGCM(k.Secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid encryption key %q", k.ID)
+		}
+		e.keys[k.ID] = aead
+	}
+	return e, nil
+}
+
+// LoadKeyFile creates an AESGCMEncrypter from a YAML key file:
+//
+//     keys:
+//     - id: 2021-10
+//       secret: <base64 encoded key>
+//     - id: 2021-01
+//       secret: <base64 encoded key>
+func LoadKeyFile(filename string) (*AESGCMEncrypter, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Keys []EncryptionKey `json:"keys"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse key file %s", filename)
+	}
+	e, err := NewAESGCMEncrypter(file.Keys...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "key file %s", filename)
+	}
+	return e, nil
+}
+
+// KeyID returns the ID of the first key.
+func (e *AESGCMEncrypter) KeyID() string {
+	return e.keyID
+}
+
+// Encrypt encrypts plaintext with the first key.
+func (e *AESGCMEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
+	aead := e.keys[e.keyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(e.keyID)), nil
+}
+
+// Decrypt decrypts ciphertext with the key identified by keyID.
+func (e *AESGCMEncrypter) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	aead, ok := e.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("unknown encryption key %q", keyID)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], []byte(keyID))
+}
+
+// ExecEncrypter delegates the encryption of data keys to an external command,
+// typically a plugin calling a key management service. The command is run
+// with its arguments followed by
+//
+//     key-id              to print the ID of the key to encrypt with
+//     encrypt KEY_ID      to print the encryption of its standard input
+//     decrypt KEY_ID      to print the decryption of its standard input
+//
+// Rotating the keys of the service is up to the command: the key ID it prints
+// is recorded in the releases and given back to decrypt them.
+type ExecEncrypter struct {
+	// Command is the path of the command.
+	Command string
+	// Args are the arguments given to the command before the operation.
+	Args []string
+	// ID is the key ID recorded in the releases. If it is empty, the command
+	// is run once with "key-id" to get it.
+	ID string
+
+	keyIDOnce sync.Once
+	keyID     string
+	keyIDErr  error
+}
+
+// KeyID returns the ID of the key of the command. It is empty if the command
+// failed to print it, in which case Encrypt fails with the error.
+func (e *ExecEncrypter) KeyID() string {
+	keyID, _ := e.currentKeyID()
+	return keyID
+}
+
+// currentKeyID returns ID, or else the key ID printed by the command.
+func (e *ExecEncrypter) currentKeyID() (string, error) {
+	if e.ID != "" {
+		return e.ID, nil
+	}
+	e.keyIDOnce.Do(func() {
+		out, err := e.run(nil, "key-id")
+		if err != nil {
+			e.keyIDErr = err
+			return
+		}
+		e.keyID = strings.TrimSpace(string(out))
+		if e.keyID == "" {
+			e.keyIDErr = errors.Errorf("%s key-id: no key ID printed", e.Command)
+		}
+	})
+	return e.keyID, e.keyIDErr
+}
+
+// Encrypt encrypts plaintext with the command.
+func (e *ExecEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
+	keyID, err := e.currentKeyID()
+	if err != nil {
+		return nil, err
+	}
+	return e.run(plaintext, "encrypt", keyID)
+}
+
+// Decrypt decrypts ciphertext with the command.
+func (e *ExecEncrypter) Decrypt(keyID string, ciphertext []byte) ([]byte, error) {
+	return e.run(ciphertext, "decrypt", keyID)
+}
+
+func (e *ExecEncrypter) run(in []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command(e.Command, append(append([]string{}, e.Args...), args...)...)
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s: %s", e.Command, args[0], bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+func testEncrypter(t *testing.T, keys ...EncryptionKey) *AESGCMEncrypter {
+	t.Helper()
+	enc, err := NewAESGCMEncrypter(keys...)
+	if err != nil {
+		t.Fatalf("Failed to create encrypter: %s", err)
+	}
+	return enc
+}
+
+var (
+	key1 = EncryptionKey{ID: "one", Secret: bytes.Repeat([]byte{1}, 32)}
+	key2 = EncryptionKey{ID: "two", Secret: bytes.Repeat([]byte{2}, 32)}
+)
+
+func TestEncryptedRelease(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	rel.Config = map[string]interface{}{"password": "hunter2"}
+	enc := testEncrypter(t, key1)
+
+	data, err := encodeRelease(rel, enc)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+	raw, _ := b64.DecodeString(data)
+	if !isEncrypted(raw) {
+		t.Fatal("Expected the release to be encrypted")
+	}
+	if bytes.Contains(raw, []byte("hunter2")) {
+		t.Fatal("Expected the values of the release not to be readable")
+	}
+
+	got, err := decodeRelease(data, enc)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	if _, err := decodeRelease(data, nil); err == nil || err.Error() != "release is encrypted but no encryption key is configured" {
+		t.Errorf("Expected an error without encrypter, got %v", err)
+	}
+
+	t.Run("rotated key", func(t *testing.T) {
+		rotated := testEncrypter(t, key2, key1)
+		if _, err := decodeRelease(data, rotated); err != nil {
+			t.Fatalf("Failed to decode release with a previous key: %s", err)
+		}
+		data, err := encodeRelease(rel, rotated)
+		if err != nil {
+			t.Fatalf("Failed to encode release: %s", err)
+		}
+		_, err = decodeRelease(data, enc)
+		if err == nil || !strings.Contains(err.Error(), `unknown encryption key "two"`) {
+			t.Errorf("Expected an unknown key error, got %v", err)
+		}
+	})
+
+	t.Run("unencrypted release", func(t *testing.T) {
+		data, err := encodeRelease(rel, nil)
+		if err != nil {
+			t.Fatalf("Failed to encode release: %s", err)
+		}
+		got, err := decodeRelease(data, enc)
+		if err != nil {
+			t.Fatalf("Failed to decode unencrypted release: %s", err)
+		}
+		if !reflect.DeepEqual(rel, got) {
+			t.Errorf("Expected {%v}, got {%v}", rel, got)
+		}
+	})
+
+	t.Run("tampered release", func(t *testing.T) {
+		tampered := append([]byte{}, raw...)
+		tampered[len(tampered)-1] ^= 0xff
+		if _, err := decodeRelease(b64.EncodeToString(tampered), enc); err == nil {
+			t.Error("Expected an error decoding a tampered release")
+		}
+		truncated := raw[:len(magicEnvelope)+2]
+		if _, err := decodeRelease(b64.EncodeToString(truncated), enc); err == nil || err.Error() != "malformed encrypted release" {
+			t.Errorf("Expected a malformed release error, got %v", err)
+		}
+	})
+}
+
+func TestNewAESGCMEncrypter(t *testing.T) {
+	tests := []struct {
+		keys []EncryptionKey
+		err  string
+	}{
+		{nil, "no encryption key"},
+		{[]EncryptionKey{{Secret: key1.Secret}}, `encryption key ID "" must be between 1 and 255 characters`},
+		{[]EncryptionKey{key1, key1}, `encryption key "one" is listed more than once`},
+		{[]EncryptionKey{{ID: "short", Secret: []byte("short")}}, `invalid encryption key "short": crypto/aes: invalid key size 5`},
+	}
+	for _, tt := range tests {
+		if _, err := NewAESGCMEncrypter(tt.keys...); err == nil || err.Error() != tt.err {
+			t.Errorf("Expected error %q, got %v", tt.err, err)
+		}
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "keys.yaml")
+	content := "keys:\n- id: two\n  secret: " + b64.EncodeToString(key2.Secret) + "\n- id: one\n  secret: " + b64.EncodeToString(key1.Secret) + "\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := LoadKeyFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to load key file: %s", err)
+	}
+	if enc.KeyID() != "two" {
+		t.Errorf("Expected the first key to encrypt, got %q", enc.KeyID())
+	}
+	ciphertext, err := testEncrypter(t, key1).Encrypt([]byte("data key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := enc.Decrypt("one", ciphertext); err != nil || string(plaintext) != "data key" {
+		t.Errorf("Expected the second key to decrypt, got %q, %v", plaintext, err)
+	}
+}
+
+func TestExecEncrypter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command requires a POSIX shell")
+	}
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	// The command "encrypts" the data keys with base64, with the key "kms".
+	enc := &ExecEncrypter{
+		Command: "sh",
+		Args:    []string{"-c", `case "$0 $1" in "key-id ") echo kms ;; encrypt*) base64 ;; "decrypt kms") base64 -d ;; *) echo "unknown key $1" >&2; exit 1 ;; esac`},
+	}
+	if keyID := enc.KeyID(); keyID != "kms" {
+		t.Errorf("Expected the key ID printed by the command, got %q", keyID)
+	}
+	data, err := encodeRelease(rel, enc)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+	got, err := decodeRelease(data, enc)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	enc.ID = "other"
+	data, err = encodeRelease(rel, enc)
+	if err != nil {
+		t.Fatalf("Failed to encode release: %s", err)
+	}
+	if _, err := decodeRelease(data, enc); err == nil || !strings.Contains(err.Error(), "unknown key other") {
+		t.Errorf("Expected the error of the command, got %v", err)
+	}
+}
+
+func TestExecEncrypterKeyIDError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command requires a POSIX shell")
+	}
+	enc := &ExecEncrypter{
+		Command: "sh",
+		Args:    []string{"-c", `case "$0" in key-id) exit 0 ;; *) base64 ;; esac`},
+	}
+	if keyID := enc.KeyID(); keyID != "" {
+		t.Errorf("Expected no key ID, got %q", keyID)
+	}
+	if _, err := enc.Encrypt([]byte("data key")); err == nil || !strings.Contains(err.Error(), "no key ID printed") {
+		t.Errorf("Expected encryption to fail without a key ID, got %v", err)
+	}
+}
+
+func TestSecretsEncryption(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	key := testKey(rel.Name, rel.Version)
+	secrets := newTestFixtureSecrets(t)
+	secrets.Encrypter = testEncrypter(t, key1)
+
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	obj := secrets.impl.(*MockSecretsInterface).objects[key]
+	raw, err := b64.DecodeString(string(obj.Data["release"]))
+	if err != nil || !isEncrypted(raw) {
+		t.Fatalf("Expected the stored release to be encrypted, got %v", err)
+	}
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+}
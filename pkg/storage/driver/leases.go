@@ -0,0 +1,200 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationclientv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+var _ ReleaseLocker = (*Leases)(nil)
+
+// Leases locks releases with coordination.k8s.io Lease objects. It is used
+// alongside the Secrets and ConfigMaps drivers, and requires permission to
+// get, create, update and delete Leases in the namespace of the releases.
+type Leases struct {
+	impl coordinationclientv1.LeaseInterface
+	Log  func(string, ...interface{})
+}
+
+// NewLeases initializes a new Leases locker wrapping an implementation of
+// the kubernetes LeasesInterface.
+func NewLeases(impl coordinationclientv1.LeaseInterface) *Leases {
+	return &Leases{
+		impl: impl,
+		Log:  func(_ string, _ ...interface{}) {},
+	}
+}
+
+// AcquireLock acquires or renews the Lease locking the named release.
+func (leases *Leases) AcquireLock(name, holder string, ttl time.Duration) error {
+	now := time.Now()
+	key := lockKey(name)
+
+	obj, err := leases.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		obj = newLeaseObject(key, name, holder, ttl, now)
+		if _, err := leases.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				// Another holder created the lease first
+				return leases.lockedError(name)
+			}
+			return errors.Wrapf(err, "lock: failed to create lease %q", key)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "lock: failed to get lease %q", key)
+	}
+
+	current := leaseToLock(obj)
+	if err := acquirable(name, current, holder, now); err != nil {
+		return err
+	}
+
+	if current.Holder != holder {
+		leases.Log("lock: taking over expired lock of release %q held by %s", name, current.Holder)
+		obj.Spec.AcquireTime = &metav1.MicroTime{Time: now}
+	}
+	obj.Spec.HolderIdentity = &holder
+	obj.Spec.LeaseDurationSeconds = leaseDurationSeconds(ttl)
+	obj.Spec.RenewTime = &metav1.MicroTime{Time: now}
+
+	// The update is rejected if the lease changed since it was read, which
+	// means another holder got to it first.
+	if _, err := leases.impl.Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return leases.lockedError(name)
+		}
+		return errors.Wrapf(err, "lock: failed to update lease %q", key)
+	}
+	return nil
+}
+
+// ReleaseLock deletes the Lease locking the named release if it is held by holder.
+func (leases *Leases) ReleaseLock(name, holder string) error {
+	key := lockKey(name)
+	obj, err := leases.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unlock: failed to get lease %q", key)
+	}
+	if l := leaseToLock(obj); l.Holder != holder {
+		leases.Log("unlock: lock of release %q is held by %s, not releasing it", name, l.Holder)
+		return nil
+	}
+
+	// Only delete the lease we have read, in case it was taken over in the meantime
+	err = leases.impl.Delete(context.Background(), key, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &obj.ResourceVersion},
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return errors.Wrapf(err, "unlock: failed to delete lease %q", key)
+	}
+	return nil
+}
+
+// GetLock returns the lock described by the Lease of the named release.
+func (leases *Leases) GetLock(name string) (*Lock, error) {
+	obj, err := leases.impl.Get(context.Background(), lockKey(name), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrLockNotFound
+		}
+		return nil, errors.Wrapf(err, "get lock: failed to get lease %q", lockKey(name))
+	}
+	return leaseToLock(obj), nil
+}
+
+// BreakLock deletes the Lease of the named release.
+func (leases *Leases) BreakLock(name string) error {
+	err := leases.impl.Delete(context.Background(), lockKey(name), metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrLockNotFound
+	}
+	return errors.Wrapf(err, "break lock: failed to delete lease %q", lockKey(name))
+}
+
+func (leases *Leases) lockedError(name string) error {
+	l, err := leases.GetLock(name)
+	if err != nil {
+		l = nil
+	}
+	return &ReleaseLockedError{ReleaseName: name, Lock: l}
+}
+
+// lockKey returns the name of the object locking the named release.
+func lockKey(name string) string {
+	return "sh.helm.release.v1." + name + ".lock"
+}
+
+func leaseDurationSeconds(ttl time.Duration) *int32 {
+	seconds := int32(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return &seconds
+}
+
+// newLeaseObject constructs a kubernetes Lease object locking a release.
+//
+// The following labels are used within each lease:
+//
+//	"owner"          - owner of the lease, currently "helm".
+//	"name"           - name of the release.
+func newLeaseObject(key, name, holder string, ttl time.Duration, now time.Time) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: key,
+			Labels: map[string]string{
+				"name":  name,
+				"owner": "helm",
+			},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: leaseDurationSeconds(ttl),
+			AcquireTime:          &metav1.MicroTime{Time: now},
+			RenewTime:            &metav1.MicroTime{Time: now},
+		},
+	}
+}
+
+func leaseToLock(obj *coordinationv1.Lease) *Lock {
+	l := &Lock{}
+	if obj.Spec.HolderIdentity != nil {
+		l.Holder = *obj.Spec.HolderIdentity
+	}
+	if obj.Spec.AcquireTime != nil {
+		l.AcquiredAt = obj.Spec.AcquireTime.Time
+	}
+	if obj.Spec.RenewTime != nil {
+		l.RenewedAt = obj.Spec.RenewTime.Time
+	}
+	if obj.Spec.LeaseDurationSeconds != nil {
+		l.TTL = time.Duration(*obj.Spec.LeaseDurationSeconds) * time.Second
+	}
+	return l
+}
@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrReleaseLocked indicates that a release is locked by another operation.
+	ErrReleaseLocked = errors.New("release: locked by another operation")
+	// ErrLockNotFound indicates that a release is not locked.
+	ErrLockNotFound = errors.New("release: not locked")
+)
+
+// Lock describes the lock of a release.
+type Lock struct {
+	// Holder identifies the process holding the lock.
+	Holder string `json:"holder"`
+	// AcquiredAt is when the holder acquired the lock.
+	AcquiredAt time.Time `json:"acquiredAt"`
+	// RenewedAt is when the holder last renewed the lock.
+	RenewedAt time.Time `json:"renewedAt"`
+	// TTL is how long the lock is valid after it was last renewed.
+	TTL time.Duration `json:"ttl"`
+}
+
+// Expires returns when the lock expires unless it is renewed.
+func (l *Lock) Expires() time.Time {
+	return l.RenewedAt.Add(l.TTL)
+}
+
+// Expired reports whether the lock has expired at the given time.
+func (l *Lock) Expired(now time.Time) bool {
+	return now.After(l.Expires())
+}
+
+// ReleaseLockedError records the lock held by another holder on a release.
+type ReleaseLockedError struct {
+	ReleaseName string
+	Lock        *Lock
+}
+
+func (e *ReleaseLockedError) Error() string {
+	if e.Lock == nil {
+		return fmt.Sprintf("release %q is locked by another operation", e.ReleaseName)
+	}
+	return fmt.Sprintf("release %q is locked by %s since %s (expires %s)",
+		e.ReleaseName, e.Lock.Holder, e.Lock.AcquiredAt.Format(time.RFC3339), e.Lock.Expires().Format(time.RFC3339))
+}
+
+func (e *ReleaseLockedError) Unwrap() error { return ErrReleaseLocked }
+
+// ReleaseLocker is implemented by drivers that can lock a release against
+// concurrent operations. Locks are scoped to the namespace of the driver.
+type ReleaseLocker interface {
+	// AcquireLock acquires the lock of the named release for holder, or
+	// renews it if holder already holds it. It returns a *ReleaseLockedError
+	// if another holder has a lock that has not expired.
+	AcquireLock(name, holder string, ttl time.Duration) error
+	// ReleaseLock releases the lock of the named release if it is held by
+	// holder. Releasing a lock that is not held is not an error.
+	ReleaseLock(name, holder string) error
+	// GetLock returns the lock of the named release or ErrLockNotFound.
+	GetLock(name string) (*Lock, error)
+	// BreakLock removes the lock of the named release regardless of its
+	// holder, or returns ErrLockNotFound.
+	BreakLock(name string) error
+}
+
+// acquirable checks whether holder can take or renew the current lock of a
+// release at the given time.
+func acquirable(name string, current *Lock, holder string, now time.Time) error {
+	if current == nil || current.Holder == holder || current.Expired(now) {
+		return nil
+	}
+	return &ReleaseLockedError{ReleaseName: name, Lock: current}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testReleaseLocker(t *testing.T, locker ReleaseLocker) {
+	t.Helper()
+
+	if _, err := locker.GetLock("smug-pigeon"); err != ErrLockNotFound {
+		t.Fatalf("Expected ErrLockNotFound, got %v", err)
+	}
+
+	if err := locker.AcquireLock("smug-pigeon", "alice", time.Minute); err != nil {
+		t.Fatalf("Failed to acquire lock: %s", err)
+	}
+	// Renewing a held lock succeeds
+	if err := locker.AcquireLock("smug-pigeon", "alice", time.Minute); err != nil {
+		t.Fatalf("Failed to renew lock: %s", err)
+	}
+
+	err := locker.AcquireLock("smug-pigeon", "bob", time.Minute)
+	if !errors.Is(err, ErrReleaseLocked) {
+		t.Fatalf("Expected ErrReleaseLocked, got %v", err)
+	}
+	lockedErr := &ReleaseLockedError{}
+	if !errors.As(err, &lockedErr) || lockedErr.Lock == nil || lockedErr.Lock.Holder != "alice" {
+		t.Errorf("Expected the error to describe the lock held by alice, got %v", err)
+	}
+
+	// Other releases are not affected
+	if err := locker.AcquireLock("other-pigeon", "bob", time.Minute); err != nil {
+		t.Fatalf("Failed to acquire lock of other release: %s", err)
+	}
+
+	l, err := locker.GetLock("smug-pigeon")
+	if err != nil {
+		t.Fatalf("Failed to get lock: %s", err)
+	}
+	if l.Holder != "alice" || l.TTL != time.Minute {
+		t.Errorf("Unexpected lock %+v", l)
+	}
+
+	// Only the holder releases the lock
+	if err := locker.ReleaseLock("smug-pigeon", "bob"); err != nil {
+		t.Fatalf("Failed to release lock: %s", err)
+	}
+	if _, err := locker.GetLock("smug-pigeon"); err != nil {
+		t.Fatalf("Expected the lock to be kept, got %v", err)
+	}
+	if err := locker.ReleaseLock("smug-pigeon", "alice"); err != nil {
+		t.Fatalf("Failed to release lock: %s", err)
+	}
+	if err := locker.AcquireLock("smug-pigeon", "bob", time.Minute); err != nil {
+		t.Fatalf("Failed to acquire released lock: %s", err)
+	}
+
+	if err := locker.BreakLock("smug-pigeon"); err != nil {
+		t.Fatalf("Failed to break lock: %s", err)
+	}
+	if err := locker.BreakLock("smug-pigeon"); err != ErrLockNotFound {
+		t.Fatalf("Expected ErrLockNotFound, got %v", err)
+	}
+}
+
+func TestMemoryReleaseLocker(t *testing.T) {
+	testReleaseLocker(t, NewMemory())
+}
+
+func TestLeasesReleaseLocker(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	testReleaseLocker(t, NewLeases(client.CoordinationV1().Leases("default")))
+}
+
+func TestExpiredLockIsTakenOver(t *testing.T) {
+	mem := NewMemory()
+	if err := mem.AcquireLock("smug-pigeon", "alice", -time.Minute); err != nil {
+		t.Fatalf("Failed to acquire lock: %s", err)
+	}
+
+	leaseClient := fake.NewSimpleClientset().CoordinationV1().Leases("default")
+	lease := newLeaseObject(lockKey("smug-pigeon"), "smug-pigeon", "alice", time.Minute, time.Now().Add(-time.Hour))
+	if _, err := leaseClient.Create(context.Background(), lease, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create lease: %s", err)
+	}
+
+	lockers := map[string]ReleaseLocker{
+		"memory": mem,
+		"leases": NewLeases(leaseClient),
+	}
+	for name, locker := range lockers {
+		t.Run(name, func(t *testing.T) {
+			if err := locker.AcquireLock("smug-pigeon", "bob", time.Minute); err != nil {
+				t.Fatalf("Expected the expired lock to be taken over, got %v", err)
+			}
+			l, err := locker.GetLock("smug-pigeon")
+			if err != nil {
+				t.Fatalf("Failed to get lock: %s", err)
+			}
+			if l.Holder != "bob" {
+				t.Errorf("Expected lock held by bob, got %s", l.Holder)
+			}
+		})
+	}
+}
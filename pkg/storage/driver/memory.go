@@ -20,11 +20,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
 
 var _ Driver = (*Memory)(nil)
+var _ ReleaseLocker = (*Memory)(nil)
 
 const (
 	// MemoryDriverName is the string name of this driver.
@@ -42,11 +44,13 @@ type Memory struct {
 	namespace string
 	// A map of namespaces to releases
 	cache map[string]memReleases
+	// A map of namespaces to release names to locks
+	locks map[string]map[string]*Lock
 }
 
 // NewMemory initializes a new memory driver.
 func NewMemory() *Memory {
-	return &Memory{cache: map[string]memReleases{}, namespace: "default"}
+	return &Memory{cache: map[string]memReleases{}, locks: map[string]map[string]*Lock{}, namespace: "default"}
 }
 
 // SetNamespace sets a specific namespace in which releases will be accessed.
@@ -222,6 +226,60 @@ func (mem *Memory) Delete(key string) (*rspb.Release, error) {
 	return nil, ErrReleaseNotFound
 }
 
+// AcquireLock acquires or renews the lock of the named release.
+func (mem *Memory) AcquireLock(name, holder string, ttl time.Duration) error {
+	defer unlock(mem.wlock())
+
+	now := time.Now()
+	current := mem.locks[mem.namespace][name]
+	if err := acquirable(name, current, holder, now); err != nil {
+		return err
+	}
+
+	l := &Lock{Holder: holder, AcquiredAt: now, RenewedAt: now, TTL: ttl}
+	if current != nil && current.Holder == holder {
+		l.AcquiredAt = current.AcquiredAt
+	}
+	if _, ok := mem.locks[mem.namespace]; !ok {
+		mem.locks[mem.namespace] = map[string]*Lock{}
+	}
+	mem.locks[mem.namespace][name] = l
+	return nil
+}
+
+// ReleaseLock releases the lock of the named release if it is held by holder.
+func (mem *Memory) ReleaseLock(name, holder string) error {
+	defer unlock(mem.wlock())
+
+	if l, ok := mem.locks[mem.namespace][name]; ok && l.Holder == holder {
+		delete(mem.locks[mem.namespace], name)
+	}
+	return nil
+}
+
+// GetLock returns the lock of the named release or ErrLockNotFound.
+func (mem *Memory) GetLock(name string) (*Lock, error) {
+	defer unlock(mem.rlock())
+
+	l, ok := mem.locks[mem.namespace][name]
+	if !ok {
+		return nil, ErrLockNotFound
+	}
+	copied := *l
+	return &copied, nil
+}
+
+// BreakLock removes the lock of the named release regardless of its holder.
+func (mem *Memory) BreakLock(name string) error {
+	defer unlock(mem.wlock())
+
+	if _, ok := mem.locks[mem.namespace][name]; !ok {
+		return ErrLockNotFound
+	}
+	delete(mem.locks[mem.namespace], name)
+	return nil
+}
+
 // wlock locks mem for writing
 func (mem *Memory) wlock() func() {
 	mem.Lock()
@@ -43,6 +43,14 @@ const SecretsDriverName = "Secret"
 type Secrets struct {
 	impl corev1.SecretInterface
 	Log  func(string, ...interface{})
+
+	// Encrypter encrypts the stored releases if it is set. Releases stored
+	// without encryption can still be read.
+	Encrypter Encrypter
+	// ChunkSize is the maximum size of the encoded release stored in one
+	// Secret. Larger releases are split across several Secrets. It defaults
+	// to DefaultChunkSize.
+	ChunkSize int
 }
 
 // NewSecrets initializes a new Secrets wrapping an implementation of
@@ -71,8 +79,12 @@ func (secrets *Secrets) Get(key string) (*rspb.Release, error) {
 		return nil, errors.Wrapf(err, "get: failed to get %q", key)
 	}
 	// found the secret, decode the base64 data string
-	r, err := decodeRelease(string(obj.Data["release"]))
-	return r, errors.Wrapf(err, "get: failed to decode data %q", key)
+	r, err := secrets.decode(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get: failed to decode data %q", key)
+	}
+	r.Labels = filterSystemLabels(obj.ObjectMeta.Labels)
+	return r, nil
 }
 
 // List fetches all releases and returns the list releases such
@@ -92,13 +104,13 @@ func (secrets *Secrets) List(filter func(*rspb.Release) bool) ([]*rspb.Release,
 	// iterate over the secrets object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := secrets.decode(&item)
 		if err != nil {
 			secrets.Log("list: failed to decode release: %v: %s", item, err)
 			continue
 		}
 
-		rls.Labels = item.ObjectMeta.Labels
+		rls.Labels = filterSystemLabels(item.ObjectMeta.Labels)
 
 		if filter(rls) {
 			results = append(results, rls)
@@ -131,11 +143,12 @@ func (secrets *Secrets) Query(labels map[string]string) ([]*rspb.Release, error)
 
 	var results []*rspb.Release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := secrets.decode(&item)
 		if err != nil {
 			secrets.Log("query: failed to decode release: %s", err)
 			continue
 		}
+		rls.Labels = filterSystemLabels(item.ObjectMeta.Labels)
 		results = append(results, rls)
 	}
 	return results, nil
@@ -151,12 +164,17 @@ func (secrets *Secrets) Create(key string, rls *rspb.Release) error {
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new secret to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Encrypter)
 	if err != nil {
 		return errors.Wrapf(err, "create: failed to encode release %q", rls.Name)
 	}
+	chunks, err := secrets.createChunks(obj, rls)
+	if err != nil {
+		return errors.Wrap(err, "create: failed to create chunks")
+	}
 	// push the secret object out into the kubiverse
 	if _, err := secrets.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		secrets.deleteChunks(chunks)
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -176,29 +194,132 @@ func (secrets *Secrets) Update(key string, rls *rspb.Release) error {
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new secret object to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Encrypter)
 	if err != nil {
 		return errors.Wrapf(err, "update: failed to encode release %q", rls.Name)
 	}
+	chunks, err := secrets.createChunks(obj, rls)
+	if err != nil {
+		return errors.Wrap(err, "update: failed to create chunks")
+	}
 	// push the secret object out into the kubiverse
-	_, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
-	return errors.Wrap(err, "update: failed to update")
+	if _, err := secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		secrets.deleteChunks(chunks)
+		return errors.Wrap(err, "update: failed to update")
+	}
+	// the chunks of the previous revision of the object are not linked anymore
+	secrets.deleteStaleChunks(rls.Name, strconv.Itoa(rls.Version), obj.Labels["chunkSet"])
+	return nil
 }
 
-// Delete deletes the Secret holding the release named by key.
+// Delete deletes the Secret holding the release named by key, and the Secrets
+// holding its chunks. The deleted release is returned, but deleting it does
+// not depend on decoding it: a release that cannot be decoded, for instance
+// because one of its chunks is missing, is deleted anyway and returned with
+// the fields recorded in the labels of the Secret.
 func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
-	// fetch the release to check existence
-	if rls, err = secrets.Get(key); err != nil {
-		return nil, err
+	// fetch the Secret to check existence
+	obj, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, errors.Wrapf(err, "delete: failed to get %q", key)
+	}
+	if rls, err = secrets.decode(obj); err != nil {
+		secrets.Log("delete: failed to decode release %q, deleting it anyway: %s", key, err)
+		rls = releaseFromLabels(obj.Labels)
+	} else {
+		rls.Labels = filterSystemLabels(obj.Labels)
 	}
 	// delete the release
-	err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{})
-	return rls, err
+	if err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
+		return rls, err
+	}
+	// delete its chunks, and any left over by an interrupted write
+	secrets.deleteStaleChunks(obj.Labels["name"], obj.Labels["version"], "")
+	return rls, nil
+}
+
+// decode decodes the release held by a Secret and the chunks it links.
+func (secrets *Secrets) decode(obj *v1.Secret) (*rspb.Release, error) {
+	data, err := joinChunks(obj.Name, string(obj.Data["release"]), obj.Labels, func(name string) (string, error) {
+		chunk, err := secrets.impl.Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return string(chunk.Data["release"]), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeRelease(data, secrets.Encrypter)
+}
+
+// createChunks moves the data of a Secret beyond the chunk size to new
+// Secrets, which are created before the Secret is written. It returns the
+// created Secrets.
+func (secrets *Secrets) createChunks(obj *v1.Secret, rls *rspb.Release) ([]*v1.Secret, error) {
+	parts := splitData(string(obj.Data["release"]), secrets.ChunkSize)
+	if len(parts) == 1 {
+		return nil, nil
+	}
+	set, err := newChunkSet()
+	if err != nil {
+		return nil, err
+	}
+	obj.Data["release"] = []byte(parts[0])
+	setChunks(obj.Labels, len(parts), set)
+
+	var chunks []*v1.Secret
+	for i := 1; i < len(parts); i++ {
+		chunk := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkName(obj.Name, set, i),
+				Labels: chunkLabels(rls, set, i),
+			},
+			Type: "helm.sh/release-chunk.v1",
+			Data: map[string][]byte{"release": []byte(parts[i])},
+		}
+		if _, err := secrets.impl.Create(context.Background(), chunk, metav1.CreateOptions{}); err != nil {
+			secrets.deleteChunks(chunks)
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// deleteChunks deletes Secrets holding chunks.
+func (secrets *Secrets) deleteChunks(chunks []*v1.Secret) {
+	for _, chunk := range chunks {
+		if err := secrets.impl.Delete(context.Background(), chunk.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			secrets.Log("failed to delete chunk %q: %s", chunk.Name, err)
+		}
+	}
+}
+
+// deleteStaleChunks deletes the Secrets holding chunks of a revision of a
+// release, except the chunks of the set that is in use.
+func (secrets *Secrets) deleteStaleChunks(name, version, inUse string) {
+	opts := metav1.ListOptions{LabelSelector: chunkSelector(name, version)}
+	list, err := secrets.impl.List(context.Background(), opts)
+	if err != nil {
+		secrets.Log("failed to list chunks of release %s version %s: %s", name, version, err)
+		return
+	}
+	var stale []*v1.Secret
+	for i := range list.Items {
+		if list.Items[i].Labels["chunkSet"] != inUse {
+			stale = append(stale, &list.Items[i])
+		}
+	}
+	secrets.deleteChunks(stale)
 }
 
 // newSecretsObject constructs a kubernetes Secret object
 // to store a release. Each secret data entry is the base64
-// encoded gzipped string of a release.
+// encoded gzipped string of a release, encrypted if enc is set.
 //
 // The following labels are used within each secret:
 //
@@ -209,11 +330,11 @@ func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 //    "owner"          - owner of the secret, currently "helm".
 //    "name"           - name of the release.
 //
-func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, error) {
+func newSecretsObject(key string, rls *rspb.Release, lbs labels, enc Encrypter) (*v1.Secret, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeRelease(rls, enc)
 	if err != nil {
 		return nil, err
 	}
@@ -222,6 +343,9 @@ func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, er
 		lbs.init()
 	}
 
+	// apply custom labels first, so that the labels set by Helm take precedence
+	lbs.fromMap(rls.Labels)
+
 	// apply labels
 	lbs.set("name", rls.Name)
 	lbs.set("owner", owner)
@@ -33,6 +33,7 @@ import (
 )
 
 var _ Driver = (*SQL)(nil)
+var _ ReleaseLocker = (*SQL)(nil)
 
 var labelMap = map[string]struct{}{
 	"modifiedAt": {},
@@ -63,6 +64,30 @@ const (
 	sqlReleaseTableModifiedAtColumn = "modifiedAt"
 )
 
+const (
+	sqlCustomLabelsTableName                   = "custom_labels_v1"
+	sqlCustomLabelsTableReleaseKeyColumn       = "releaseKey"
+	sqlCustomLabelsTableReleaseNamespaceColumn = "releaseNamespace"
+	sqlCustomLabelsTableKeyColumn              = "key"
+	sqlCustomLabelsTableValueColumn            = "value"
+)
+
+// Following limits based on k8s labels limits - https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#syntax-and-character-set
+const (
+	sqlCustomLabelsTableKeyMaxLength   = 253 + 1 + 63
+	sqlCustomLabelsTableValueMaxLength = 63
+)
+
+const (
+	sqlReleaseLocksTableName             = "release_locks_v1"
+	sqlReleaseLocksTableNameColumn       = "name"
+	sqlReleaseLocksTableNamespaceColumn  = "namespace"
+	sqlReleaseLocksTableHolderColumn     = "holder"
+	sqlReleaseLocksTableAcquiredAtColumn = "acquiredAt"
+	sqlReleaseLocksTableRenewedAtColumn  = "renewedAt"
+	sqlReleaseLocksTableTTLColumn        = "ttl"
+)
+
 const (
 	sqlReleaseDefaultOwner = "helm"
 	sqlReleaseDefaultType  = "helm.sh/release.v1"
@@ -75,6 +100,10 @@ type SQL struct {
 	statementBuilder sq.StatementBuilderType
 
 	Log func(string, ...interface{})
+
+	// Encrypter encrypts the stored releases if it is set. Releases stored
+	// without encryption can still be read.
+	Encrypter Encrypter
 }
 
 // Name returns the name of the driver.
@@ -150,6 +179,77 @@ func (s *SQL) ensureDBSetup() error {
 					`, sqlReleaseTableName),
 				},
 			},
+			{
+				Id: "init-custom-labels",
+				Up: []string{
+					fmt.Sprintf(`
+						CREATE TABLE %s (
+							%s VARCHAR(64),
+							%s VARCHAR(67),
+							%s VARCHAR(%d),
+							%s VARCHAR(%d)
+						);
+						CREATE INDEX ON %s (%s, %s);
+
+						GRANT ALL ON %s TO PUBLIC;
+						ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
+					`,
+						sqlCustomLabelsTableName,
+						sqlCustomLabelsTableReleaseKeyColumn,
+						sqlCustomLabelsTableReleaseNamespaceColumn,
+						sqlCustomLabelsTableKeyColumn,
+						sqlCustomLabelsTableKeyMaxLength,
+						sqlCustomLabelsTableValueColumn,
+						sqlCustomLabelsTableValueMaxLength,
+						sqlCustomLabelsTableName,
+						sqlCustomLabelsTableReleaseKeyColumn,
+						sqlCustomLabelsTableReleaseNamespaceColumn,
+						sqlCustomLabelsTableName,
+						sqlCustomLabelsTableName,
+					),
+				},
+				Down: []string{
+					fmt.Sprintf(`
+						DROP TABLE %s;
+					`, sqlCustomLabelsTableName),
+				},
+			},
+			{
+				Id: "init-release-locks",
+				Up: []string{
+					fmt.Sprintf(`
+						CREATE TABLE %s (
+							%s VARCHAR(64) NOT NULL,
+							%s VARCHAR(67) NOT NULL,
+							%s TEXT NOT NULL,
+							%s BIGINT NOT NULL,
+							%s BIGINT NOT NULL,
+							%s INTEGER NOT NULL,
+							PRIMARY KEY(%s, %s)
+						);
+
+						GRANT ALL ON %s TO PUBLIC;
+						ALTER TABLE %s ENABLE ROW LEVEL SECURITY;
+					`,
+						sqlReleaseLocksTableName,
+						sqlReleaseLocksTableNameColumn,
+						sqlReleaseLocksTableNamespaceColumn,
+						sqlReleaseLocksTableHolderColumn,
+						sqlReleaseLocksTableAcquiredAtColumn,
+						sqlReleaseLocksTableRenewedAtColumn,
+						sqlReleaseLocksTableTTLColumn,
+						sqlReleaseLocksTableNameColumn,
+						sqlReleaseLocksTableNamespaceColumn,
+						sqlReleaseLocksTableName,
+						sqlReleaseLocksTableName,
+					),
+				},
+				Down: []string{
+					fmt.Sprintf(`
+						DROP TABLE %s;
+					`, sqlReleaseLocksTableName),
+				},
+			},
 		},
 	}
 
@@ -180,6 +280,15 @@ type SQLReleaseWrapper struct {
 	ModifiedAt int    `db:"modifiedAt"`
 }
 
+// SQLReleaseCustomLabelWrapper describes how the custom labels of a Helm
+// release are stored in an SQL database
+type SQLReleaseCustomLabelWrapper struct {
+	ReleaseKey       string `db:"releaseKey"`
+	ReleaseNamespace string `db:"releaseNamespace"`
+	Key              string `db:"key"`
+	Value            string `db:"value"`
+}
+
 // NewSQL initializes a new sql driver.
 func NewSQL(connectionString string, logger func(string, ...interface{}), namespace string) (*SQL, error) {
 	db, err := sqlx.Connect(postgreSQLDialect, connectionString)
@@ -224,19 +333,24 @@ func (s *SQL) Get(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := decodeRelease(record.Body, s.Encrypter)
 	if err != nil {
 		s.Log("get: failed to decode data %q: %v", key, err)
 		return nil, err
 	}
 
+	if release.Labels, err = s.getReleaseCustomLabels(key, s.namespace); err != nil {
+		s.Log("failed to get release %s/%s custom labels: %v", s.namespace, key, err)
+		return nil, err
+	}
+
 	return release, nil
 }
 
 // List returns the list of all releases such that filter(release) == true
 func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 	sb := s.statementBuilder.
-		Select(sqlReleaseTableBodyColumn).
+		Select(sqlReleaseTableKeyColumn, sqlReleaseTableNamespaceColumn, sqlReleaseTableBodyColumn).
 		From(sqlReleaseTableName).
 		Where(sq.Eq{sqlReleaseTableOwnerColumn: sqlReleaseDefaultOwner})
 
@@ -259,11 +373,17 @@ func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := decodeRelease(record.Body, s.Encrypter)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
 		}
+
+		if release.Labels, err = s.getReleaseCustomLabels(record.Key, record.Namespace); err != nil {
+			s.Log("failed to get release %s/%s custom labels: %v", record.Namespace, record.Key, err)
+			return nil, err
+		}
+
 		if filter(release) {
 			releases = append(releases, release)
 		}
@@ -275,7 +395,7 @@ func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 // Query returns the set of releases that match the provided set of labels.
 func (s *SQL) Query(labels map[string]string) ([]*rspb.Release, error) {
 	sb := s.statementBuilder.
-		Select(sqlReleaseTableBodyColumn).
+		Select(sqlReleaseTableKeyColumn, sqlReleaseTableNamespaceColumn, sqlReleaseTableBodyColumn).
 		From(sqlReleaseTableName)
 
 	keys := make([]string, 0, len(labels))
@@ -316,11 +436,17 @@ func (s *SQL) Query(labels map[string]string) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := decodeRelease(record.Body, s.Encrypter)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
 		}
+
+		if release.Labels, err = s.getReleaseCustomLabels(record.Key, record.Namespace); err != nil {
+			s.Log("failed to get release %s/%s custom labels: %v", record.Namespace, record.Key, err)
+			return nil, err
+		}
+
 		releases = append(releases, release)
 	}
 
@@ -339,7 +465,7 @@ func (s *SQL) Create(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := encodeRelease(rls, s.Encrypter)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
@@ -403,6 +529,37 @@ func (s *SQL) Create(key string, rls *rspb.Release) error {
 		s.Log("failed to store release %s in SQL database: %v", key, err)
 		return err
 	}
+
+	// System labels are stored in the columns of the release table, so only
+	// the custom labels go to the custom labels table
+	for k, v := range filterSystemLabels(rls.Labels) {
+		insertLabelsQuery, args, err := s.statementBuilder.
+			Insert(sqlCustomLabelsTableName).
+			Columns(
+				sqlCustomLabelsTableReleaseKeyColumn,
+				sqlCustomLabelsTableReleaseNamespaceColumn,
+				sqlCustomLabelsTableKeyColumn,
+				sqlCustomLabelsTableValueColumn,
+			).
+			Values(
+				key,
+				namespace,
+				k,
+				v,
+			).ToSql()
+
+		if err != nil {
+			defer transaction.Rollback()
+			s.Log("failed to build insert query: %v", err)
+			return err
+		}
+
+		if _, err := transaction.Exec(insertLabelsQuery, args...); err != nil {
+			defer transaction.Rollback()
+			s.Log("failed to write custom labels of release %s: %v", key, err)
+			return err
+		}
+	}
 	defer transaction.Commit()
 
 	return nil
@@ -416,7 +573,7 @@ func (s *SQL) Update(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := encodeRelease(rls, s.Encrypter)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
@@ -473,7 +630,7 @@ func (s *SQL) Delete(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := decodeRelease(record.Body, s.Encrypter)
 	if err != nil {
 		s.Log("failed to decode release %s: %v", key, err)
 		transaction.Rollback()
@@ -481,6 +638,11 @@ func (s *SQL) Delete(key string) (*rspb.Release, error) {
 	}
 	defer transaction.Commit()
 
+	if release.Labels, err = s.getReleaseCustomLabels(key, s.namespace); err != nil {
+		s.Log("failed to get release %s/%s custom labels: %v", s.namespace, key, err)
+		return nil, err
+	}
+
 	deleteQuery, args, err := s.statementBuilder.
 		Delete(sqlReleaseTableName).
 		Where(sq.Eq{sqlReleaseTableKeyColumn: key}).
@@ -492,5 +654,50 @@ func (s *SQL) Delete(key string) (*rspb.Release, error) {
 	}
 
 	_, err = transaction.Exec(deleteQuery, args...)
+	if err != nil {
+		s.Log("failed to perform delete query: %v", err)
+		return release, err
+	}
+
+	deleteCustomLabelsQuery, args, err := s.statementBuilder.
+		Delete(sqlCustomLabelsTableName).
+		Where(sq.Eq{sqlCustomLabelsTableReleaseKeyColumn: key}).
+		Where(sq.Eq{sqlCustomLabelsTableReleaseNamespaceColumn: s.namespace}).
+		ToSql()
+
+	if err != nil {
+		s.Log("failed to build delete custom labels query: %v", err)
+		return nil, err
+	}
+	_, err = transaction.Exec(deleteCustomLabelsQuery, args...)
 	return release, err
 }
+
+// getReleaseCustomLabels returns the custom labels of the release with the
+// given key in the given namespace, or nil if it has none.
+func (s *SQL) getReleaseCustomLabels(key string, namespace string) (map[string]string, error) {
+	query, args, err := s.statementBuilder.
+		Select(sqlCustomLabelsTableKeyColumn, sqlCustomLabelsTableValueColumn).
+		From(sqlCustomLabelsTableName).
+		Where(sq.Eq{sqlCustomLabelsTableReleaseKeyColumn: key}).
+		Where(sq.Eq{sqlCustomLabelsTableReleaseNamespaceColumn: namespace}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var labelsList = []SQLReleaseCustomLabelWrapper{}
+	if err := s.db.Select(&labelsList, query, args...); err != nil {
+		return nil, err
+	}
+
+	var labelsMap map[string]string
+	for _, i := range labelsList {
+		if labelsMap == nil {
+			labelsMap = make(map[string]string)
+		}
+		labelsMap[i.Key] = i.Value
+	}
+
+	return filterSystemLabels(labelsMap), nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+)
+
+// AcquireLock acquires or renews the lock of the named release. The lock row
+// is locked with SELECT ... FOR UPDATE while it is checked, so that concurrent
+// holders are serialized by the database.
+func (s *SQL) AcquireLock(name, holder string, ttl time.Duration) error {
+	transaction, err := s.db.Beginx()
+	if err != nil {
+		s.Log("failed to start SQL transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %v", err)
+	}
+
+	current, err := s.selectLock(transaction, name, true)
+	if err != nil && err != ErrLockNotFound {
+		transaction.Rollback()
+		return err
+	}
+
+	now := time.Now()
+	if err := acquirable(name, current, holder, now); err != nil {
+		transaction.Rollback()
+		return err
+	}
+
+	var query string
+	var args []interface{}
+	if current == nil {
+		query, args, err = s.statementBuilder.
+			Insert(sqlReleaseLocksTableName).
+			Columns(
+				sqlReleaseLocksTableNameColumn,
+				sqlReleaseLocksTableNamespaceColumn,
+				sqlReleaseLocksTableHolderColumn,
+				sqlReleaseLocksTableAcquiredAtColumn,
+				sqlReleaseLocksTableRenewedAtColumn,
+				sqlReleaseLocksTableTTLColumn,
+			).
+			Values(name, s.namespace, holder, now.Unix(), now.Unix(), int(ttl/time.Second)).
+			ToSql()
+	} else {
+		acquiredAt := now
+		if current.Holder == holder {
+			acquiredAt = current.AcquiredAt
+		}
+		query, args, err = s.statementBuilder.
+			Update(sqlReleaseLocksTableName).
+			Set(sqlReleaseLocksTableHolderColumn, holder).
+			Set(sqlReleaseLocksTableAcquiredAtColumn, acquiredAt.Unix()).
+			Set(sqlReleaseLocksTableRenewedAtColumn, now.Unix()).
+			Set(sqlReleaseLocksTableTTLColumn, int(ttl/time.Second)).
+			Where(sq.Eq{sqlReleaseLocksTableNameColumn: name}).
+			Where(sq.Eq{sqlReleaseLocksTableNamespaceColumn: s.namespace}).
+			ToSql()
+	}
+	if err != nil {
+		transaction.Rollback()
+		s.Log("failed to build lock query: %v", err)
+		return err
+	}
+
+	if _, err := transaction.Exec(query, args...); err != nil {
+		transaction.Rollback()
+		if current == nil {
+			// The insert fails on the primary key if another holder
+			// created the lock since it was selected
+			s.Log("failed to insert lock of release %s: %v", name, err)
+			l, _ := s.GetLock(name)
+			return &ReleaseLockedError{ReleaseName: name, Lock: l}
+		}
+		s.Log("failed to update lock of release %s: %v", name, err)
+		return err
+	}
+	return transaction.Commit()
+}
+
+// ReleaseLock releases the lock of the named release if it is held by holder.
+func (s *SQL) ReleaseLock(name, holder string) error {
+	query, args, err := s.statementBuilder.
+		Delete(sqlReleaseLocksTableName).
+		Where(sq.Eq{sqlReleaseLocksTableNameColumn: name}).
+		Where(sq.Eq{sqlReleaseLocksTableNamespaceColumn: s.namespace}).
+		Where(sq.Eq{sqlReleaseLocksTableHolderColumn: holder}).
+		ToSql()
+	if err != nil {
+		s.Log("failed to build delete lock query: %v", err)
+		return err
+	}
+	if _, err := s.db.Exec(query, args...); err != nil {
+		s.Log("failed to release lock of release %s: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// GetLock returns the lock of the named release or ErrLockNotFound.
+func (s *SQL) GetLock(name string) (*Lock, error) {
+	return s.selectLock(s.db, name, false)
+}
+
+// BreakLock removes the lock of the named release regardless of its holder.
+func (s *SQL) BreakLock(name string) error {
+	query, args, err := s.statementBuilder.
+		Delete(sqlReleaseLocksTableName).
+		Where(sq.Eq{sqlReleaseLocksTableNameColumn: name}).
+		Where(sq.Eq{sqlReleaseLocksTableNamespaceColumn: s.namespace}).
+		ToSql()
+	if err != nil {
+		s.Log("failed to build delete lock query: %v", err)
+		return err
+	}
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		s.Log("failed to break lock of release %s: %v", name, err)
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrLockNotFound
+	}
+	return nil
+}
+
+// selectLock reads the lock of the named release. If forUpdate is true, the
+// row is locked until the end of the transaction.
+func (s *SQL) selectLock(q sqlx.Queryer, name string, forUpdate bool) (*Lock, error) {
+	sb := s.statementBuilder.
+		Select(
+			sqlReleaseLocksTableHolderColumn,
+			sqlReleaseLocksTableAcquiredAtColumn,
+			sqlReleaseLocksTableRenewedAtColumn,
+			sqlReleaseLocksTableTTLColumn,
+		).
+		From(sqlReleaseLocksTableName).
+		Where(sq.Eq{sqlReleaseLocksTableNameColumn: name}).
+		Where(sq.Eq{sqlReleaseLocksTableNamespaceColumn: s.namespace})
+	if forUpdate {
+		sb = sb.Suffix("FOR UPDATE")
+	}
+	query, args, err := sb.ToSql()
+	if err != nil {
+		s.Log("failed to build select lock query: %v", err)
+		return nil, err
+	}
+
+	var (
+		holder                string
+		acquiredAt, renewedAt int64
+		ttl                   int
+	)
+	if err := q.QueryRowx(query, args...).Scan(&holder, &acquiredAt, &renewedAt, &ttl); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLockNotFound
+		}
+		s.Log("failed to get lock of release %s: %v", name, err)
+		return nil, err
+	}
+	return &Lock{
+		Holder:     holder,
+		AcquiredAt: time.Unix(acquiredAt, 0),
+		RenewedAt:  time.Unix(renewedAt, 0),
+		TTL:        time.Duration(ttl) * time.Second,
+	}, nil
+}
@@ -21,6 +21,8 @@ import (
 	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	kblabels "k8s.io/apimachinery/pkg/labels"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
@@ -39,7 +41,7 @@ func TestSQLGet(t *testing.T) {
 	key := testKey(name, vers)
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
-	body, _ := encodeRelease(rel)
+	body, _ := encodeRelease(rel, nil)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
 
@@ -62,6 +64,9 @@ func TestSQLGet(t *testing.T) {
 			),
 		).RowsWillBeClosed()
 
+	rel.Labels = map[string]string{"team": "storage"}
+	mockGetReleaseCustomLabels(mock, key, namespace, rel.Labels)
+
 	got, err := sqlDriver.Get(key)
 	if err != nil {
 		t.Fatalf("Failed to get release: %v", err)
@@ -77,38 +82,42 @@ func TestSQLGet(t *testing.T) {
 }
 
 func TestSQLList(t *testing.T) {
-	body1, _ := encodeRelease(releaseStub("key-1", 1, "default", rspb.StatusUninstalled))
-	body2, _ := encodeRelease(releaseStub("key-2", 1, "default", rspb.StatusUninstalled))
-	body3, _ := encodeRelease(releaseStub("key-3", 1, "default", rspb.StatusDeployed))
-	body4, _ := encodeRelease(releaseStub("key-4", 1, "default", rspb.StatusDeployed))
-	body5, _ := encodeRelease(releaseStub("key-5", 1, "default", rspb.StatusSuperseded))
-	body6, _ := encodeRelease(releaseStub("key-6", 1, "default", rspb.StatusSuperseded))
+	body1, _ := encodeRelease(releaseStub("key-1", 1, "default", rspb.StatusUninstalled), nil)
+	body2, _ := encodeRelease(releaseStub("key-2", 1, "default", rspb.StatusUninstalled), nil)
+	body3, _ := encodeRelease(releaseStub("key-3", 1, "default", rspb.StatusDeployed), nil)
+	body4, _ := encodeRelease(releaseStub("key-4", 1, "default", rspb.StatusDeployed), nil)
+	body5, _ := encodeRelease(releaseStub("key-5", 1, "default", rspb.StatusSuperseded), nil)
+	body6, _ := encodeRelease(releaseStub("key-6", 1, "default", rspb.StatusSuperseded), nil)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
 
 	for i := 0; i < 3; i++ {
 		query := fmt.Sprintf(
-			"SELECT %s FROM %s WHERE %s = $1 AND %s = $2",
+			"SELECT %s, %s, %s FROM %s WHERE %s = $1 AND %s = $2",
+			sqlReleaseTableKeyColumn,
+			sqlReleaseTableNamespaceColumn,
 			sqlReleaseTableBodyColumn,
 			sqlReleaseTableName,
 			sqlReleaseTableOwnerColumn,
 			sqlReleaseTableNamespaceColumn,
 		)
 
+		rows := mock.NewRows([]string{
+			sqlReleaseTableKeyColumn,
+			sqlReleaseTableNamespaceColumn,
+			sqlReleaseTableBodyColumn,
+		})
+		for j, body := range []string{body1, body2, body3, body4, body5, body6} {
+			rows.AddRow(testKey(fmt.Sprintf("key-%d", j+1), 1), "default", body)
+		}
 		mock.
 			ExpectQuery(regexp.QuoteMeta(query)).
 			WithArgs(sqlReleaseDefaultOwner, sqlDriver.namespace).
-			WillReturnRows(
-				mock.NewRows([]string{
-					sqlReleaseTableBodyColumn,
-				}).
-					AddRow(body1).
-					AddRow(body2).
-					AddRow(body3).
-					AddRow(body4).
-					AddRow(body5).
-					AddRow(body6),
-			).RowsWillBeClosed()
+			WillReturnRows(rows).
+			RowsWillBeClosed()
+		for j := 1; j <= 6; j++ {
+			mockGetReleaseCustomLabels(mock, testKey(fmt.Sprintf("key-%d", j), 1), "default", nil)
+		}
 	}
 
 	// list all deleted releases
@@ -152,6 +161,60 @@ func TestSQLList(t *testing.T) {
 	}
 }
 
+func TestSQLListCustomLabels(t *testing.T) {
+	storageRel := releaseStub("key-1", 1, "default", rspb.StatusDeployed)
+	webRel := releaseStub("key-2", 1, "default", rspb.StatusDeployed)
+	storageBody, _ := encodeRelease(storageRel, nil)
+	webBody, _ := encodeRelease(webRel, nil)
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	query := fmt.Sprintf(
+		"SELECT %s, %s, %s FROM %s WHERE %s = $1 AND %s = $2",
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableNamespaceColumn,
+		sqlReleaseTableBodyColumn,
+		sqlReleaseTableName,
+		sqlReleaseTableOwnerColumn,
+		sqlReleaseTableNamespaceColumn,
+	)
+	mock.
+		ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(sqlReleaseDefaultOwner, sqlDriver.namespace).
+		WillReturnRows(
+			mock.NewRows([]string{
+				sqlReleaseTableKeyColumn,
+				sqlReleaseTableNamespaceColumn,
+				sqlReleaseTableBodyColumn,
+			}).
+				AddRow(testKey("key-1", 1), "default", storageBody).
+				AddRow(testKey("key-2", 1), "default", webBody),
+		).RowsWillBeClosed()
+	mockGetReleaseCustomLabels(mock, testKey("key-1", 1), "default", map[string]string{"team": "storage"})
+	mockGetReleaseCustomLabels(mock, testKey("key-2", 1), "default", map[string]string{"team": "web"})
+
+	selector, err := kblabels.Parse("team=storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rels, err := sqlDriver.List(func(rel *rspb.Release) bool {
+		return selector.Matches(kblabels.Set(rel.Labels))
+	})
+	if err != nil {
+		t.Fatalf("Failed to list releases: %v", err)
+	}
+	if len(rels) != 1 || rels[0].Name != "key-1" {
+		t.Fatalf("Expected release key-1 to be listed, got %v", rels)
+	}
+	if !reflect.DeepEqual(map[string]string{"team": "storage"}, rels[0].Labels) {
+		t.Errorf("Expected the custom labels of the release, got %v", rels[0].Labels)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
 func TestSqlCreate(t *testing.T) {
 	vers := 1
 	name := "smug-pigeon"
@@ -160,7 +223,48 @@ func TestSqlCreate(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
-	body, _ := encodeRelease(rel)
+	body, _ := encodeRelease(rel, nil)
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s,%s,%s,%s,%s,%s,%s,%s,%s) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)",
+		sqlReleaseTableName,
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableTypeColumn,
+		sqlReleaseTableBodyColumn,
+		sqlReleaseTableNameColumn,
+		sqlReleaseTableNamespaceColumn,
+		sqlReleaseTableVersionColumn,
+		sqlReleaseTableStatusColumn,
+		sqlReleaseTableOwnerColumn,
+		sqlReleaseTableCreatedAtColumn,
+	)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec(regexp.QuoteMeta(query)).
+		WithArgs(key, sqlReleaseDefaultType, body, rel.Name, rel.Namespace, int(rel.Version), rel.Info.Status.String(), sqlReleaseDefaultOwner, int(time.Now().Unix())).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := sqlDriver.Create(key, rel); err != nil {
+		t.Fatalf("failed to create release with key %s: %v", key, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
+func TestSqlCreateWithCustomLabels(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+	rel.Labels = map[string]string{"team": "storage"}
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+	body, _ := encodeRelease(rel, nil)
 
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s,%s,%s,%s,%s,%s,%s,%s,%s) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)",
@@ -175,12 +279,24 @@ func TestSqlCreate(t *testing.T) {
 		sqlReleaseTableOwnerColumn,
 		sqlReleaseTableCreatedAtColumn,
 	)
+	labelsQuery := fmt.Sprintf(
+		"INSERT INTO %s (%s,%s,%s,%s) VALUES ($1,$2,$3,$4)",
+		sqlCustomLabelsTableName,
+		sqlCustomLabelsTableReleaseKeyColumn,
+		sqlCustomLabelsTableReleaseNamespaceColumn,
+		sqlCustomLabelsTableKeyColumn,
+		sqlCustomLabelsTableValueColumn,
+	)
 
 	mock.ExpectBegin()
 	mock.
 		ExpectExec(regexp.QuoteMeta(query)).
 		WithArgs(key, sqlReleaseDefaultType, body, rel.Name, rel.Namespace, int(rel.Version), rel.Info.Status.String(), sqlReleaseDefaultOwner, int(time.Now().Unix())).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(regexp.QuoteMeta(labelsQuery)).
+		WithArgs(key, rel.Namespace, "team", "storage").
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	if err := sqlDriver.Create(key, rel); err != nil {
@@ -200,7 +316,7 @@ func TestSqlCreateAlreadyExists(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
-	body, _ := encodeRelease(rel)
+	body, _ := encodeRelease(rel, nil)
 
 	insertQuery := fmt.Sprintf(
 		"INSERT INTO %s (%s,%s,%s,%s,%s,%s,%s,%s,%s) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)",
@@ -261,7 +377,7 @@ func TestSqlUpdate(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
-	body, _ := encodeRelease(rel)
+	body, _ := encodeRelease(rel, nil)
 
 	query := fmt.Sprintf(
 		"UPDATE %s SET %s = $1, %s = $2, %s = $3, %s = $4, %s = $5, %s = $6 WHERE %s = $7 AND %s = $8",
@@ -308,15 +424,17 @@ func TestSqlQuery(t *testing.T) {
 	}
 
 	supersededRelease := releaseStub("smug-pigeon", 1, "default", rspb.StatusSuperseded)
-	supersededReleaseBody, _ := encodeRelease(supersededRelease)
+	supersededReleaseBody, _ := encodeRelease(supersededRelease, nil)
 	deployedRelease := releaseStub("smug-pigeon", 2, "default", rspb.StatusDeployed)
-	deployedReleaseBody, _ := encodeRelease(deployedRelease)
+	deployedReleaseBody, _ := encodeRelease(deployedRelease, nil)
 
 	// Let's actually start our test
 	sqlDriver, mock := newTestFixtureSQL(t)
 
 	query := fmt.Sprintf(
-		"SELECT %s FROM %s WHERE %s = $1 AND %s = $2 AND %s = $3 AND %s = $4",
+		"SELECT %s, %s, %s FROM %s WHERE %s = $1 AND %s = $2 AND %s = $3 AND %s = $4",
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableNamespaceColumn,
 		sqlReleaseTableBodyColumn,
 		sqlReleaseTableName,
 		sqlReleaseTableNameColumn,
@@ -330,6 +448,8 @@ func TestSqlQuery(t *testing.T) {
 		WithArgs("smug-pigeon", sqlReleaseDefaultOwner, "unknown", "default").
 		WillReturnRows(
 			mock.NewRows([]string{
+				sqlReleaseTableKeyColumn,
+				sqlReleaseTableNamespaceColumn,
 				sqlReleaseTableBodyColumn,
 			}),
 		).RowsWillBeClosed()
@@ -339,14 +459,22 @@ func TestSqlQuery(t *testing.T) {
 		WithArgs("smug-pigeon", sqlReleaseDefaultOwner, "deployed", "default").
 		WillReturnRows(
 			mock.NewRows([]string{
+				sqlReleaseTableKeyColumn,
+				sqlReleaseTableNamespaceColumn,
 				sqlReleaseTableBodyColumn,
 			}).AddRow(
+				"smug-pigeon.v2",
+				"default",
 				deployedReleaseBody,
 			),
 		).RowsWillBeClosed()
 
+	mockGetReleaseCustomLabels(mock, "smug-pigeon.v2", "default", nil)
+
 	query = fmt.Sprintf(
-		"SELECT %s FROM %s WHERE %s = $1 AND %s = $2 AND %s = $3",
+		"SELECT %s, %s, %s FROM %s WHERE %s = $1 AND %s = $2 AND %s = $3",
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableNamespaceColumn,
 		sqlReleaseTableBodyColumn,
 		sqlReleaseTableName,
 		sqlReleaseTableNameColumn,
@@ -359,14 +487,23 @@ func TestSqlQuery(t *testing.T) {
 		WithArgs("smug-pigeon", sqlReleaseDefaultOwner, "default").
 		WillReturnRows(
 			mock.NewRows([]string{
+				sqlReleaseTableKeyColumn,
+				sqlReleaseTableNamespaceColumn,
 				sqlReleaseTableBodyColumn,
 			}).AddRow(
+				"smug-pigeon.v1",
+				"default",
 				supersededReleaseBody,
 			).AddRow(
+				"smug-pigeon.v2",
+				"default",
 				deployedReleaseBody,
 			),
 		).RowsWillBeClosed()
 
+	mockGetReleaseCustomLabels(mock, "smug-pigeon.v1", "default", nil)
+	mockGetReleaseCustomLabels(mock, "smug-pigeon.v2", "default", nil)
+
 	_, err := sqlDriver.Query(labelSetUnknown)
 	if err == nil {
 		t.Errorf("Expected error {%v}, got nil", ErrReleaseNotFound)
@@ -412,7 +549,7 @@ func TestSqlDelete(t *testing.T) {
 	key := testKey(name, vers)
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
-	body, _ := encodeRelease(rel)
+	body, _ := encodeRelease(rel, nil)
 
 	sqlDriver, mock := newTestFixtureSQL(t)
 
@@ -436,6 +573,8 @@ func TestSqlDelete(t *testing.T) {
 			),
 		).RowsWillBeClosed()
 
+	mockGetReleaseCustomLabels(mock, key, namespace, nil)
+
 	deleteQuery := fmt.Sprintf(
 		"DELETE FROM %s WHERE %s = $1 AND %s = $2",
 		sqlReleaseTableName,
@@ -447,6 +586,18 @@ func TestSqlDelete(t *testing.T) {
 		ExpectExec(regexp.QuoteMeta(deleteQuery)).
 		WithArgs(key, namespace).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deleteLabelsQuery := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = $1 AND %s = $2",
+		sqlCustomLabelsTableName,
+		sqlCustomLabelsTableReleaseKeyColumn,
+		sqlCustomLabelsTableReleaseNamespaceColumn,
+	)
+
+	mock.
+		ExpectExec(regexp.QuoteMeta(deleteLabelsQuery)).
+		WithArgs(key, namespace).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	deletedRelease, err := sqlDriver.Delete(key)
@@ -461,3 +612,134 @@ func TestSqlDelete(t *testing.T) {
 		t.Errorf("Expected release {%v}, got {%v}", rel, deletedRelease)
 	}
 }
+
+func mockGetReleaseCustomLabels(mock sqlmock.Sqlmock, key string, namespace string, labels map[string]string) {
+	query := fmt.Sprintf(
+		regexp.QuoteMeta("SELECT %s, %s FROM %s WHERE %s = $1 AND %s = $2"),
+		sqlCustomLabelsTableKeyColumn,
+		sqlCustomLabelsTableValueColumn,
+		sqlCustomLabelsTableName,
+		sqlCustomLabelsTableReleaseKeyColumn,
+		sqlCustomLabelsTableReleaseNamespaceColumn,
+	)
+
+	rows := mock.NewRows([]string{
+		sqlCustomLabelsTableKeyColumn,
+		sqlCustomLabelsTableValueColumn,
+	})
+	for k, v := range labels {
+		rows.AddRow(k, v)
+	}
+
+	mock.
+		ExpectQuery(query).
+		WithArgs(key, namespace).
+		WillReturnRows(rows).
+		RowsWillBeClosed()
+}
+
+func TestSqlAcquireLock(t *testing.T) {
+	name := "smug-pigeon"
+	namespace := "default"
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	selectQuery := fmt.Sprintf(
+		"SELECT %s, %s, %s, %s FROM %s WHERE %s = $1 AND %s = $2 FOR UPDATE",
+		sqlReleaseLocksTableHolderColumn,
+		sqlReleaseLocksTableAcquiredAtColumn,
+		sqlReleaseLocksTableRenewedAtColumn,
+		sqlReleaseLocksTableTTLColumn,
+		sqlReleaseLocksTableName,
+		sqlReleaseLocksTableNameColumn,
+		sqlReleaseLocksTableNamespaceColumn,
+	)
+	insertQuery := fmt.Sprintf(
+		"INSERT INTO %s (%s,%s,%s,%s,%s,%s) VALUES ($1,$2,$3,$4,$5,$6)",
+		sqlReleaseLocksTableName,
+		sqlReleaseLocksTableNameColumn,
+		sqlReleaseLocksTableNamespaceColumn,
+		sqlReleaseLocksTableHolderColumn,
+		sqlReleaseLocksTableAcquiredAtColumn,
+		sqlReleaseLocksTableRenewedAtColumn,
+		sqlReleaseLocksTableTTLColumn,
+	)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(regexp.QuoteMeta(selectQuery)).
+		WithArgs(name, namespace).
+		WillReturnRows(mock.NewRows([]string{
+			sqlReleaseLocksTableHolderColumn,
+			sqlReleaseLocksTableAcquiredAtColumn,
+			sqlReleaseLocksTableRenewedAtColumn,
+			sqlReleaseLocksTableTTLColumn,
+		}))
+	mock.
+		ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(name, namespace, "alice", sqlmock.AnyArg(), sqlmock.AnyArg(), 60).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := sqlDriver.AcquireLock(name, "alice", time.Minute); err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+
+	// The lock is now held by alice
+	now := time.Now().Unix()
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(regexp.QuoteMeta(selectQuery)).
+		WithArgs(name, namespace).
+		WillReturnRows(mock.NewRows([]string{
+			sqlReleaseLocksTableHolderColumn,
+			sqlReleaseLocksTableAcquiredAtColumn,
+			sqlReleaseLocksTableRenewedAtColumn,
+			sqlReleaseLocksTableTTLColumn,
+		}).AddRow("alice", now, now, 60))
+	mock.ExpectRollback()
+
+	err := sqlDriver.AcquireLock(name, "bob", time.Minute)
+	if !errors.Is(err, ErrReleaseLocked) {
+		t.Fatalf("expected ErrReleaseLocked, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
+func TestSqlBreakLock(t *testing.T) {
+	name := "smug-pigeon"
+	namespace := "default"
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	deleteQuery := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s = $1 AND %s = $2",
+		sqlReleaseLocksTableName,
+		sqlReleaseLocksTableNameColumn,
+		sqlReleaseLocksTableNamespaceColumn,
+	)
+
+	mock.
+		ExpectExec(regexp.QuoteMeta(deleteQuery)).
+		WithArgs(name, namespace).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(regexp.QuoteMeta(deleteQuery)).
+		WithArgs(name, namespace).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := sqlDriver.BreakLock(name); err != nil {
+		t.Fatalf("failed to break lock: %v", err)
+	}
+	if err := sqlDriver.BreakLock(name); err != ErrLockNotFound {
+		t.Fatalf("expected ErrLockNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
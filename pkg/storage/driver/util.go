@@ -22,6 +22,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
+	"strconv"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
@@ -31,8 +32,9 @@ var b64 = base64.StdEncoding
 var magicGzip = []byte{0x1f, 0x8b, 0x08}
 
 // encodeRelease encodes a release returning a base64 encoded
-// gzipped string representation, or error.
-func encodeRelease(rls *rspb.Release) (string, error) {
+// gzipped string representation, or error. The gzipped release is
+// encrypted if enc is not nil.
+func encodeRelease(rls *rspb.Release, enc Encrypter) (string, error) {
 	b, err := json.Marshal(rls)
 	if err != nil {
 		return "", err
@@ -47,19 +49,33 @@ func encodeRelease(rls *rspb.Release) (string, error) {
 	}
 	w.Close()
 
-	return b64.EncodeToString(buf.Bytes()), nil
+	out := buf.Bytes()
+	if enc != nil {
+		if out, err = seal(enc, out); err != nil {
+			return "", err
+		}
+	}
+	return b64.EncodeToString(out), nil
 }
 
 // decodeRelease decodes the bytes of data into a release
 // type. Data must contain a base64 encoded gzipped string of a
-// valid release, otherwise an error is returned.
-func decodeRelease(data string) (*rspb.Release, error) {
+// valid release, otherwise an error is returned. Encrypted releases are
+// decrypted with enc, while releases stored without encryption are decoded
+// as they are.
+func decodeRelease(data string, enc Encrypter) (*rspb.Release, error) {
 	// base64 decode string
 	b, err := b64.DecodeString(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if isEncrypted(b) {
+		if b, err = open(enc, b); err != nil {
+			return nil, err
+		}
+	}
+
 	// For backwards compatibility with releases that were stored before
 	// compression was introduced we skip decompression if the
 	// gzip magic header is not found
@@ -83,3 +99,60 @@ func decodeRelease(data string) (*rspb.Release, error) {
 	}
 	return &rls, nil
 }
+
+// systemLabels are the labels that Helm sets on its storage objects. They
+// cannot be used as custom release labels.
+var systemLabels = []string{"name", "owner", "status", "version", "createdAt", "modifiedAt", "chunk", "chunks", "chunkSet"}
+
+// isSystemLabel checks if the given label is a system label.
+func isSystemLabel(key string) bool {
+	for _, l := range systemLabels {
+		if l == key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSystemLabels returns the labels that Helm reserves on its storage objects.
+func GetSystemLabels() []string {
+	return append([]string(nil), systemLabels...)
+}
+
+// ContainsSystemLabels checks if any of the given labels is a system label.
+func ContainsSystemLabels(lbs map[string]string) bool {
+	for k := range lbs {
+		if isSystemLabel(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseFromLabels returns the release described by the labels of its
+// storage object, for releases that cannot be decoded.
+func releaseFromLabels(lbs map[string]string) *rspb.Release {
+	version, _ := strconv.Atoi(lbs["version"])
+	return &rspb.Release{
+		Name:    lbs["name"],
+		Version: version,
+		Info:    &rspb.Info{Status: rspb.Status(lbs["status"])},
+		Labels:  filterSystemLabels(lbs),
+	}
+}
+
+// filterSystemLabels removes the system labels from the given labels. It
+// returns nil if no other labels are left.
+func filterSystemLabels(lbs map[string]string) map[string]string {
+	var result map[string]string
+	for k, v := range lbs {
+		if isSystemLabel(k) {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]string)
+		}
+		result[k] = v
+	}
+	return result
+}
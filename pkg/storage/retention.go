@@ -0,0 +1,191 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "helm.sh/helm/v3/pkg/storage"
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+	relutil "helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// RetentionPolicy decides which revisions of a release are kept in its
+// history. Limits of 0 or less are ignored.
+//
+// The last revision, the deployed revision and pending revisions are always
+// kept, whatever the limits.
+type RetentionPolicy struct {
+	// MaxHistory is the maximum number of revisions kept.
+	MaxHistory int
+	// MaxAge removes the revisions last deployed longer ago than MaxAge.
+	MaxAge time.Duration
+	// KeepDeployed is the number of most recent revisions that were
+	// deployed, i.e. that are deployed or superseded, which are kept even if
+	// they are beyond the other limits.
+	KeepDeployed int
+	// MaxSuperseded is the maximum number of superseded revisions kept.
+	MaxSuperseded int
+	// MaxFailed is the maximum number of failed revisions kept.
+	MaxFailed int
+}
+
+// Prunable returns the revisions of a release history that the policy does
+// not keep at the given time, from the oldest to the newest.
+func (p RetentionPolicy) Prunable(history []*rspb.Release, now time.Time) []*rspb.Release {
+	h := make([]*rspb.Release, len(history))
+	copy(h, history)
+	// Newest first, so that the most recent revisions are kept
+	relutil.Reverse(h, relutil.SortByRevision)
+
+	protected := make([]bool, len(h))
+	deployed, wasDeployed := false, 0
+	for i, rel := range h {
+		status := rel.Info.Status
+		switch {
+		case i == 0, status.IsPending():
+			protected[i] = true
+		case status == rspb.StatusDeployed && !deployed:
+			protected[i] = true
+		}
+		if status == rspb.StatusDeployed {
+			deployed = true
+		}
+		if status == rspb.StatusDeployed || status == rspb.StatusSuperseded {
+			if wasDeployed < p.KeepDeployed {
+				protected[i] = true
+			}
+			wasDeployed++
+		}
+	}
+
+	keep := make([]bool, len(h))
+	kept := map[rspb.Status]int{}
+	for i, rel := range h {
+		status := rel.Info.Status
+		keep[i] = protected[i] || !p.exceeds(rel, kept[status], now)
+		if keep[i] {
+			kept[status]++
+		}
+	}
+
+	if p.MaxHistory > 0 {
+		count := 0
+		for _, k := range keep {
+			if k {
+				count++
+			}
+		}
+		// Drop the oldest revisions first
+		for i := len(h) - 1; i >= 0 && count > p.MaxHistory; i-- {
+			if keep[i] && !protected[i] {
+				keep[i] = false
+				count--
+			}
+		}
+	}
+
+	var prunable []*rspb.Release
+	for i := len(h) - 1; i >= 0; i-- {
+		if !keep[i] {
+			prunable = append(prunable, h[i])
+		}
+	}
+	return prunable
+}
+
+// exceeds returns whether a revision is beyond the limits of the policy, given
+// the number of newer revisions with the same status that are kept.
+func (p RetentionPolicy) exceeds(rel *rspb.Release, newer int, now time.Time) bool {
+	if p.MaxAge > 0 && now.Sub(rel.Info.LastDeployed.Time) > p.MaxAge {
+		return true
+	}
+	switch rel.Info.Status {
+	case rspb.StatusSuperseded:
+		return p.MaxSuperseded > 0 && newer >= p.MaxSuperseded
+	case rspb.StatusFailed:
+		return p.MaxFailed > 0 && newer >= p.MaxFailed
+	}
+	return false
+}
+
+// CreateRetained creates a new storage entry holding the release like
+// Create, after removing the revisions of its history that the policy does
+// not keep once the release is added to it.
+func (s *Storage) CreateRetained(rls *rspb.Release, policy RetentionPolicy) error {
+	s.Log("creating release %q", makeKey(rls.Name, rls.Version))
+	h, err := s.History(rls.Name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return err
+	}
+
+	errs := []error{}
+	pruned := 0
+	for _, rel := range policy.Prunable(append(h, rls), time.Now()) {
+		if rel == rls {
+			continue
+		}
+		if err := s.deleteReleaseVersion(rel.Name, rel.Version); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned++
+	}
+	if pruned > 0 || len(errs) > 0 {
+		s.Log("Pruned %d record(s) from %s with %d error(s)", pruned, rls.Name, len(errs))
+	}
+	switch c := len(errs); c {
+	case 0:
+	case 1:
+		return errs[0]
+	default:
+		return errors.Errorf("encountered %d deletion errors. First is: %s", c, errs[0])
+	}
+
+	return s.Driver.Create(makeKey(rls.Name, rls.Version), rls)
+}
+
+// Prune removes the revisions of the named release that the policy does not
+// keep, and returns them.
+func (s *Storage) Prune(name string, policy RetentionPolicy) ([]*rspb.Release, error) {
+	h, err := s.History(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []*rspb.Release
+	errs := []error{}
+	for _, rel := range policy.Prunable(h, time.Now()) {
+		if err := s.deleteReleaseVersion(name, rel.Version); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, rel)
+	}
+
+	s.Log("Pruned %d record(s) from %s with %d error(s)", len(pruned), name, len(errs))
+	switch c := len(errs); c {
+	case 0:
+		return pruned, nil
+	case 1:
+		return pruned, errs[0]
+	default:
+		return pruned, errors.Errorf("encountered %d deletion errors. First is: %s", c, errs[0])
+	}
+}
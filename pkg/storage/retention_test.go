@@ -0,0 +1,196 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "helm.sh/helm/v3/pkg/storage"
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// retentionHistory returns the revisions of a release with the given
+// statuses, one day apart, the last one being deployed at now.
+func retentionHistory(now time.Time, statuses ...rspb.Status) []*rspb.Release {
+	var h []*rspb.Release
+	for i, status := range statuses {
+		rls := ReleaseTestData{Name: "angry-bird", Version: i + 1, Status: status}.ToRelease()
+		age := time.Duration(len(statuses)-1-i) * 24 * time.Hour
+		rls.Info.LastDeployed = helmtime.Time{Time: now.Add(-age)}
+		h = append(h, rls)
+	}
+	return h
+}
+
+func versions(rels []*rspb.Release) []int {
+	var v []int
+	for _, rls := range rels {
+		v = append(v, rls.Version)
+	}
+	return v
+}
+
+func TestRetentionPolicyPrunable(t *testing.T) {
+	const (
+		deployed   = rspb.StatusDeployed
+		superseded = rspb.StatusSuperseded
+		failed     = rspb.StatusFailed
+		pending    = rspb.StatusPendingUpgrade
+	)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		statuses []rspb.Status
+		expected []int
+	}{
+		{
+			name:     "no limits",
+			statuses: []rspb.Status{superseded, superseded, failed, deployed},
+		},
+		{
+			name:     "max history keeps the deployed revision",
+			policy:   RetentionPolicy{MaxHistory: 2},
+			statuses: []rspb.Status{superseded, deployed, failed, failed, failed},
+			expected: []int{1, 3, 4},
+		},
+		{
+			name:     "max age",
+			policy:   RetentionPolicy{MaxAge: 2*day + time.Hour},
+			statuses: []rspb.Status{superseded, superseded, superseded, superseded, deployed},
+			expected: []int{1, 2},
+		},
+		{
+			name:     "max age keeps the last and deployed revisions",
+			policy:   RetentionPolicy{MaxAge: time.Hour},
+			statuses: []rspb.Status{superseded, deployed, failed, failed},
+			expected: []int{1, 3},
+		},
+		{
+			name:     "max superseded",
+			policy:   RetentionPolicy{MaxSuperseded: 1},
+			statuses: []rspb.Status{superseded, superseded, failed, superseded, deployed},
+			expected: []int{1, 2},
+		},
+		{
+			name:     "max failed",
+			policy:   RetentionPolicy{MaxFailed: 1},
+			statuses: []rspb.Status{failed, superseded, failed, deployed, failed},
+			expected: []int{1, 3},
+		},
+		{
+			name:     "keep deployed overrides the limits",
+			policy:   RetentionPolicy{MaxAge: time.Hour, KeepDeployed: 3},
+			statuses: []rspb.Status{superseded, superseded, failed, superseded, superseded, deployed},
+			expected: []int{1, 2, 3},
+		},
+		{
+			name:     "keep deployed overrides max history",
+			policy:   RetentionPolicy{MaxHistory: 1, KeepDeployed: 2},
+			statuses: []rspb.Status{superseded, failed, superseded, failed, deployed},
+			expected: []int{1, 2, 4},
+		},
+		{
+			name:     "pending revisions are kept",
+			policy:   RetentionPolicy{MaxAge: time.Hour},
+			statuses: []rspb.Status{superseded, deployed, pending, failed},
+			expected: []int{1},
+		},
+		{
+			name:     "limits combine",
+			policy:   RetentionPolicy{MaxHistory: 3, MaxFailed: 1},
+			statuses: []rspb.Status{superseded, superseded, failed, superseded, failed, deployed},
+			expected: []int{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			h := retentionHistory(now, tt.statuses...)
+			got := versions(tt.policy.Prunable(h, now))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected revisions %v to be pruned, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStoragePrune(t *testing.T) {
+	storage := Init(driver.NewMemory())
+	storage.Log = t.Logf
+
+	h := retentionHistory(time.Now(),
+		rspb.StatusSuperseded, rspb.StatusSuperseded, rspb.StatusFailed, rspb.StatusSuperseded, rspb.StatusDeployed)
+	for _, rls := range h {
+		assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+	}
+
+	pruned, err := storage.Prune("angry-bird", RetentionPolicy{MaxSuperseded: 1, MaxFailed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(pruned); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("expected revisions [1 2] to be pruned, got %v", got)
+	}
+
+	hist, err := storage.History("angry-bird")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := map[int]bool{}
+	for _, rls := range hist {
+		remaining[rls.Version] = true
+	}
+	if !reflect.DeepEqual(remaining, map[int]bool{3: true, 4: true, 5: true}) {
+		t.Errorf("expected revisions 3, 4 and 5 to remain, got %v", remaining)
+	}
+}
+
+func TestStorageCreateWithRetention(t *testing.T) {
+	storage := Init(driver.NewMemory())
+	storage.Log = t.Logf
+
+	now := time.Now()
+	h := retentionHistory(now,
+		rspb.StatusSuperseded, rspb.StatusFailed, rspb.StatusFailed, rspb.StatusDeployed)
+	for _, rls := range h {
+		assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+	}
+
+	// The policy applies to the revision being created, not MaxHistory
+	storage.MaxHistory = 1
+	storage.Retention = &RetentionPolicy{MaxFailed: 1}
+	rls := ReleaseTestData{Name: "angry-bird", Version: 5, Status: rspb.StatusPendingUpgrade}.ToRelease()
+	rls.Info.LastDeployed = helmtime.Time{Time: now}
+	assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+
+	hist, err := storage.History("angry-bird")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := map[int]bool{}
+	for _, rls := range hist {
+		remaining[rls.Version] = true
+	}
+	if !reflect.DeepEqual(remaining, map[int]bool{1: true, 3: true, 4: true, 5: true}) {
+		t.Errorf("expected revisions 1, 3, 4 and 5 to remain, got %v", remaining)
+	}
+}
@@ -43,6 +43,14 @@ type Storage struct {
 	// ignored (meaning no limits are imposed).
 	MaxHistory int
 
+	// Retention, if set, decides which revisions are kept in the history of
+	// a release when a new revision is created, instead of MaxHistory.
+	Retention *RetentionPolicy
+
+	// Locker locks releases against concurrent operations. It is nil if the
+	// driver does not support locking.
+	Locker driver.ReleaseLocker
+
 	Log func(string, ...interface{})
 }
 
@@ -58,6 +66,9 @@ func (s *Storage) Get(name string, version int) (*rspb.Release, error) {
 // error is returned if the storage driver fails to store the
 // release, or a release with an identical key already exists.
 func (s *Storage) Create(rls *rspb.Release) error {
+	if s.Retention != nil {
+		return s.CreateRetained(rls, *s.Retention)
+	}
 	s.Log("creating release %q", makeKey(rls.Name, rls.Version))
 	if s.MaxHistory > 0 {
 		// Want to make space for one more release.
@@ -259,8 +270,12 @@ func Init(d driver.Driver) *Storage {
 	if d == nil {
 		d = driver.NewMemory()
 	}
-	return &Storage{
+	s := &Storage{
 		Driver: d,
 		Log:    func(_ string, _ ...interface{}) {},
 	}
+	if l, ok := d.(driver.ReleaseLocker); ok {
+		s.Locker = l
+	}
+	return s
 }
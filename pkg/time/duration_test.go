@@ -0,0 +1,73 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package time
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationMarshal(t *testing.T) {
+	res, err := json.Marshal(Duration(5 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != `"5m0s"` {
+		t.Errorf("expected a marshaled value of \"5m0s\", got %s", res)
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	for in, expected := range map[string]Duration{
+		`"5m"`:           Duration(5 * time.Minute),
+		`"1h30m"`:        Duration(90 * time.Minute),
+		`""`:             0,
+		`null`:           0,
+		`300000000000`:   Duration(5 * time.Minute),
+		`"300000000000"`: -1,
+		`"five minutes"`: -1,
+	} {
+		var d Duration
+		err := json.Unmarshal([]byte(in), &d)
+		if expected < 0 {
+			if err == nil {
+				t.Errorf("expected an error unmarshaling %s, got %s", in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error unmarshaling %s: %s", in, err)
+			continue
+		}
+		if d != expected {
+			t.Errorf("expected %s to unmarshal to %s, got %s", in, expected, d)
+		}
+	}
+}
+
+func TestDurationOmitEmpty(t *testing.T) {
+	res, err := json.Marshal(struct {
+		Timeout Duration `json:"timeout,omitempty"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != `{}` {
+		t.Errorf("expected a zero duration to be omitted, got %s", res)
+	}
+}